@@ -38,6 +38,7 @@ type Options struct {
 	Desc       bool
 	Takeout    bool
 	Group      bool // auto detect grouped message
+	Join       bool // join parts uploaded by `tdl up --split`
 
 	// resume opts
 	Continue, Restart bool
@@ -101,11 +102,21 @@ func Run(ctx context.Context, c *telegram.Client, kvd storage.Storage, opts Opti
 	dlProgress.SetNumTrackersExpected(it.Total())
 	prog.EnablePS(ctx, dlProgress)
 
+	var j *joiner
+	if opts.Join {
+		j = newJoiner()
+		defer func() { // join after progress is done
+			if rerr == nil {
+				j.join()
+			}
+		}()
+	}
+
 	options := downloader.Options{
 		Pool:     pool,
 		Threads:  viper.GetInt(consts.FlagThreads),
 		Iter:     it,
-		Progress: newProgress(dlProgress, it, opts),
+		Progress: newProgress(dlProgress, it, opts, j),
 	}
 	limit := viper.GetInt(consts.FlagLimit)
 
@@ -0,0 +1,134 @@
+package dl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/go-faster/errors"
+
+	"github.com/lshcx/tdl/core/util/fsutil"
+)
+
+// partCaptionRe matches part caption written by `tdl up --split`, e.g. `【分卷】1/3 file.bin.001`
+var partCaptionRe = regexp.MustCompile(`【分卷】\d+/(\d+) (\S+)`)
+
+// joiner collects downloaded parts and reassembles them after download
+type joiner struct {
+	mu     *sync.Mutex
+	parts  map[string]map[int]string // dir/base -> index -> downloaded path
+	totals map[string]int            // dir/base -> total parts declared in caption
+}
+
+func newJoiner() *joiner {
+	return &joiner{
+		mu:     &sync.Mutex{},
+		parts:  make(map[string]map[int]string),
+		totals: make(map[string]int),
+	}
+}
+
+// add records downloaded file at path. name is the original file name in message.
+func (j *joiner) add(path, name, caption string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	dir := filepath.Dir(path)
+
+	for _, m := range partCaptionRe.FindAllStringSubmatch(caption, -1) {
+		base, _, ok := fsutil.ParsePartName(m[2])
+		if !ok {
+			continue
+		}
+		if total, err := strconv.Atoi(m[1]); err == nil {
+			j.totals[filepath.Join(dir, base)] = total
+		}
+	}
+
+	base, index, ok := fsutil.ParsePartName(name)
+	if !ok {
+		return
+	}
+
+	key := filepath.Join(dir, base)
+	if j.parts[key] == nil {
+		j.parts[key] = make(map[int]string)
+	}
+	j.parts[key][index] = path
+}
+
+// join reassembles all complete part sets. Incomplete or failed sets are kept as they are.
+func (j *joiner) join() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	keys := make([]string, 0, len(j.parts))
+	for key := range j.parts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		dst, err := j.joinSet(key)
+		if err != nil {
+			color.Red("Join parts of %s failed: %s", key, err)
+			continue
+		}
+		color.Green("Joined parts into %s", dst)
+	}
+}
+
+func (j *joiner) joinSet(key string) (string, error) {
+	set := j.parts[key]
+
+	// without caption, parts must be contiguous from 1
+	total, ok := j.totals[key]
+	if !ok {
+		total = len(set)
+	}
+	if len(set) != total {
+		return "", errors.Errorf("only %d of %d parts downloaded", len(set), total)
+	}
+
+	parts := make([]string, 0, total)
+	for i := 1; i <= total; i++ {
+		p, ok := set[i]
+		if !ok {
+			return "", errors.Errorf("missing part %d", i)
+		}
+		parts = append(parts, p)
+	}
+
+	if fsutil.PathExists(key) {
+		return "", fmt.Errorf("file already exists: %s", key)
+	}
+	if err := fsutil.JoinFiles(parts, key); err != nil {
+		return "", errors.Wrap(err, "join files")
+	}
+
+	dst := key
+	// volumes of `tdl up --split zip` contain only one file, other zip files are kept as they are
+	if strings.HasSuffix(key, ".zip") {
+		if unzipped, err := fsutil.UnzipSingle(key, filepath.Dir(key)); err == nil {
+			_ = os.Remove(key)
+			dst = unzipped
+		}
+	}
+
+	return dst, removeParts(parts)
+}
+
+func removeParts(parts []string) error {
+	for _, p := range parts {
+		if err := os.Remove(p); err != nil {
+			return errors.Wrap(err, "remove part")
+		}
+	}
+	return nil
+}
@@ -24,15 +24,17 @@ type progress struct {
 	trackers *sync.Map // map[ID]*pw.Tracker
 	opts     Options
 
-	it *iter
+	it     *iter
+	joiner *joiner // nil if join is disabled
 }
 
-func newProgress(p pw.Writer, it *iter, opts Options) *progress {
+func newProgress(p pw.Writer, it *iter, opts Options, joiner *joiner) *progress {
 	return &progress{
 		pw:       p,
 		trackers: &sync.Map{},
 		opts:     opts,
 		it:       it,
+		joiner:   joiner,
 	}
 }
 
@@ -96,10 +98,15 @@ func (p *progress) donePost(elem *iterElem) error {
 		}
 	}
 
-	if err := os.Rename(elem.to.Name(), filepath.Join(filepath.Dir(elem.to.Name()), newfile)); err != nil {
+	newpath := filepath.Join(filepath.Dir(elem.to.Name()), newfile)
+	if err := os.Rename(elem.to.Name(), newpath); err != nil {
 		return errors.Wrap(err, "rename file")
 	}
 
+	if p.joiner != nil {
+		p.joiner.add(newpath, elem.file.Name, elem.fromMsg.Message)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,200 @@
+package forward
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/go-faster/errors"
+	"github.com/gotd/td/telegram/peers"
+	"github.com/gotd/td/telegram/query"
+	"github.com/gotd/td/tg"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+
+	"github.com/lshcx/tdl/core/dcpool"
+	"github.com/lshcx/tdl/core/forwarder"
+	"github.com/lshcx/tdl/core/logctx"
+	"github.com/lshcx/tdl/core/storage"
+	"github.com/lshcx/tdl/core/util/tutil"
+	"github.com/lshcx/tdl/pkg/key"
+)
+
+// dedup skips messages whose content is already in destination peer.
+// Fingerprints of destination messages are saved as a ledger in storage, and only messages
+// newer than the last scan are scanned in subsequent runs. Nothing is saved in dry run.
+type dedup struct {
+	kvd    storage.Storage
+	pool   dcpool.Pool
+	rec    *record
+	limit  int // max number of recent destination messages to scan
+	dryRun bool
+
+	mu      sync.Mutex
+	scanned map[int64]struct{}            // destinations which are scanned in this run
+	seen    map[int64]map[string]struct{} // fingerprints reserved by dispatched messages, and scanned ones in dry run
+	skipped atomic.Int64
+}
+
+func newDedup(kvd storage.Storage, pool dcpool.Pool, rec *record, limit int, dryRun bool) *dedup {
+	return &dedup{
+		kvd:     kvd,
+		pool:    pool,
+		rec:     rec,
+		limit:   limit,
+		dryRun:  dryRun,
+		scanned: make(map[int64]struct{}),
+		seen:    make(map[int64]map[string]struct{}),
+	}
+}
+
+// duplicated reports whether the message is already in destination peer. If it's not duplicated,
+// its fingerprint is reserved and returned, so that later messages with same content are skipped even if
+// it isn't sent yet. The reservation must be released if the message fails to be sent.
+// Duplicated messages are recorded as finished of the job.
+func (d *dedup) duplicated(ctx context.Context, from peers.Peer, msg *tg.Message, to peers.Peer) (bool, string, error) {
+	dup, fp, err := d.check(ctx, from, msg, to)
+	if err != nil || !dup {
+		return false, fp, err
+	}
+
+	d.skipped.Inc()
+	if d.dryRun {
+		return true, "", nil
+	}
+	if err = d.rec.skip(from.ID(), msg.ID); err != nil {
+		return false, "", errors.Wrap(err, "save skipped message")
+	}
+	return true, "", nil
+}
+
+func (d *dedup) check(ctx context.Context, from peers.Peer, msg *tg.Message, to peers.Peer) (bool, string, error) {
+	// forwarded by previous runs
+	if _, err := d.kvd.Get(ctx, key.ForwardMap(from.ID(), msg.ID, to.ID())); err == nil {
+		return true, "", nil
+	}
+
+	fp, ok := tutil.Fingerprint(msg)
+	if !ok {
+		return false, "", nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.scan(ctx, to); err != nil {
+		return false, "", errors.Wrapf(err, "scan destination: %d", to.ID())
+	}
+
+	if _, ok = d.seen[to.ID()][fp]; ok {
+		return true, "", nil
+	}
+	if _, err := d.kvd.Get(ctx, key.ForwardDedup(to.ID(), fp)); err == nil {
+		return true, "", nil
+	}
+
+	d.see(to.ID(), fp)
+	return false, fp, nil
+}
+
+func (d *dedup) see(to int64, fp string) {
+	seen, ok := d.seen[to]
+	if !ok {
+		seen = make(map[string]struct{})
+		d.seen[to] = seen
+	}
+	seen[fp] = struct{}{}
+}
+
+// release drops reserved fingerprints of messages which fail to be sent
+func (d *dedup) release(to int64, fps []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, fp := range fps {
+		delete(d.seen[to], fp)
+	}
+}
+
+// wrap returns progress which releases reserved fingerprints of failed messages
+func (d *dedup) wrap(p forwarder.Progress) forwarder.Progress {
+	return &dedupProgress{Progress: p, dedup: d}
+}
+
+type dedupProgress struct {
+	forwarder.Progress
+	dedup *dedup
+}
+
+// OnDone implements forwarder.Progress
+func (p *dedupProgress) OnDone(elem forwarder.Elem, err error) {
+	if e, ok := elem.(*iterElem); ok && err != nil {
+		p.dedup.release(e.to.ID(), e.reserved)
+	}
+
+	p.Progress.OnDone(elem, err)
+}
+
+// scan saves fingerprints of destination messages which are newer than the last scan, once per run
+func (d *dedup) scan(ctx context.Context, to peers.Peer) error {
+	if _, ok := d.scanned[to.ID()]; ok {
+		return nil
+	}
+
+	last := 0
+	if b, err := d.kvd.Get(ctx, key.ForwardDedupScan(to.ID())); err == nil {
+		last, _ = strconv.Atoi(string(b))
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		return errors.Wrap(err, "get last scan")
+	}
+
+	// fingerprints are only kept in memory in dry run
+	save := func(fp string, id int) error {
+		if d.dryRun {
+			d.see(to.ID(), fp)
+			return nil
+		}
+		return d.kvd.Set(ctx, key.ForwardDedup(to.ID(), fp), []byte(strconv.Itoa(id)))
+	}
+
+	// from latest to oldest
+	it := query.Messages(d.pool.Default(ctx)).GetHistory(to.InputPeer()).BatchSize(100).Iter()
+	latest, n := last, 0
+	for n < d.limit && it.Next(ctx) {
+		m, ok := it.Value().Msg.(*tg.Message)
+		if !ok {
+			continue
+		}
+		if m.ID <= last {
+			break
+		}
+		n++
+		latest = max(latest, m.ID)
+
+		fp, ok := tutil.Fingerprint(m)
+		if !ok {
+			continue
+		}
+		if err := save(fp, m.ID); err != nil {
+			return errors.Wrap(err, "save fingerprint")
+		}
+	}
+	if err := it.Err(); err != nil {
+		return errors.Wrap(err, "iterate history")
+	}
+
+	if !d.dryRun {
+		if err := d.kvd.Set(ctx, key.ForwardDedupScan(to.ID()), []byte(strconv.Itoa(latest))); err != nil {
+			return errors.Wrap(err, "save last scan")
+		}
+	}
+
+	logctx.From(ctx).Debug("Scan destination for dedup",
+		zap.Int64("to", to.ID()),
+		zap.Int("last", last),
+		zap.Int("latest", latest),
+		zap.Int("scanned", n))
+
+	d.scanned[to.ID()] = struct{}{}
+	return nil
+}
@@ -1,6 +1,9 @@
 package forward
 
 import (
+	"context"
+
+	"github.com/go-faster/errors"
 	"github.com/gotd/td/telegram/peers"
 	"github.com/gotd/td/tg"
 
@@ -12,8 +15,12 @@ type iterElem struct {
 	msg          *tg.Message
 	to           peers.Peer
 	thread       int
+	schedule     int
+	sendAs       peers.Peer
 	modeOverride forwarder.Mode
 	opts         iterOptions
+	iter         *iter    // prepares grouped messages in the same way as msg
+	reserved     []string // fingerprints reserved by dedup, which are released if sending fails
 }
 
 func (i *iterElem) Mode() forwarder.Mode {
@@ -31,8 +38,55 @@ func (i *iterElem) To() peers.Peer { return i.to }
 
 func (i *iterElem) Thread() int { return i.thread }
 
+func (i *iterElem) Schedule() int { return i.schedule }
+
+func (i *iterElem) SendAs() peers.Peer { return i.sendAs }
+
 func (i *iterElem) AsSilent() bool { return i.opts.silent }
 
 func (i *iterElem) AsDryRun() bool { return i.opts.dryRun }
 
 func (i *iterElem) AsGrouped() bool { return i.opts.grouped }
+
+func (i *iterElem) AsDropAuthor() bool { return i.opts.dropAuthor }
+
+func (i *iterElem) AsDropCaption() bool { return i.opts.dropCaption }
+
+// Grouped implements forwarder.GroupedElem, other messages of the album are transformed and stripped
+// like msg. Each message of the album is compared by dedup, and only duplicated ones are dropped.
+func (i *iterElem) Grouped(ctx context.Context, msgs []*tg.Message) (_ []*tg.Message, rerr error) {
+	if i.opts.dedup != nil {
+		defer func() {
+			if rerr != nil { // album isn't sent
+				i.opts.dedup.release(i.to.ID(), i.reserved)
+				i.reserved = nil
+			}
+		}()
+	}
+
+	result := make([]*tg.Message, 0, len(msgs))
+	for _, m := range msgs {
+		if m != i.msg { // msg is already prepared by iter
+			transformed := i.iter.transform(m)
+			if stripped := i.iter.strip(m); transformed || stripped {
+				// direct mode can't modify message content, so we force it to be clone mode
+				i.modeOverride = forwarder.ModeClone
+			}
+		}
+
+		if i.opts.dedup != nil {
+			dup, fp, err := i.opts.dedup.duplicated(ctx, i.from, m, i.to)
+			if err != nil {
+				return nil, errors.Wrap(err, "dedup")
+			}
+			if dup {
+				continue
+			}
+			if fp != "" {
+				i.reserved = append(i.reserved, fp)
+			}
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
@@ -6,9 +6,11 @@ import (
 	"os"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/expr-lang/expr"
 	"github.com/expr-lang/expr/vm"
+	"github.com/fatih/color"
 	"github.com/go-faster/errors"
 	"github.com/gotd/td/telegram"
 	"github.com/gotd/td/telegram/peers"
@@ -21,6 +23,7 @@ import (
 	"github.com/lshcx/tdl/core/forwarder"
 	"github.com/lshcx/tdl/core/storage"
 	"github.com/lshcx/tdl/core/tclient"
+	"github.com/lshcx/tdl/core/util/textutil"
 	"github.com/lshcx/tdl/core/util/tutil"
 	"github.com/lshcx/tdl/pkg/consts"
 	"github.com/lshcx/tdl/pkg/prog"
@@ -32,18 +35,45 @@ type Options struct {
 	From   []string
 	To     string
 	Edit   string
+	Parse  textutil.ParseMode
 	Mode   forwarder.Mode
 	Silent bool
 	DryRun bool
 	Single bool
 	Desc   bool
+
+	// Transform is a file of transform steps applied to text of messages, transformed messages are cloned
+	Transform string
+
+	// republish opts
+	DropAuthor   bool
+	DropCaption  bool
+	StripMarkup  bool
+	DropEntities []string
+
+	// schedule opts
+	Schedule         string
+	ScheduleInterval time.Duration
+	SendAs           string
+
+	// dedup opts
+	Dedup     bool
+	DedupScan int // max number of recent destination messages to scan
+
+	// resume opts
+	Continue, Restart bool
+
+	// Watch forwards new messages of From chats in real time, and propagates edits and deletions.
+	// Updates must be the update handler of the client.
+	Watch   bool
+	Updates *Updates
 }
 
 func Run(ctx context.Context, c *telegram.Client, kvd storage.Storage, opts Options) (rerr error) {
 	if opts.To == "-" || opts.Edit == "-" {
 		fg := texpr.NewFieldsGetter(nil)
 
-		fields, err := fg.Walk(exprEnv(nil, nil))
+		fields, err := fg.Walk(exprEnv(nil, nil, nil))
 		if err != nil {
 			return fmt.Errorf("failed to walk fields: %w", err)
 		}
@@ -61,11 +91,6 @@ func Run(ctx context.Context, c *telegram.Client, kvd storage.Storage, opts Opti
 
 	ctx = tctx.WithPool(ctx, pool)
 
-	dialogs, err := collectDialogs(ctx, opts.From, opts.Desc)
-	if err != nil {
-		return errors.Wrap(err, "collect dialogs")
-	}
-
 	manager := peers.Options{Storage: storage.NewPeers(kvd)}.Build(pool.Default(ctx))
 
 	to, err := resolveDest(ctx, manager, opts.To)
@@ -78,26 +103,123 @@ func Run(ctx context.Context, c *telegram.Client, kvd storage.Storage, opts Opti
 		return errors.Wrap(err, "resolve edit")
 	}
 
+	transforms, err := loadTransforms(opts.Transform, opts.Parse)
+	if err != nil {
+		return errors.Wrap(err, "load transforms")
+	}
+
+	dropEntities, err := parseEntityTypes(opts.DropEntities)
+	if err != nil {
+		return errors.Wrap(err, "parse entity types")
+	}
+
+	if opts.Watch && (opts.Schedule != "" || opts.ScheduleInterval > 0) {
+		return errors.New("schedule can't be used in watch mode")
+	}
+	scheduleStart, err := parseSchedule(opts.Schedule, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "parse schedule")
+	}
+	if scheduleStart.IsZero() && opts.ScheduleInterval > 0 {
+		return errors.New("schedule interval requires schedule time")
+	}
+
+	var sendAs peers.Peer
+	if opts.SendAs != "" {
+		if sendAs, err = tutil.GetInputPeer(ctx, manager, opts.SendAs); err != nil {
+			return errors.Wrap(err, "resolve send as peer")
+		}
+	}
+
+	iterOpts := iterOptions{
+		manager: manager,
+		pool:    pool,
+		to:      to,
+		edit:    edit,
+		parse:   opts.Parse,
+		mode:    opts.Mode,
+		silent:  opts.Silent,
+		dryRun:  opts.DryRun,
+		grouped: !opts.Single,
+		delay:   viper.GetDuration(consts.FlagDelay),
+
+		dropAuthor:   opts.DropAuthor,
+		dropCaption:  opts.DropCaption,
+		stripMarkup:  opts.StripMarkup,
+		dropEntities: dropEntities,
+
+		scheduleStart:    scheduleStart,
+		scheduleInterval: opts.ScheduleInterval,
+		sendAs:           sendAs,
+
+		transforms: transforms,
+	}
+
+	if opts.Watch {
+		return watch(ctx, pool, kvd, iterOpts, opts)
+	}
+
+	dialogs, err := collectDialogs(ctx, opts.From, opts.Desc)
+	if err != nil {
+		return errors.Wrap(err, "collect dialogs")
+	}
+
+	rec := newRecord(ctx, kvd, fingerprint(dialogs, opts.To, opts.Mode))
+	resumed := false
+	if !opts.Restart {
+		// resume forward and ask user to continue
+		if resumed, err = resume(ctx, rec, dialogs, !opts.Continue); err != nil {
+			return err
+		}
+	} else {
+		color.Yellow("Restart forward by 'restart' flag")
+	}
+
+	var skip func(from int64, msg int) bool
+	total := totalMessages(dialogs)
+	if resumed {
+		skip = rec.finished
+		total -= rec.count(dialogs)
+	}
+
+	defer func() {
+		if rerr != nil || opts.DryRun {
+			return
+		}
+		// clear progress only if all messages are forwarded, so that failed ones can be retried by 'continue'
+		if left := totalMessages(dialogs) - rec.count(dialogs); left > 0 {
+			color.Yellow("%d messages are not forwarded, run again with '--continue' to retry them", left)
+			return
+		}
+		multierr.AppendInto(&rerr, rec.clear(dialogs))
+	}()
+
+	iterOpts.dialogs, iterOpts.skip = dialogs, skip
+	if opts.Dedup {
+		iterOpts.dedup = newDedup(kvd, pool, rec, opts.DedupScan, opts.DryRun)
+		defer func() {
+			if n := iterOpts.dedup.skipped.Load(); n > 0 {
+				color.Yellow("%d duplicated messages are skipped", n)
+			}
+		}()
+	}
+
 	fwProgress := prog.New(pw.FormatNumber)
-	fwProgress.SetNumTrackersExpected(totalMessages(dialogs))
+	fwProgress.SetNumTrackersExpected(total)
 	prog.EnablePS(ctx, fwProgress)
 
+	var progress forwarder.Progress = newProgress(fwProgress)
+	if iterOpts.dedup != nil {
+		progress = iterOpts.dedup.wrap(progress)
+	}
+
 	fw := forwarder.New(forwarder.Options{
-		Pool: pool,
-		Iter: newIter(iterOptions{
-			manager: manager,
-			pool:    pool,
-			to:      to,
-			edit:    edit,
-			dialogs: dialogs,
-			mode:    opts.Mode,
-			silent:  opts.Silent,
-			dryRun:  opts.DryRun,
-			grouped: !opts.Single,
-			delay:   viper.GetDuration(consts.FlagDelay),
-		}),
-		Progress: newProgress(fwProgress),
+		Pool:     pool,
+		Iter:     newIter(iterOpts),
+		Progress: progress,
+		Recorder: rec,
 		Threads:  viper.GetInt(consts.FlagThreads),
+		Limit:    viper.GetInt(consts.FlagLimit),
 	})
 
 	go fwProgress.Render()
@@ -146,7 +268,7 @@ func collectDialogs(ctx context.Context, input []string, desc bool) ([]*tmessage
 func resolveDest(ctx context.Context, manager *peers.Manager, input string) (*vm.Program, error) {
 	compile := func(i string) (*vm.Program, error) {
 		// we pass empty peer and message to enable type checking
-		return expr.Compile(i, expr.Env(exprEnv(nil, nil)))
+		return expr.Compile(i, expr.Env(exprEnv(nil, nil, nil)))
 	}
 
 	// default
@@ -173,7 +295,7 @@ func resolveDest(ctx context.Context, manager *peers.Manager, input string) (*vm
 func resolveEdit(input string) (*vm.Program, error) {
 	compile := func(i string) (*vm.Program, error) {
 		// we pass empty peer and message to enable type checking
-		return expr.Compile(i, expr.Env(exprEnv(nil, nil)), expr.AsKind(reflect.String))
+		return expr.Compile(i, expr.Env(exprEnv(nil, nil, nil)), expr.AsKind(reflect.String))
 	}
 
 	// no edit, nil program
@@ -190,6 +312,18 @@ func resolveEdit(input string) (*vm.Program, error) {
 	return compile(input)
 }
 
+func parseEntityTypes(input []string) ([]textutil.EntityType, error) {
+	types := make([]textutil.EntityType, 0, len(input))
+	for _, t := range input {
+		et, err := textutil.ParseEntityType(t)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, et)
+	}
+	return types, nil
+}
+
 func totalMessages(dialogs []*tmessage.Dialog) int {
 	var total int
 	for _, d := range dialogs {
@@ -2,19 +2,21 @@ package forward
 
 import (
 	"context"
-	"strings"
+	"fmt"
 	"time"
 
 	"github.com/expr-lang/expr/vm"
 	"github.com/go-faster/errors"
 	"github.com/gotd/td/telegram/message/entity"
-	"github.com/gotd/td/telegram/message/html"
 	"github.com/gotd/td/telegram/peers"
 	"github.com/gotd/td/tg"
 	"github.com/mitchellh/mapstructure"
+	"go.uber.org/zap"
 
 	"github.com/lshcx/tdl/core/dcpool"
 	"github.com/lshcx/tdl/core/forwarder"
+	"github.com/lshcx/tdl/core/logctx"
+	"github.com/lshcx/tdl/core/util/textutil"
 	"github.com/lshcx/tdl/core/util/tutil"
 	"github.com/lshcx/tdl/pkg/texpr"
 	"github.com/lshcx/tdl/pkg/tmessage"
@@ -25,16 +27,31 @@ type iterOptions struct {
 	pool    dcpool.Pool
 	to      *vm.Program
 	edit    *vm.Program
+	parse   textutil.ParseMode
 	dialogs []*tmessage.Dialog
 	mode    forwarder.Mode
 	silent  bool
 	dryRun  bool
 	grouped bool
 	delay   time.Duration
+	skip    func(from int64, msg int) bool // skip messages which are already forwarded, nil means no skip
+
+	dropAuthor   bool
+	dropCaption  bool
+	stripMarkup  bool
+	dropEntities []textutil.EntityType
+
+	scheduleStart    time.Time // zero means no schedule
+	scheduleInterval time.Duration
+	sendAs           peers.Peer // nil means sending as self
+
+	transforms []transformer
+	dedup      *dedup // nil means no dedup
 }
 
 type iter struct {
-	opts iterOptions
+	opts  iterOptions
+	sched *scheduler
 
 	i, j int
 	elem forwarder.Elem
@@ -47,10 +64,20 @@ type env struct {
 		Username    string `comment:"Username of dialog"`
 		VisibleName string `comment:"Title of channel and group, first and last name of user"`
 	}
+	Sender struct {
+		ID          int64  `comment:"ID of sender, which is the dialog itself if message has no sender, e.g. channel post"`
+		Username    string `comment:"Username of sender"`
+		FirstName   string `comment:"First name of sender if it's a user"`
+		LastName    string `comment:"Last name of sender if it's a user"`
+		VisibleName string `comment:"Title of channel and group, first and last name of user"`
+		Bot         bool   `comment:"Whether sender is a bot"`
+	}
+	Link    string `comment:"Link of the message, empty if dialog is not a channel or group"`
 	Message texpr.EnvMessage
 }
 
-func exprEnv(from peers.Peer, msg *tg.Message) env {
+// exprEnv builds expression env, sender is the peer who sends the message and can be nil if it's unknown
+func exprEnv(from, sender peers.Peer, msg *tg.Message) env {
 	e := env{}
 
 	if from != nil {
@@ -59,21 +86,53 @@ func exprEnv(from peers.Peer, msg *tg.Message) env {
 		e.From.VisibleName = from.VisibleName()
 	}
 
+	if sender != nil {
+		e.Sender.ID = sender.ID()
+		e.Sender.Username, _ = sender.Username()
+		e.Sender.VisibleName = sender.VisibleName()
+		if u, ok := sender.(peers.User); ok {
+			e.Sender.FirstName, _ = u.FirstName()
+			e.Sender.LastName, _ = u.LastName()
+			e.Sender.Bot = u.Raw().Bot
+		}
+	}
+
 	if msg != nil {
 		e.Message = texpr.ConvertEnvMessage(msg)
+
+		if sender == nil {
+			e.Sender.ID = e.Message.FromID
+		}
+		if ch, ok := from.(peers.Channel); ok {
+			e.Link = messageLink(ch, msg.ID)
+		}
 	}
 
 	return e
 }
 
+// messageLink returns public link if channel has username, otherwise private link which only works for members
+func messageLink(ch peers.Channel, msg int) string {
+	if username, ok := ch.Username(); ok {
+		return fmt.Sprintf("https://t.me/%s/%d", username, msg)
+	}
+	return fmt.Sprintf("https://t.me/c/%d/%d", ch.ID(), msg)
+}
+
 type dest struct {
-	Peer   string
-	Thread int
+	Peer     string
+	Thread   int
+	Schedule int    // unix time to send scheduled message, overrides schedule flags
+	SendAs   string // chat to send as, overrides send-as flag
 }
 
 func newIter(opts iterOptions) *iter {
 	return &iter{
 		opts: opts,
+		sched: &scheduler{
+			start:    opts.scheduleStart,
+			interval: opts.scheduleInterval,
+		},
 
 		i:    0,
 		j:    0,
@@ -90,45 +149,79 @@ func (i *iter) Next(ctx context.Context) bool {
 	default:
 	}
 
-	// end of iteration or error occurred
-	if i.i >= len(i.opts.dialogs) || i.err != nil {
-		return false
-	}
+	for {
+		p, m, ok := i.pick()
+		if !ok {
+			return false
+		}
 
-	// if delay is set, sleep for a while for each iteration
-	if i.opts.delay > 0 && (i.i+i.j) > 0 { // skip first delay
-		time.Sleep(i.opts.delay)
-	}
+		// if delay is set, sleep for a while for each iteration
+		if i.opts.delay > 0 && i.elem != nil { // skip first delay
+			time.Sleep(i.opts.delay)
+		}
 
-	p, m := i.opts.dialogs[i.i].Peer, i.opts.dialogs[i.i].Messages[i.j]
+		from, err := i.opts.manager.FromInputPeer(ctx, p)
+		if err != nil {
+			i.err = errors.Wrap(err, "get from peer")
+			return false
+		}
 
-	if i.j++; i.j >= len(i.opts.dialogs[i.i].Messages) {
-		i.i++
-		i.j = 0
-	}
+		msg, err := tutil.GetSingleMessage(ctx, i.opts.pool.Default(ctx), from.InputPeer(), m)
+		if err != nil {
+			i.err = errors.Wrapf(err, "get message: %d", m)
+			return false
+		}
 
-	from, err := i.opts.manager.FromInputPeer(ctx, p)
-	if err != nil {
-		i.err = errors.Wrap(err, "get from peer")
-		return false
+		elem, err := i.resolve(ctx, from, msg)
+		if err != nil {
+			i.err = err
+			return false
+		}
+		if elem == nil { // duplicated
+			continue
+		}
+
+		i.elem = elem
+		return true
 	}
+}
 
-	msg, err := tutil.GetSingleMessage(ctx, i.opts.pool.Default(ctx), from.InputPeer(), m)
-	if err != nil {
-		i.err = errors.Wrapf(err, "get message: %d", m)
-		return false
+// pick returns the next message which should be forwarded
+func (i *iter) pick() (tg.InputPeerClass, int, bool) {
+	for {
+		// end of iteration or error occurred
+		if i.i >= len(i.opts.dialogs) || i.err != nil {
+			return nil, 0, false
+		}
+
+		p, m := i.opts.dialogs[i.i].Peer, i.opts.dialogs[i.i].Messages[i.j]
+
+		if i.j++; i.j >= len(i.opts.dialogs[i.i].Messages) {
+			i.i++
+			i.j = 0
+		}
+
+		if i.opts.skip == nil || !i.opts.skip(tutil.GetInputPeerID(p), m) {
+			return p, m, true
+		}
 	}
+}
+
+// resolve routes the message to destination and edits it by expressions. It returns nil elem if
+// the message is duplicated in destination.
+func (i *iter) resolve(ctx context.Context, from peers.Peer, msg *tg.Message) (*iterElem, error) {
+	e := i.env(ctx, from, msg)
 
 	// message routing
-	result, err := texpr.Run(i.opts.to, exprEnv(from, msg))
+	result, err := texpr.Run(i.opts.to, e)
 	if err != nil {
-		i.err = errors.Wrap(err, "message routing")
-		return false
+		return nil, errors.Wrap(err, "message routing")
 	}
 
 	var (
 		to     peers.Peer
 		thread int
+		d      dest
 	)
 
 	switch r := result.(type) {
@@ -138,65 +231,155 @@ func (i *iter) Next(ctx context.Context) bool {
 		to, err = i.resolvePeer(ctx, r)
 	case map[string]interface{}:
 		// chat with reply to topic or message
-		var d dest
-
 		if err = mapstructure.WeakDecode(r, &d); err != nil {
-			i.err = errors.Wrapf(err, "decode dest: %v", result)
-			return false
+			return nil, errors.Wrapf(err, "decode dest: %v", result)
 		}
 
 		to, err = i.resolvePeer(ctx, d.Peer)
 		thread = d.Thread
 	default:
-		i.err = errors.Errorf("message router must return string or dest: %T", result)
-		return false
+		return nil, errors.Errorf("message router must return string or dest: %T", result)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "resolve dest: %v", result)
+	}
+
+	sendAs := i.opts.sendAs
+	if d.SendAs != "" {
+		if sendAs, err = tutil.GetInputPeer(ctx, i.opts.manager, d.SendAs); err != nil {
+			return nil, errors.Wrapf(err, "resolve send as: %s", d.SendAs)
+		}
 	}
 
 	var modeOverride forwarder.Mode = -1 // default value is invalid
-	// edit message
-	if i.opts.edit != nil {
-		result, err = texpr.Run(i.opts.edit, exprEnv(from, msg))
+	edited, err := i.edit(e, msg)
+	if err != nil {
+		return nil, err
+	}
+	transformed := i.transform(msg)
+	if stripped := i.strip(msg); edited || transformed || stripped {
+		// direct mode can't modify message content, so we force it to be clone mode
+		modeOverride = forwarder.ModeClone
+	}
+
+	// compare the content which will be sent, album is compared when grouped messages are fetched
+	var reserved []string
+	if _, album := msg.GetGroupedID(); i.opts.dedup != nil && !(album && i.opts.grouped) {
+		dup, fp, err := i.opts.dedup.duplicated(ctx, from, msg, to)
 		if err != nil {
-			i.err = errors.Wrap(err, "edit message")
-			return false
+			return nil, errors.Wrap(err, "dedup")
 		}
-
-		r, ok := result.(string)
-		if !ok {
-			i.err = errors.Errorf("edit must return string: %T", result)
-			return false
+		if dup {
+			logctx.From(ctx).Debug("Skip duplicated message",
+				zap.Int64("from", from.ID()),
+				zap.Int("msg", msg.ID),
+				zap.Int64("to", to.ID()))
+			return nil, nil
 		}
-
-		eb := entity.Builder{}
-		if err = html.HTML(strings.NewReader(r), &eb, html.Options{
-			UserResolver:          nil,
-			DisableTelegramEscape: false,
-		}); err != nil {
-			i.err = errors.Wrap(err, "parse edited message")
-			return false
+		if fp != "" {
+			reserved = append(reserved, fp)
 		}
-
-		// modify message
-		msg.Message, msg.Entities = eb.Raw()
-		// direct mode can't modify message content, so we force it to be clone mode
-		modeOverride = forwarder.ModeClone
 	}
 
-	if err != nil {
-		i.err = errors.Wrapf(err, "resolve dest: %v", result)
-		return false
+	groupedID := int64(0)
+	if i.opts.grouped {
+		groupedID = msg.GroupedID
+	}
+	schedule := i.sched.next(groupedID)
+	if d.Schedule != 0 {
+		schedule = d.Schedule
 	}
 
-	i.elem = &iterElem{
+	return &iterElem{
 		from:         from,
 		msg:          msg,
 		to:           to,
 		thread:       thread,
+		schedule:     schedule,
+		sendAs:       sendAs,
 		modeOverride: modeOverride,
 		opts:         i.opts,
+		iter:         i,
+		reserved:     reserved,
+	}, nil
+}
+
+// edit modifies message by edit expression, and reports whether the message is edited
+func (i *iter) edit(e env, msg *tg.Message) (bool, error) {
+	if i.opts.edit == nil {
+		return false, nil
+	}
+
+	result, err := texpr.Run(i.opts.edit, e)
+	if err != nil {
+		return false, errors.Wrap(err, "edit message")
+	}
+
+	r, ok := result.(string)
+	if !ok {
+		return false, errors.Errorf("edit must return string: %T", result)
+	}
+
+	eb := entity.Builder{}
+	if err = textutil.Parse(i.opts.parse, r, &eb); err != nil {
+		return false, errors.Wrap(err, "parse edited message")
+	}
+
+	// modify message
+	msg.Message, msg.Entities = eb.Raw()
+	return true, nil
+}
+
+// transform applies transform chain to text of the message, and reports whether the message is changed
+func (i *iter) transform(msg *tg.Message) bool {
+	changed := false
+	for _, t := range i.opts.transforms {
+		var c bool
+		msg.Message, msg.Entities, c = t(msg.Message, msg.Entities)
+		changed = changed || c
+	}
+	return changed
+}
+
+// strip removes reply markup and filtered entities, and reports whether the message is changed
+func (i *iter) strip(msg *tg.Message) bool {
+	changed := false
+	if i.opts.stripMarkup && msg.ReplyMarkup != nil {
+		msg.ReplyMarkup = nil
+		changed = true
+	}
+
+	var filtered bool
+	msg.Message, msg.Entities, filtered = textutil.FilterEntities(msg.Message, msg.Entities, i.opts.dropEntities)
+
+	return changed || filtered
+}
+
+// env resolves sender of the message and builds expression env
+func (i *iter) env(ctx context.Context, from peers.Peer, msg *tg.Message) env {
+	sender := from // message without sender is sent by dialog itself
+	_, private := from.(peers.User)
+	switch {
+	case msg.FromID == nil && msg.Out && private:
+		self, err := i.opts.manager.Self(ctx)
+		if err != nil {
+			logctx.From(ctx).Debug("Get self", zap.Error(err))
+			sender = nil
+		} else {
+			sender = self
+		}
+	case msg.FromID != nil:
+		var err error
+		if sender, err = i.opts.manager.ResolvePeer(ctx, msg.FromID); err != nil {
+			// access hash of sender may be unknown, only ID is available
+			logctx.From(ctx).Debug("Resolve sender",
+				zap.Int64("sender", tutil.GetPeerID(msg.FromID)),
+				zap.Error(err))
+			sender = nil
+		}
 	}
 
-	return true
+	return exprEnv(from, sender, msg)
 }
 
 func (i *iter) resolvePeer(ctx context.Context, peer string) (peers.Peer, error) {
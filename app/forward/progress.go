@@ -3,6 +3,7 @@ package forward
 import (
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/fatih/color"
 	pw "github.com/jedib0t/go-pretty/v6/progress"
@@ -15,7 +16,8 @@ import (
 
 type progress struct {
 	pw       pw.Writer
-	trackers map[tuple]*pw.Tracker // TODO(iyear): concurrent map
+	mu       sync.Mutex // messages are forwarded concurrently
+	trackers map[tuple]*pw.Tracker
 	elemName map[int64]string
 }
 
@@ -35,11 +37,14 @@ func newProgress(p pw.Writer) *progress {
 
 func (p *progress) OnAdd(elem forwarder.Elem) {
 	tracker := prog.AppendTracker(p.pw, pw.FormatNumber, p.processMessage(elem, false), 1)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.trackers[p.tuple(elem)] = tracker
 }
 
 func (p *progress) OnClone(elem forwarder.Elem, state forwarder.ProgressState) {
-	tracker, ok := p.trackers[p.tuple(elem)]
+	tracker, ok := p.getTracker(elem)
 	if !ok {
 		return
 	}
@@ -52,7 +57,7 @@ func (p *progress) OnClone(elem forwarder.Elem, state forwarder.ProgressState) {
 }
 
 func (p *progress) OnDone(elem forwarder.Elem, err error) {
-	tracker, ok := p.trackers[p.tuple(elem)]
+	tracker, ok := p.getTracker(elem)
 	if !ok {
 		return
 	}
@@ -69,6 +74,14 @@ func (p *progress) OnDone(elem forwarder.Elem, err error) {
 	tracker.MarkAsDone()
 }
 
+func (p *progress) getTracker(elem forwarder.Elem) (*pw.Tracker, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tracker, ok := p.trackers[p.tuple(elem)]
+	return tracker, ok
+}
+
 func (p *progress) tuple(elem forwarder.Elem) tuple {
 	return tuple{
 		from: elem.From().ID(),
@@ -89,6 +102,9 @@ func (p *progress) processMessage(elem forwarder.Elem, clone bool) string {
 }
 
 func (p *progress) metaString(elem forwarder.Elem) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	// TODO(iyear): better responsive name
 	if _, ok := p.elemName[elem.From().ID()]; !ok {
 		p.elemName[elem.From().ID()] = runewidth.Truncate(elem.From().VisibleName(), 15, "...")
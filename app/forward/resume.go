@@ -0,0 +1,231 @@
+package forward
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/fatih/color"
+	"github.com/go-faster/errors"
+	"go.uber.org/zap"
+
+	"github.com/lshcx/tdl/core/forwarder"
+	"github.com/lshcx/tdl/core/logctx"
+	"github.com/lshcx/tdl/core/storage"
+	"github.com/lshcx/tdl/core/util/tutil"
+	"github.com/lshcx/tdl/pkg/key"
+	"github.com/lshcx/tdl/pkg/tmessage"
+)
+
+// record persists forwarded messages of the job and the mapping of source to destination messages.
+// Each message is saved when it's sent, so that progress is kept even if the process is killed.
+// Progress of the job isn't saved if fingerprint is empty, e.g. in watch mode.
+type record struct {
+	ctx         context.Context
+	kvd         storage.Storage
+	fingerprint string
+}
+
+func newRecord(ctx context.Context, kvd storage.Storage, fingerprint string) *record {
+	return &record{ctx: ctx, kvd: kvd, fingerprint: fingerprint}
+}
+
+// OnSent implements forwarder.Recorder
+func (r *record) OnSent(elem forwarder.Elem, ids map[int]int) {
+	from, to := elem.From().ID(), elem.To().ID()
+
+	for src, dst := range ids {
+		if r.fingerprint != "" {
+			if err := r.kvd.Set(r.ctx, key.ForwardResume(r.fingerprint, from, src), []byte(strconv.Itoa(dst))); err != nil {
+				logctx.From(r.ctx).Error("Save forward progress", zap.Int64("from", from), zap.Int("msg", src), zap.Error(err))
+			}
+		}
+
+		if dst == 0 {
+			continue
+		}
+		if err := r.kvd.Set(r.ctx, key.ForwardMap(from, src, to), []byte(strconv.Itoa(dst))); err != nil {
+			logctx.From(r.ctx).Error("Save forward mapping", zap.Int64("from", from), zap.Int("msg", src), zap.Error(err))
+		}
+		if err := r.addTarget(from, src, target{To: to, ID: dst}); err != nil {
+			logctx.From(r.ctx).Error("Save forward targets", zap.Int64("from", from), zap.Int("msg", src), zap.Error(err))
+		}
+	}
+}
+
+// Lookup implements forwarder.Recorder
+func (r *record) Lookup(from int64, msg int, to int64) (int, bool) {
+	b, err := r.kvd.Get(r.ctx, key.ForwardMap(from, msg, to))
+	if err != nil {
+		return 0, false
+	}
+
+	dst, err := strconv.Atoi(string(b))
+	if err != nil || dst == 0 {
+		return 0, false
+	}
+	return dst, true
+}
+
+// target is a destination message of the source message
+type target struct {
+	To int64 `json:"to"`
+	ID int   `json:"id"`
+}
+
+// targets returns all destination messages of the source message
+func (r *record) targets(from int64, msg int) ([]target, error) {
+	b, err := r.kvd.Get(r.ctx, key.ForwardTargets(from, msg))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var t []target
+	if err = json.Unmarshal(b, &t); err != nil {
+		return nil, errors.Wrap(err, "unmarshal targets")
+	}
+	return t, nil
+}
+
+func (r *record) addTarget(from int64, msg int, t target) error {
+	ts, err := r.targets(from, msg)
+	if err != nil {
+		return err
+	}
+
+	for i, old := range ts {
+		// message is forwarded to the same peer again, only the latest one is kept
+		if old.To == t.To {
+			ts = append(ts[:i], ts[i+1:]...)
+			break
+		}
+	}
+
+	return r.setTargets(from, msg, append(ts, t))
+}
+
+// setTargets saves targets of the source message, and deletes the key if targets are empty
+func (r *record) setTargets(from int64, msg int, ts []target) error {
+	if len(ts) == 0 {
+		err := r.kvd.Delete(r.ctx, key.ForwardTargets(from, msg))
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		return nil
+	}
+
+	b, err := json.Marshal(ts)
+	if err != nil {
+		return errors.Wrap(err, "marshal targets")
+	}
+	return r.kvd.Set(r.ctx, key.ForwardTargets(from, msg), b)
+}
+
+// forget deletes mapping of the source message, which is deleted
+func (r *record) forget(from int64, msg int, ts []target) error {
+	for _, t := range ts {
+		err := r.kvd.Delete(r.ctx, key.ForwardMap(from, msg, t.To))
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+	}
+	return r.setTargets(from, msg, nil)
+}
+
+// skip marks the message as finished without sending it
+func (r *record) skip(from int64, msg int) error {
+	if r.fingerprint == "" {
+		return nil
+	}
+	return r.kvd.Set(r.ctx, key.ForwardResume(r.fingerprint, from, msg), []byte("0"))
+}
+
+// finished reports whether the message is forwarded in the job
+func (r *record) finished(from int64, msg int) bool {
+	_, err := r.kvd.Get(r.ctx, key.ForwardResume(r.fingerprint, from, msg))
+	return err == nil
+}
+
+// count returns number of forwarded messages of dialogs in the job
+func (r *record) count(dialogs []*tmessage.Dialog) int {
+	n := 0
+	for _, d := range dialogs {
+		from := tutil.GetInputPeerID(d.Peer)
+		for _, m := range d.Messages {
+			if r.finished(from, m) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// clear deletes progress of the job, mapping of messages is kept
+func (r *record) clear(dialogs []*tmessage.Dialog) error {
+	for _, d := range dialogs {
+		from := tutil.GetInputPeerID(d.Peer)
+		for _, m := range d.Messages {
+			err := r.kvd.Delete(r.ctx, key.ForwardResume(r.fingerprint, from, m))
+			if err != nil && !errors.Is(err, storage.ErrNotFound) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resume asks user to continue the unfinished job, and returns whether finished messages should be skipped
+func resume(ctx context.Context, r *record, dialogs []*tmessage.Dialog, ask bool) (bool, error) {
+	logctx.From(ctx).Debug("Check forward resume",
+		zap.String("fingerprint", r.fingerprint))
+
+	finished := r.count(dialogs)
+	if finished == 0 {
+		return false, nil
+	}
+
+	confirm := false
+	resumeStr := fmt.Sprintf("Found unfinished forward, continue from '%d/%d'", finished, totalMessages(dialogs))
+	if ask {
+		if err := survey.AskOne(&survey.Confirm{
+			Message: color.YellowString(resumeStr + "?"),
+		}, &confirm); err != nil {
+			return false, err
+		}
+	} else {
+		color.Yellow(resumeStr)
+		confirm = true
+	}
+
+	if !confirm {
+		return false, r.clear(dialogs)
+	}
+
+	return true, nil
+}
+
+// fingerprint identifies the forward job by sources, destination expression and mode
+func fingerprint(dialogs []*tmessage.Dialog, to string, mode forwarder.Mode) string {
+	endian := binary.BigEndian
+	buf, b := &bytes.Buffer{}, make([]byte, 8)
+	for _, m := range dialogs {
+		endian.PutUint64(b, uint64(tutil.GetInputPeerID(m.Peer)))
+		buf.Write(b)
+		for _, msg := range m.Messages {
+			endian.PutUint64(b, uint64(msg))
+			buf.Write(b)
+		}
+	}
+	buf.WriteString(to)
+	buf.WriteString(mode.String())
+
+	return fmt.Sprintf("%x", sha256.Sum256(buf.Bytes()))
+}
@@ -0,0 +1,67 @@
+package forward
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-faster/errors"
+)
+
+// scheduleLayouts are layouts of schedule time in local time zone, RFC3339 has its own zone
+var scheduleLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// parseSchedule parses time to send scheduled messages. It can be a time in scheduleLayouts,
+// a unix timestamp, or a duration from now with "+" prefix, e.g. "+1h30m". Empty means no schedule.
+func parseSchedule(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if d, ok := strings.CutPrefix(s, "+"); ok {
+		duration, err := time.ParseDuration(d)
+		if err != nil {
+			return time.Time{}, errors.Wrap(err, "parse duration")
+		}
+		return now.Add(duration), nil
+	}
+
+	if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(ts, 0), nil
+	}
+
+	for _, layout := range scheduleLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errors.Errorf("unknown schedule time: %s", s)
+}
+
+// scheduler spreads messages from start with interval, albums are counted as one message
+type scheduler struct {
+	start     time.Time
+	interval  time.Duration
+	n         int
+	lastGroup int64
+}
+
+// next returns schedule date of the message, 0 if messages are not scheduled
+func (s *scheduler) next(groupedID int64) int {
+	if s.start.IsZero() {
+		return 0
+	}
+
+	if groupedID == 0 || groupedID != s.lastGroup {
+		s.n++
+	}
+	s.lastGroup = groupedID
+
+	return int(s.start.Add(time.Duration(s.n-1) * s.interval).Unix())
+}
@@ -0,0 +1,72 @@
+package forward
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name string
+		s    string
+		want time.Time
+		err  bool
+	}{
+		{"empty", "", time.Time{}, false},
+		{"blank", "  ", time.Time{}, false},
+		{"duration", "+1h30m", now.Add(90 * time.Minute), false},
+		{"unix", "1714564800", time.Unix(1714564800, 0), false},
+		{"rfc3339", "2024-05-02T08:00:00Z", time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC), false},
+		{"seconds", "2024-05-02 08:00:30", time.Date(2024, 5, 2, 8, 0, 30, 0, time.Local), false},
+		{"minutes", "2024-05-02 08:00", time.Date(2024, 5, 2, 8, 0, 0, 0, time.Local), false},
+		{"bad duration", "+1x", time.Time{}, true},
+		{"unknown", "tomorrow", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSchedule(tt.s, now)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tt.want.Equal(got), "want %v, got %v", tt.want, got)
+		})
+	}
+}
+
+func TestSchedulerNext(t *testing.T) {
+	start := time.Unix(1714564800, 0)
+
+	tests := []struct {
+		name     string
+		start    time.Time
+		interval time.Duration
+		groups   []int64
+		want     []int
+	}{
+		{"no schedule", time.Time{}, time.Minute, []int64{0, 0}, []int{0, 0}},
+		{"no interval", start, 0, []int64{0, 0}, []int{1714564800, 1714564800}},
+		{"interval", start, time.Minute, []int64{0, 0, 0}, []int{1714564800, 1714564860, 1714564920}},
+		{"album", start, time.Minute, []int64{0, 7, 7, 7, 0}, []int{1714564800, 1714564860, 1714564860, 1714564860, 1714564920}},
+		{"adjacent albums", start, time.Minute, []int64{7, 7, 8, 8}, []int{1714564800, 1714564800, 1714564860, 1714564860}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &scheduler{start: tt.start, interval: tt.interval}
+
+			got := make([]int, 0, len(tt.groups))
+			for _, g := range tt.groups {
+				got = append(got, s.next(g))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package forward
+
+import (
+	"regexp"
+
+	"github.com/go-faster/errors"
+	"github.com/gotd/td/telegram/message/entity"
+	"github.com/gotd/td/tg"
+	"github.com/spf13/viper"
+
+	"github.com/lshcx/tdl/core/util/textutil"
+)
+
+//go:generate go-enum --values --names --flag --nocase
+
+// TransformType
+// ENUM(replace, links, mentions, convert, prepend, append)
+type TransformType int
+
+// transformConfig is a step of transform file, fields are used by the type:
+//
+//	replace:  Pattern, With   replace regexp matches of text, With can contain $1-like submatches
+//	links:    Pattern, With   replace URLs of links
+//	mentions:                 remove @username mentions
+//	convert:  From, To        convert entities of From style to To style, To is plain by default
+//	prepend:  Text, Sep       add styled Text with parse mode before the text
+//	append:   Text, Sep       add styled Text with parse mode after the text
+type transformConfig struct {
+	Type    string
+	Pattern string
+	With    string
+	From    string
+	To      string
+	Text    string
+	Sep     *string // "\n\n" by default
+}
+
+// transformer modifies text and entities of the message, and reports whether they are changed
+type transformer func(text string, entities []tg.MessageEntityClass) (string, []tg.MessageEntityClass, bool)
+
+// loadTransforms reads transform steps from the file, which can be any format supported by viper.
+// It returns nil if path is empty.
+func loadTransforms(path string, parse textutil.ParseMode) ([]transformer, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, errors.Wrap(err, "read transform file")
+	}
+
+	var configs []transformConfig
+	if err := v.UnmarshalKey("transforms", &configs); err != nil {
+		return nil, errors.Wrap(err, "decode transform file")
+	}
+
+	transforms := make([]transformer, 0, len(configs))
+	for i, c := range configs {
+		t, err := c.build(parse)
+		if err != nil {
+			return nil, errors.Wrapf(err, "transform %d", i)
+		}
+		transforms = append(transforms, t)
+	}
+	return transforms, nil
+}
+
+func (c transformConfig) build(parse textutil.ParseMode) (transformer, error) {
+	typ, err := ParseTransformType(c.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	switch typ {
+	case TransformTypeReplace, TransformTypeLinks:
+		re, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, errors.Wrap(err, "compile pattern")
+		}
+		if typ == TransformTypeLinks {
+			return func(text string, entities []tg.MessageEntityClass) (string, []tg.MessageEntityClass, bool) {
+				return textutil.RewriteLinks(text, entities, re, c.With)
+			}, nil
+		}
+		return func(text string, entities []tg.MessageEntityClass) (string, []tg.MessageEntityClass, bool) {
+			return textutil.ReplaceRegexp(text, entities, re, c.With)
+		}, nil
+	case TransformTypeMentions:
+		drop := []textutil.EntityType{textutil.EntityTypeMention}
+		return func(text string, entities []tg.MessageEntityClass) (string, []tg.MessageEntityClass, bool) {
+			return textutil.FilterEntities(text, entities, drop)
+		}, nil
+	case TransformTypeConvert:
+		from, err := textutil.ParseStyle(c.From)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse from style")
+		}
+		to := textutil.StylePlain
+		if c.To != "" {
+			if to, err = textutil.ParseStyle(c.To); err != nil {
+				return nil, errors.Wrap(err, "parse to style")
+			}
+		}
+		if from == textutil.StylePlain || to == textutil.StyleTextUrl {
+			return nil, errors.Errorf("can't convert %s to %s", from, to)
+		}
+
+		return func(text string, entities []tg.MessageEntityClass) (string, []tg.MessageEntityClass, bool) {
+			entities, changed := textutil.ConvertEntities(entities, from, to)
+			return text, entities, changed
+		}, nil
+	case TransformTypePrepend, TransformTypeAppend:
+		eb := entity.Builder{}
+		if err = textutil.Parse(parse, c.Text, &eb); err != nil {
+			return nil, errors.Wrap(err, "parse text")
+		}
+		add, addEntities := eb.Raw()
+		if add == "" {
+			return nil, errors.New("text is empty")
+		}
+
+		sep := "\n\n"
+		if c.Sep != nil {
+			sep = *c.Sep
+		}
+
+		if typ == TransformTypePrepend {
+			return func(text string, entities []tg.MessageEntityClass) (string, []tg.MessageEntityClass, bool) {
+				text, entities = textutil.Concat(add, addEntities, text, entities, sep)
+				return text, entities, true
+			}, nil
+		}
+		return func(text string, entities []tg.MessageEntityClass) (string, []tg.MessageEntityClass, bool) {
+			text, entities = textutil.Concat(text, entities, add, addEntities, sep)
+			return text, entities, true
+		}, nil
+	}
+
+	return nil, errors.Errorf("unsupported transform type: %s", typ)
+}
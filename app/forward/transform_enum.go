@@ -0,0 +1,127 @@
+// Code generated by go-enum DO NOT EDIT.
+// Version: 0.5.8
+// Revision: 3d844c8ecc59661ed7aa17bfd65727bc06a60ad8
+// Build Date: 2023-09-18T14:55:21Z
+// Built By: goreleaser
+
+package forward
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// TransformTypeReplace is a TransformType of type Replace.
+	TransformTypeReplace TransformType = iota
+	// TransformTypeLinks is a TransformType of type Links.
+	TransformTypeLinks
+	// TransformTypeMentions is a TransformType of type Mentions.
+	TransformTypeMentions
+	// TransformTypeConvert is a TransformType of type Convert.
+	TransformTypeConvert
+	// TransformTypePrepend is a TransformType of type Prepend.
+	TransformTypePrepend
+	// TransformTypeAppend is a TransformType of type Append.
+	TransformTypeAppend
+)
+
+var ErrInvalidTransformType = fmt.Errorf("not a valid TransformType, try [%s]", strings.Join(_TransformTypeNames, ", "))
+
+const _TransformTypeName = "replacelinksmentionsconvertprependappend"
+
+var _TransformTypeNames = []string{
+	_TransformTypeName[0:7],
+	_TransformTypeName[7:12],
+	_TransformTypeName[12:20],
+	_TransformTypeName[20:27],
+	_TransformTypeName[27:34],
+	_TransformTypeName[34:40],
+}
+
+// TransformTypeNames returns a list of possible string values of TransformType.
+func TransformTypeNames() []string {
+	tmp := make([]string, len(_TransformTypeNames))
+	copy(tmp, _TransformTypeNames)
+	return tmp
+}
+
+// TransformTypeValues returns a list of the values for TransformType
+func TransformTypeValues() []TransformType {
+	return []TransformType{
+		TransformTypeReplace,
+		TransformTypeLinks,
+		TransformTypeMentions,
+		TransformTypeConvert,
+		TransformTypePrepend,
+		TransformTypeAppend,
+	}
+}
+
+var _TransformTypeMap = map[TransformType]string{
+	TransformTypeReplace:  _TransformTypeName[0:7],
+	TransformTypeLinks:    _TransformTypeName[7:12],
+	TransformTypeMentions: _TransformTypeName[12:20],
+	TransformTypeConvert:  _TransformTypeName[20:27],
+	TransformTypePrepend:  _TransformTypeName[27:34],
+	TransformTypeAppend:   _TransformTypeName[34:40],
+}
+
+// String implements the Stringer interface.
+func (x TransformType) String() string {
+	if str, ok := _TransformTypeMap[x]; ok {
+		return str
+	}
+	return fmt.Sprintf("TransformType(%d)", x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x TransformType) IsValid() bool {
+	_, ok := _TransformTypeMap[x]
+	return ok
+}
+
+var _TransformTypeValue = map[string]TransformType{
+	_TransformTypeName[0:7]:                    TransformTypeReplace,
+	strings.ToLower(_TransformTypeName[0:7]):   TransformTypeReplace,
+	_TransformTypeName[7:12]:                   TransformTypeLinks,
+	strings.ToLower(_TransformTypeName[7:12]):  TransformTypeLinks,
+	_TransformTypeName[12:20]:                  TransformTypeMentions,
+	strings.ToLower(_TransformTypeName[12:20]): TransformTypeMentions,
+	_TransformTypeName[20:27]:                  TransformTypeConvert,
+	strings.ToLower(_TransformTypeName[20:27]): TransformTypeConvert,
+	_TransformTypeName[27:34]:                  TransformTypePrepend,
+	strings.ToLower(_TransformTypeName[27:34]): TransformTypePrepend,
+	_TransformTypeName[34:40]:                  TransformTypeAppend,
+	strings.ToLower(_TransformTypeName[34:40]): TransformTypeAppend,
+}
+
+// ParseTransformType attempts to convert a string to a TransformType.
+func ParseTransformType(name string) (TransformType, error) {
+	if x, ok := _TransformTypeValue[name]; ok {
+		return x, nil
+	}
+	// Case insensitive parse, do a separate lookup to prevent unnecessary cost of lowercasing a string if we don't need to.
+	if x, ok := _TransformTypeValue[strings.ToLower(name)]; ok {
+		return x, nil
+	}
+	return TransformType(0), fmt.Errorf("%s is %w", name, ErrInvalidTransformType)
+}
+
+// Set implements the Golang flag.Value interface func.
+func (x *TransformType) Set(val string) error {
+	v, err := ParseTransformType(val)
+	*x = v
+	return err
+}
+
+// Get implements the Golang flag.Getter interface func.
+func (x *TransformType) Get() interface{} {
+	return *x
+}
+
+// Type implements the github.com/spf13/pFlag Value interface.
+func (x *TransformType) Type() string {
+	return "TransformType"
+}
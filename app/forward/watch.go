@@ -0,0 +1,441 @@
+package forward
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/go-faster/errors"
+	"github.com/gotd/td/telegram/peers"
+	"github.com/gotd/td/telegram/updates"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	pw "github.com/jedib0t/go-pretty/v6/progress"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/lshcx/tdl/core/dcpool"
+	"github.com/lshcx/tdl/core/forwarder"
+	"github.com/lshcx/tdl/core/logctx"
+	"github.com/lshcx/tdl/core/storage"
+	"github.com/lshcx/tdl/core/util/tutil"
+	"github.com/lshcx/tdl/pkg/consts"
+	"github.com/lshcx/tdl/pkg/prog"
+)
+
+// albumWait is the time to wait for the rest of album, which is received in several updates
+const albumWait = 2 * time.Second
+
+// maxProduced is the max number of produced messages whose updates haven't been received
+const maxProduced = 4096
+
+// Updates receives updates of the client in watch mode, it must be set as update handler of the client
+type Updates struct {
+	dispatcher tg.UpdateDispatcher
+	gaps       *updates.Manager
+}
+
+func NewUpdates(ctx context.Context) *Updates {
+	d := tg.NewUpdateDispatcher()
+	return &Updates{
+		dispatcher: d,
+		gaps: updates.New(updates.Config{
+			Handler: d,
+			Logger:  logctx.From(ctx).Named("updates"),
+		}),
+	}
+}
+
+// Handle implements telegram.UpdateHandler
+func (u *Updates) Handle(ctx context.Context, us tg.UpdatesClass) error {
+	return u.gaps.Handle(ctx, us)
+}
+
+// peerMsg identifies message in peer
+type peerMsg struct {
+	peer int64
+	msg  int
+}
+
+// watcher mirrors new messages of source chats, and propagates edits and deletions to mirrored messages
+type watcher struct {
+	*iter
+
+	client  *tg.Client
+	rec     *record
+	sources map[int64]peers.Peer
+	msgs    chan *tg.Message
+
+	lastGroup int64 // grouped ID of the last message, only the first message of album waits for the rest
+
+	mu       sync.Mutex
+	produced map[peerMsg]struct{} // messages sent by watcher, which are ignored if destination is also a source
+	order    []peerMsg            // produced messages in sending order, the oldest is evicted if exceeding maxProduced
+}
+
+func newWatcher(it *iter, client *tg.Client, rec *record, sources []peers.Peer) *watcher {
+	w := &watcher{
+		iter:     it,
+		client:   client,
+		rec:      rec,
+		sources:  make(map[int64]peers.Peer, len(sources)),
+		msgs:     make(chan *tg.Message, 256),
+		produced: make(map[peerMsg]struct{}),
+	}
+	for _, s := range sources {
+		w.sources[s.ID()] = s
+	}
+
+	return w
+}
+
+// register adds handlers of new, edited and deleted messages to the dispatcher
+func (w *watcher) register(d tg.UpdateDispatcher) {
+	d.OnNewChannelMessage(func(ctx context.Context, _ tg.Entities, u *tg.UpdateNewChannelMessage) error {
+		return w.onNew(ctx, u.Message)
+	})
+	d.OnNewMessage(func(ctx context.Context, _ tg.Entities, u *tg.UpdateNewMessage) error {
+		return w.onNew(ctx, u.Message)
+	})
+	d.OnEditChannelMessage(func(ctx context.Context, _ tg.Entities, u *tg.UpdateEditChannelMessage) error {
+		w.onEdit(ctx, u.Message)
+		return nil
+	})
+	d.OnEditMessage(func(ctx context.Context, _ tg.Entities, u *tg.UpdateEditMessage) error {
+		w.onEdit(ctx, u.Message)
+		return nil
+	})
+	d.OnDeleteChannelMessages(func(ctx context.Context, _ tg.Entities, u *tg.UpdateDeleteChannelMessages) error {
+		if from, ok := w.sources[u.ChannelID]; ok {
+			w.onDelete(ctx, from, u.Messages)
+		}
+		return nil
+	})
+	d.OnDeleteMessages(func(ctx context.Context, _ tg.Entities, u *tg.UpdateDeleteMessages) error {
+		// peer is unknown, so check all sources which are not channels
+		for _, from := range w.sources {
+			if _, ok := from.(peers.Channel); !ok {
+				w.onDelete(ctx, from, u.Messages)
+			}
+		}
+		return nil
+	})
+}
+
+// source returns the source peer of the message, nil if the message isn't from watched chats
+func (w *watcher) source(msg tg.MessageClass) (peers.Peer, *tg.Message) {
+	m, ok := msg.(*tg.Message)
+	if !ok {
+		return nil, nil
+	}
+
+	from, ok := w.sources[tutil.GetPeerID(m.PeerID)]
+	if !ok {
+		return nil, nil
+	}
+	return from, m
+}
+
+func (w *watcher) onNew(ctx context.Context, msg tg.MessageClass) error {
+	from, m := w.source(msg)
+	if from == nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	key := peerMsg{peer: from.ID(), msg: m.ID}
+	_, produced := w.produced[key]
+	delete(w.produced, key) // update of the message is received only once
+	w.mu.Unlock()
+	if produced {
+		return nil
+	}
+
+	// already mirrored, e.g. the update is received again after restart
+	if ts, err := w.rec.targets(from.ID(), m.ID); err == nil && len(ts) > 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case w.msgs <- m:
+		return nil
+	}
+}
+
+func (w *watcher) onEdit(ctx context.Context, msg tg.MessageClass) {
+	from, m := w.source(msg)
+	if from == nil {
+		return
+	}
+	log := logctx.From(ctx).With(zap.Int64("from", from.ID()), zap.Int("msg", m.ID))
+
+	ts, err := w.rec.targets(from.ID(), m.ID)
+	if err != nil {
+		log.Error("Get forward targets", zap.Error(err))
+		return
+	}
+	if len(ts) == 0 {
+		return
+	}
+
+	if _, err = w.edit(w.env(ctx, from, m), m); err != nil {
+		log.Warn("Edit message", zap.Error(err))
+		return
+	}
+	w.transform(m)
+	w.strip(m)
+
+	// caption isn't mirrored
+	if _, preview := m.Media.(*tg.MessageMediaWebPage); w.opts.dropCaption && m.Media != nil && !preview {
+		return
+	}
+
+	for _, t := range ts {
+		if w.opts.dryRun {
+			fmt.Printf("Edit message %d in %d by %d/%d\n", t.ID, t.To, from.ID(), m.ID)
+			continue
+		}
+
+		to, err := w.resolvePeer(ctx, strconv.FormatInt(t.To, 10))
+		if err != nil {
+			log.Warn("Resolve target peer", zap.Int64("to", t.To), zap.Error(err))
+			continue
+		}
+
+		_, err = w.client.MessagesEditMessage(ctx, &tg.MessagesEditMessageRequest{
+			Peer:     to.InputPeer(),
+			ID:       t.ID,
+			Message:  m.Message,
+			Entities: m.Entities,
+		})
+		// forwarded messages can't be edited, and only media can be changed without text
+		if err != nil && !tgerr.Is(err, tg.ErrMessageNotModified) {
+			log.Warn("Edit target message", zap.Int64("to", t.To), zap.Int("id", t.ID), zap.Error(err))
+		}
+	}
+}
+
+func (w *watcher) onDelete(ctx context.Context, from peers.Peer, msgs []int) {
+	log := logctx.From(ctx).With(zap.Int64("from", from.ID()))
+
+	// destination peer -> message IDs
+	deleted := make(map[int64][]int)
+	for _, m := range msgs {
+		ts, err := w.rec.targets(from.ID(), m)
+		if err != nil {
+			log.Error("Get forward targets", zap.Int("msg", m), zap.Error(err))
+			continue
+		}
+		for _, t := range ts {
+			deleted[t.To] = append(deleted[t.To], t.ID)
+		}
+
+		if !w.opts.dryRun && len(ts) > 0 {
+			if err = w.rec.forget(from.ID(), m, ts); err != nil {
+				log.Error("Delete forward mapping", zap.Int("msg", m), zap.Error(err))
+			}
+		}
+	}
+
+	for peer, ids := range deleted {
+		if w.opts.dryRun {
+			fmt.Printf("Delete messages %v in %d\n", ids, peer)
+			continue
+		}
+
+		to, err := w.resolvePeer(ctx, strconv.FormatInt(peer, 10))
+		if err != nil {
+			log.Warn("Resolve target peer", zap.Int64("to", peer), zap.Error(err))
+			continue
+		}
+
+		if ch, ok := to.(peers.Channel); ok {
+			_, err = w.client.ChannelsDeleteMessages(ctx, &tg.ChannelsDeleteMessagesRequest{
+				Channel: ch.InputChannel(),
+				ID:      ids,
+			})
+		} else {
+			_, err = w.client.MessagesDeleteMessages(ctx, &tg.MessagesDeleteMessagesRequest{
+				Revoke: true,
+				ID:     ids,
+			})
+		}
+		if err != nil {
+			log.Warn("Delete target messages", zap.Int64("to", peer), zap.Ints("ids", ids), zap.Error(err))
+		}
+	}
+}
+
+// OnSent implements forwarder.Recorder
+func (w *watcher) OnSent(elem forwarder.Elem, ids map[int]int) {
+	// only messages sent to watched chats can be received again
+	if _, ok := w.sources[elem.To().ID()]; ok {
+		w.mu.Lock()
+		for _, dst := range ids {
+			if dst != 0 {
+				w.produce(peerMsg{peer: elem.To().ID(), msg: dst})
+			}
+		}
+		w.mu.Unlock()
+	}
+
+	w.rec.OnSent(elem, ids)
+}
+
+// produce adds the message to produced set, and evicts the oldest ones whose updates are never received.
+// It must be called with mu held.
+func (w *watcher) produce(key peerMsg) {
+	w.produced[key] = struct{}{}
+	w.order = append(w.order, key)
+
+	// entries are deleted from map when their updates are received, so compact order with it
+	if len(w.order) > 2*len(w.produced)+64 {
+		order := make([]peerMsg, 0, len(w.produced))
+		for _, k := range w.order {
+			if _, ok := w.produced[k]; ok {
+				order = append(order, k)
+			}
+		}
+		w.order = order
+	}
+
+	for len(w.order) > maxProduced {
+		delete(w.produced, w.order[0])
+		w.order = w.order[1:]
+	}
+}
+
+// Lookup implements forwarder.Recorder
+func (w *watcher) Lookup(from int64, msg int, to int64) (int, bool) {
+	return w.rec.Lookup(from, msg, to)
+}
+
+// Next implements forwarder.Iter, it blocks until new message is received or context is canceled
+func (w *watcher) Next(ctx context.Context) bool {
+	for {
+		var msg *tg.Message
+		select {
+		case <-ctx.Done():
+			w.err = ctx.Err()
+			return false
+		case msg = <-w.msgs:
+		}
+
+		if group, ok := msg.GetGroupedID(); ok && group != w.lastGroup {
+			w.lastGroup = group
+			select {
+			case <-ctx.Done():
+				w.err = ctx.Err()
+				return false
+			case <-time.After(albumWait):
+			}
+		}
+
+		if w.opts.delay > 0 && w.elem != nil { // skip first delay
+			time.Sleep(w.opts.delay)
+		}
+
+		elem, err := w.resolve(ctx, w.sources[tutil.GetPeerID(msg.PeerID)], msg)
+		if err != nil {
+			// one bad message shouldn't stop watching
+			logctx.From(ctx).Warn("Resolve message",
+				zap.Int64("from", tutil.GetPeerID(msg.PeerID)),
+				zap.Int("msg", msg.ID),
+				zap.Error(err))
+			continue
+		}
+		if elem == nil { // duplicated
+			continue
+		}
+
+		w.elem = elem
+		return true
+	}
+}
+
+// run receives updates until context is canceled
+func (w *watcher) run(ctx context.Context, u *Updates, self int64) error {
+	w.register(u.dispatcher)
+
+	return u.gaps.Run(ctx, w.client, self, updates.AuthOptions{
+		OnStart: func(ctx context.Context) {
+			color.Green("Watching %d chats for new messages, press Ctrl+C to stop", len(w.sources))
+		},
+	})
+}
+
+// collectSources resolves chats to be watched
+func collectSources(ctx context.Context, manager *peers.Manager, input []string) ([]peers.Peer, error) {
+	sources := make([]peers.Peer, 0, len(input))
+	for _, p := range input {
+		peer, err := tutil.GetInputPeer(ctx, manager, p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "resolve chat: %s", p)
+		}
+		sources = append(sources, peer)
+	}
+
+	if len(sources) == 0 {
+		return nil, errors.New("no chats to watch")
+	}
+	return sources, nil
+}
+
+// watch mirrors source chats until context is canceled
+func watch(ctx context.Context, pool dcpool.Pool, kvd storage.Storage, iterOpts iterOptions, opts Options) error {
+	if opts.Updates == nil {
+		return errors.New("update handler is required in watch mode")
+	}
+
+	sources, err := collectSources(ctx, iterOpts.manager, opts.From)
+	if err != nil {
+		return errors.Wrap(err, "collect sources")
+	}
+
+	self, err := iterOpts.manager.Self(ctx)
+	if err != nil {
+		return errors.Wrap(err, "get self")
+	}
+
+	fwProgress := prog.New(pw.FormatNumber)
+	prog.EnablePS(ctx, fwProgress)
+
+	rec := newRecord(ctx, kvd, "")
+	if opts.Dedup {
+		iterOpts.dedup = newDedup(kvd, pool, rec, opts.DedupScan, opts.DryRun)
+	}
+	w := newWatcher(newIter(iterOpts), pool.Default(ctx), rec, sources)
+
+	var progress forwarder.Progress = newProgress(fwProgress)
+	if iterOpts.dedup != nil {
+		progress = iterOpts.dedup.wrap(progress)
+	}
+
+	fw := forwarder.New(forwarder.Options{
+		Pool:     pool,
+		Iter:     w,
+		Progress: progress,
+		Recorder: w,
+		Threads:  viper.GetInt(consts.FlagThreads),
+		Limit:    viper.GetInt(consts.FlagLimit),
+	})
+
+	go fwProgress.Render()
+	defer prog.Wait(ctx, fwProgress)
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error { return w.run(gctx, opts.Updates, self.ID()) })
+	g.Go(func() error { return fw.Forward(gctx) })
+
+	// stopped by user
+	if err = g.Wait(); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
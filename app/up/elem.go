@@ -1,8 +1,8 @@
 package up
 
 import (
+	"io"
 	"os"
-	"path/filepath"
 
 	"github.com/go-faster/errors"
 	"github.com/gotd/td/telegram/peers"
@@ -12,12 +12,14 @@ import (
 )
 
 type uploaderFile struct {
-	*os.File
+	io.ReadSeekCloser
+	path string // local path or URL
+	name string
 	size int64
 }
 
 func (u *uploaderFile) Name() string {
-	return filepath.Base(u.File.Name())
+	return u.name
 }
 
 func (u *uploaderFile) Size() int64 {
@@ -25,18 +27,26 @@ func (u *uploaderFile) Size() int64 {
 }
 
 type iterElem struct {
-	file *uploaderFile
-	to   peers.Peer
+	file  *uploaderFile
+	to    peers.Peer
+	topic int
+	reply int
 
 	asPhoto  bool
 	remove   bool
 	thumb    string
 	caption  string
+	group    string
 	mime     string
 	duration float64
 	width    int
 	height   int
 	codec    string
+	mode     uploader.Mode
+	source   *splitSource // original file of the part which is removed after all parts are uploaded
+
+	title     string
+	performer string
 }
 
 func (e *iterElem) File() uploader.File {
@@ -54,14 +64,30 @@ func (e *iterElem) To() tg.InputPeerClass {
 	return e.to.InputPeer()
 }
 
+func (e *iterElem) Topic() int {
+	return e.topic
+}
+
+func (e *iterElem) Reply() int {
+	return e.reply
+}
+
 func (e *iterElem) AsPhoto() bool {
 	return e.asPhoto
 }
 
+func (e *iterElem) Mode() uploader.Mode {
+	return e.mode
+}
+
 func (e *iterElem) Caption() string {
 	return e.caption
 }
 
+func (e *iterElem) Group() string {
+	return e.group
+}
+
 func (e *iterElem) Mime() string {
 	return e.mime
 }
@@ -82,9 +108,17 @@ func (e *iterElem) Codec() string {
 	return e.codec
 }
 
+func (e *iterElem) Title() string {
+	return e.title
+}
+
+func (e *iterElem) Performer() string {
+	return e.performer
+}
+
 func (e *iterElem) DoRemove() error {
 	if e.remove {
-		if err := os.Remove(e.file.File.Name()); err != nil {
+		if err := os.Remove(e.file.path); err != nil {
 			return errors.Wrap(err, "remove file")
 		}
 
@@ -96,5 +130,11 @@ func (e *iterElem) DoRemove() error {
 		}
 	}
 
+	if e.source != nil {
+		if err := e.source.done(); err != nil {
+			return errors.Wrap(err, "remove original file")
+		}
+	}
+
 	return nil
 }
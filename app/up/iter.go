@@ -3,48 +3,189 @@ package up
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/expr-lang/expr/vm"
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/go-faster/errors"
 	"github.com/gotd/td/telegram/peers"
+	"github.com/mitchellh/mapstructure"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
 
+	"github.com/lshcx/tdl/core/logctx"
 	"github.com/lshcx/tdl/core/uploader"
 	"github.com/lshcx/tdl/core/util/fsutil"
 	"github.com/lshcx/tdl/core/util/mediautil"
+	"github.com/lshcx/tdl/pkg/texpr"
 )
 
 type file struct {
-	file    string
+	file    string // local path, or URL if remote
+	name    string // file name, base of file if empty
 	thumb   string
 	mime    string
 	caption string
+	group   string // album group key
 	size    int64
 	info    *mediautil.VideoInfo
+	audio   *mediautil.AudioInfo
+	width   int // size of image, video size is in info
+	height  int
+	mode    uploader.Mode
+	part    *part    // nil if file is not a split part
+	album   int      // 1-based album number of plan, 0 if file is sent as single message
+	temp    bool     // generated file which should be removed after uploading
+	photo   bool     // send as photo even if --photo is not set
+	source  *file    // video which the preview is generated from, nil if file is not a preview
+	notes   []string // actions planned in dry run
+	remote  bool     // file is a URL which is streamed when uploading
 }
 
-type iter struct {
+// base returns name of the file
+func (f *file) base() string {
+	if f.name != "" {
+		return f.name
+	}
+	return filepath.Base(f.file)
+}
+
+// origin returns the file which decides routing and grouping, previews follow their videos
+func (f *file) origin() *file {
+	if f.source != nil {
+		return f.source
+	}
+	return f
+}
+
+// part is a part of the file split by fsutil.SplitFile
+type part struct {
+	index  int // 1-based
+	total  int
+	name   string // base name of original file
+	mode   fsutil.SplitMode
+	source *splitSource // nil if original file is kept
+}
+
+// splitSource is the original file of parts, which is removed after all parts are uploaded
+type splitSource struct {
+	path string
+	left atomic.Int64
+}
+
+func newSplitSource(path string, parts int) *splitSource {
+	s := &splitSource{path: path}
+	s.left.Store(int64(parts))
+	return s
+}
+
+// done is called when a part is uploaded, it removes original file after the last one
+func (s *splitSource) done() error {
+	if s.left.Dec() != 0 {
+		return nil
+	}
+	return os.Remove(s.path)
+}
+
+type iterOptions struct {
+	manager   *peers.Manager
 	files     []*file
-	to        peers.Peer
+	to        peers.Peer  // used when router is nil
+	router    *vm.Program // route each file to different destinations
+	topic     int
+	reply     int
 	photo     bool
 	remove    bool
 	delay     time.Duration
 	thumbTime string
+	onSkip    func(f *file) // called when the file is skipped when uploading, optional
+}
+
+type iter struct {
+	opts  iterOptions
+	peers map[string]peers.Peer // resolved router destinations
 
 	cur  int
 	err  error
 	file uploader.Elem
 }
 
-func newIter(files []*file, to peers.Peer, photo, remove bool, delay time.Duration, thumbTime string) *iter {
+type env struct {
+	Path  string `comment:"Path of file"`
+	Dir   string `comment:"Directory of file"`
+	Name  string `comment:"Name of file"`
+	Ext   string `comment:"Extension of file, with dot"`
+	Size  int64  `comment:"File size. Unit: Byte"`
+	MIME  string `comment:"MIME type of file"`
+	Video struct {
+		Duration float64  `comment:"Video duration. Unit: Second"`
+		Width    int      `comment:"Video width"`
+		Height   int      `comment:"Video height"`
+		Codec    string   `comment:"Video codec"`
+		Rotation int      `comment:"Video rotation, clockwise degrees"`
+		HDR      bool     `comment:"Whether video is HDR (PQ or HLG)"`
+		Audio    []string `comment:"Languages of audio tracks, empty string if unknown"`
+		Subtitle []string `comment:"Languages of subtitle tracks, empty string if unknown"`
+	}
+	Audio struct {
+		Title     string  `comment:"Audio title from tags"`
+		Performer string  `comment:"Audio performer from tags"`
+		Duration  float64 `comment:"Audio duration. Unit: Second"`
+	}
+}
+
+func exprEnv(f *file) env {
+	e := env{}
+
+	if f != nil {
+		e.Path = f.file
+		e.Dir = filepath.Dir(f.file)
+		e.Name = f.base()
+		e.Ext = filepath.Ext(f.file)
+		e.Size = f.size
+		e.MIME = f.mime
+
+		if f.info != nil {
+			e.Video.Duration = f.info.Duration
+			e.Video.Width = f.info.Width
+			e.Video.Height = f.info.Height
+			e.Video.Codec = f.info.Codec
+			e.Video.Rotation = f.info.Rotation
+			e.Video.HDR = f.info.HDR
+			e.Video.Audio = streamLanguages(f.info.StreamsOf(mediautil.StreamAudio))
+			e.Video.Subtitle = streamLanguages(f.info.StreamsOf(mediautil.StreamSubtitle))
+		}
+
+		if f.audio != nil {
+			e.Audio.Title = f.audio.Title
+			e.Audio.Performer = f.audio.Performer
+			e.Audio.Duration = f.audio.Duration
+		}
+	}
+
+	return e
+}
+
+func streamLanguages(streams []mediautil.StreamInfo) []string {
+	langs := make([]string, 0, len(streams))
+	for _, s := range streams {
+		langs = append(langs, s.Language)
+	}
+	return langs
+}
+
+type dest struct {
+	Peer   string
+	Thread int
+}
+
+func newIter(opts iterOptions) *iter {
 	return &iter{
-		files:     files,
-		to:        to,
-		photo:     photo,
-		remove:    remove,
-		delay:     delay,
-		thumbTime: thumbTime,
+		opts:  opts,
+		peers: make(map[string]peers.Peer),
 
 		cur:  0,
 		err:  nil,
@@ -60,18 +201,36 @@ func (i *iter) Next(ctx context.Context) bool {
 	default:
 	}
 
-	if i.cur >= len(i.files) || i.err != nil {
-		return false
+	for i.cur < len(i.opts.files) && i.err == nil {
+		e, err := i.next(ctx)
+		if err != nil {
+			i.err = err
+			return false
+		}
+		if e != nil {
+			i.file = e
+			return true
+		}
 	}
 
+	return false
+}
+
+// next builds uploader elem of the next file. It returns nil elem if the file is skipped.
+func (i *iter) next(ctx context.Context) (uploader.Elem, error) {
 	// if delay is set, sleep for a while for each iteration
-	if i.delay > 0 && i.cur > 0 { // skip first delay
-		time.Sleep(i.delay)
+	if i.opts.delay > 0 && i.cur > 0 { // skip first delay
+		time.Sleep(i.opts.delay)
 	}
 
-	cur := i.files[i.cur]
+	cur := i.opts.files[i.cur]
 	i.cur++
 
+	to, topic, err := i.route(ctx, cur)
+	if err != nil {
+		return nil, errors.Wrapf(err, "route file: %s", cur.file)
+	}
+
 	// build thumbnail
 	thumb := ""
 	if cur.thumb != "" {
@@ -80,17 +239,24 @@ func (i *iter) Next(ctx context.Context) bool {
 			if vp != nil {
 
 				// get thumb time and transform to float64
-				thumbTimeF64, err := timeToFloat(i.thumbTime)
+				thumbTimeF64, err := timeToFloat(i.opts.thumbTime)
 				if err != nil {
 					thumbTimeF64 = 0
 				}
 
 				// generate thumbnail with specified time if the time is small than cur.info.Duration
-				if i.thumbTime != "" && cur.info != nil && cur.info.Duration > thumbTimeF64 {
-					vp.GenerateThumbnail(ctx, i.thumbTime, cur.file, cur.thumb)
+				if i.opts.thumbTime != "" && cur.info != nil && cur.info.Duration > thumbTimeF64 {
+					vp.GenerateThumbnail(ctx, i.opts.thumbTime, cur.file, cur.thumb)
 				} else {
 					vp.GenerateThumbnail(ctx, "00:00:01", cur.file, cur.thumb)
 				}
+			} else {
+				// without ffmpeg, use embedded cover art if has. Keyframes can't be decoded without ffmpeg.
+				if err := mediautil.ExtractCover(cur.file, cur.thumb); err != nil {
+					logctx.From(ctx).Debug("No thumbnail without ffmpeg",
+						zap.String("file", cur.file),
+						zap.Error(err))
+				}
 			}
 		}
 
@@ -100,33 +266,114 @@ func (i *iter) Next(ctx context.Context) bool {
 	}
 
 	// build uploader file
-	f, err := os.Open(cur.file)
+	var f io.ReadSeekCloser
+	if cur.remote {
+		if f, err = openRemote(ctx, cur.file, cur.size); err != nil {
+			// remote file may be gone or changed, which shouldn't stop uploading others
+			i.skip(ctx, cur, err)
+			return nil, nil
+		}
+	} else {
+		f, err = os.Open(cur.file)
+	}
 	if err != nil {
-		i.err = errors.Wrap(err, "open file")
-		return false
+		return nil, errors.Wrap(err, "open file")
 	}
-	file := &uploaderFile{File: f, size: cur.size}
+	file := &uploaderFile{ReadSeekCloser: f, path: cur.file, name: cur.base(), size: cur.size}
 
 	// build uploader elem
 	e := &iterElem{
 		file:    file,
 		thumb:   thumb,
-		to:      i.to,
-		asPhoto: i.photo,
-		remove:  i.remove,
+		to:      to,
+		topic:   topic,
+		reply:   i.opts.reply,
+		asPhoto: i.opts.photo || cur.photo,
+		remove:  (i.opts.remove && !cur.remote) || cur.temp,
 		caption: cur.caption,
+		group:   cur.group,
 		mime:    cur.mime,
+		mode:    cur.mode,
+	}
+	if cur.part != nil {
+		e.source = cur.part.source
 	}
 
+	e.width, e.height = cur.width, cur.height
 	if cur.info != nil {
 		e.duration = cur.info.Duration
 		e.width = cur.info.Width
 		e.height = cur.info.Height
 		e.codec = cur.info.Codec
 	}
-	i.file = e
+	if cur.audio != nil {
+		e.duration = cur.audio.Duration
+		e.title = cur.audio.Title
+		e.performer = cur.audio.Performer
+	}
+	return e, nil
+}
 
-	return true
+// skip drops the file which can't be uploaded. Caption of album is moved to the next file of the album.
+func (i *iter) skip(ctx context.Context, f *file, err error) {
+	logctx.From(ctx).Warn("Skip file",
+		zap.String("file", f.file),
+		zap.Error(err))
+
+	if i.cur < len(i.opts.files) {
+		if next := i.opts.files[i.cur]; f.album != 0 && next.album == f.album && next.caption == "" {
+			next.caption = f.caption
+		}
+	}
+
+	if i.opts.onSkip != nil {
+		i.opts.onSkip(f)
+	}
+}
+
+// route returns destination peer and topic of the file
+func (i *iter) route(ctx context.Context, f *file) (peers.Peer, int, error) {
+	if i.opts.router == nil {
+		return i.opts.to, i.opts.topic, nil
+	}
+
+	result, err := texpr.Run(i.opts.router, exprEnv(f.origin()))
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "file routing")
+	}
+
+	switch r := result.(type) {
+	case string:
+		// pure chat, no topic
+		to, err := i.resolvePeer(ctx, r)
+		return to, 0, err
+	case map[string]interface{}:
+		// chat with topic
+		var d dest
+
+		if err = mapstructure.WeakDecode(r, &d); err != nil {
+			return nil, 0, errors.Wrapf(err, "decode dest: %v", result)
+		}
+
+		to, err := i.resolvePeer(ctx, d.Peer)
+		return to, d.Thread, err
+	default:
+		return nil, 0, errors.Errorf("file router must return string or dest: %T", result)
+	}
+}
+
+func (i *iter) resolvePeer(ctx context.Context, peer string) (peers.Peer, error) {
+	if p, ok := i.peers[peer]; ok {
+		return p, nil
+	}
+
+	p, err := resolveDestPeer(ctx, i.opts.manager, peer)
+	if err != nil {
+		return nil, errors.Wrapf(err, "resolve dest: %s", peer)
+	}
+	i.peers[peer] = p
+
+	return p, nil
 }
 
 func (i *iter) Value() uploader.Elem {
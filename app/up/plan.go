@@ -0,0 +1,215 @@
+package up
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-faster/errors"
+	"github.com/mattn/go-runewidth"
+
+	"github.com/lshcx/tdl/core/uploader"
+	"github.com/lshcx/tdl/core/util/mediautil"
+	"github.com/lshcx/tdl/pkg/consts"
+	"github.com/lshcx/tdl/pkg/utils"
+)
+
+//go:generate go-enum --values --names --flag --nocase
+
+// PlanOutput
+// ENUM(table, json)
+type PlanOutput int
+
+// planEntry describes how a file will be uploaded
+type planEntry struct {
+	Path    string   `json:"path"`
+	Size    int64    `json:"size"`
+	MIME    string   `json:"mime"`
+	As      string   `json:"as"` // photo, video, audio, document, or upload mode
+	To      string   `json:"to"`
+	ToID    int64    `json:"to_id"`
+	Topic   int      `json:"topic,omitempty"`
+	Group   string   `json:"group,omitempty"`
+	Album   int      `json:"album,omitempty"` // 1-based album number, 0 if sent as single message
+	Thumb   string   `json:"thumb,omitempty"`
+	Caption string   `json:"caption,omitempty"`
+	Remove  bool     `json:"remove,omitempty"`
+	Notes   []string `json:"notes,omitempty"`
+}
+
+// buildPlan resolves destination, album and thumbnail of each file by the iterator, without uploading.
+// Caption isn't set, because it depends on albums.
+func buildPlan(ctx context.Context, it *iter, asAlbum bool, maxAlbumSize int) ([]*planEntry, error) {
+	entries := make([]*planEntry, 0, len(it.opts.files))
+	kinds := make([]string, 0, len(it.opts.files))
+
+	for _, f := range it.opts.files {
+		to, topic, err := it.route(ctx, f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "route file: %s", f.file)
+		}
+
+		as, kind := planKind(f, it.opts.photo || f.photo)
+		entries = append(entries, &planEntry{
+			Path:   f.file,
+			Size:   f.size,
+			MIME:   f.mime,
+			As:     as,
+			To:     to.VisibleName(),
+			ToID:   to.ID(),
+			Topic:  topic,
+			Group:  f.group,
+			Thumb:  planThumb(it, f),
+			Remove: it.opts.remove || f.temp,
+			Notes:  f.notes,
+		})
+		kinds = append(kinds, kind)
+	}
+
+	if asAlbum {
+		planAlbums(entries, kinds, maxAlbumSize)
+	}
+
+	return entries, nil
+}
+
+// planKind returns how the file is sent and its album kind, must be consistent with uploader
+func planKind(f *file, photo bool) (string, string) {
+	switch f.mode {
+	case uploader.ModeAuto:
+	case uploader.ModeDocument:
+		return f.mode.String(), "document"
+	default:
+		// voice, round video, animation and sticker can't be sent in album
+		return f.mode.String(), ""
+	}
+
+	switch {
+	case photo && mediautil.IsImage(f.mime) && f.mime != "image/webp":
+		return "photo", "media"
+	case mediautil.IsVideo(f.mime):
+		return "video", "media"
+	case mediautil.IsAudio(f.mime):
+		return "audio", "audio"
+	default:
+		return "document", "document"
+	}
+}
+
+// planAlbums numbers contiguous entries which are sent in the same album
+func planAlbums(entries []*planEntry, kinds []string, maxAlbumSize int) {
+	maxAlbumSize = max(maxAlbumSize, 1)
+
+	album := 0
+	for i := 0; i < len(entries); {
+		j := i + 1
+		for j < len(entries) && j-i < maxAlbumSize && kinds[i] != "" &&
+			kinds[j] == kinds[i] &&
+			entries[j].ToID == entries[i].ToID &&
+			entries[j].Topic == entries[i].Topic &&
+			entries[j].Group == entries[i].Group {
+			j++
+		}
+
+		// album with only one media is sent as single message
+		if j-i > 1 {
+			album++
+			for k := i; k < j; k++ {
+				entries[k].Album = album
+			}
+		}
+		i = j
+	}
+}
+
+// planGroups splits files into messages by albums of the plan, a file which isn't in album is a message itself
+func planGroups(files []*file, entries []*planEntry) [][]*file {
+	groups := make([][]*file, 0, len(files))
+	for i, f := range files {
+		if i == 0 || entries[i].Album == 0 || entries[i].Album != entries[i-1].Album {
+			groups = append(groups, make([]*file, 0, 1))
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], f)
+	}
+	return groups
+}
+
+// planThumb describes where the thumbnail comes from, see iter.Next
+func planThumb(it *iter, f *file) string {
+	if f.thumb == "" || !mediautil.IsVideo(f.mime) {
+		return ""
+	}
+	if it.validThumb(f.thumb) {
+		return f.thumb
+	}
+	if mediautil.GetVideoProcessor(consts.FFmpegPath) == nil {
+		return "embedded cover"
+	}
+
+	t := "00:00:01"
+	if thumbTime, err := timeToFloat(it.opts.thumbTime); err == nil && it.opts.thumbTime != "" && f.info != nil && f.info.Duration > thumbTime {
+		t = it.opts.thumbTime
+	}
+	return "frame at " + t
+}
+
+func printPlan(entries []*planEntry, output PlanOutput) error {
+	switch output {
+	case PlanOutputTable:
+		printPlanTable(entries)
+	case PlanOutputJson:
+		bytes, err := json.MarshalIndent(entries, "", "\t")
+		if err != nil {
+			return fmt.Errorf("marshal json: %w", err)
+		}
+
+		fmt.Println(string(bytes))
+	default:
+		return fmt.Errorf("unknown output: %s", output)
+	}
+
+	return nil
+}
+
+func printPlanTable(entries []*planEntry) {
+	fmt.Printf("%s %s %s %s %s %s %s\n",
+		trunc("File", 30),
+		trunc("Size", 10),
+		trunc("As", 9),
+		trunc("To", 20),
+		trunc("Album", 5),
+		trunc("Thumb", 18),
+		"Notes")
+
+	for _, e := range entries {
+		to := fmt.Sprintf("%s(%d)", e.To, e.ToID)
+		if e.Topic != 0 {
+			to += "#" + strconv.Itoa(e.Topic)
+		}
+		album := ""
+		if e.Album > 0 {
+			album = strconv.Itoa(e.Album)
+		}
+
+		fmt.Printf("%s %s %s %s %s %s %s\n",
+			trunc(filepath.Base(e.Path), 30),
+			trunc(utils.Byte.FormatBinaryBytes(e.Size), 10),
+			trunc(e.As, 9),
+			trunc(to, 20),
+			trunc(album, 5),
+			trunc(e.Thumb, 18),
+			strings.Join(e.Notes, "; "))
+	}
+}
+
+func trunc(s string, len int) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		s = "-"
+	}
+
+	return runewidth.FillRight(runewidth.Truncate(s, len, "..."), len)
+}
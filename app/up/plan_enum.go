@@ -0,0 +1,99 @@
+// Code generated by go-enum DO NOT EDIT.
+// Version: 0.5.8
+// Revision: 3d844c8ecc59661ed7aa17bfd65727bc06a60ad8
+// Build Date: 2023-09-18T14:55:21Z
+// Built By: goreleaser
+
+package up
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// PlanOutputTable is a PlanOutput of type Table.
+	PlanOutputTable PlanOutput = iota
+	// PlanOutputJson is a PlanOutput of type Json.
+	PlanOutputJson
+)
+
+var ErrInvalidPlanOutput = fmt.Errorf("not a valid PlanOutput, try [%s]", strings.Join(_PlanOutputNames, ", "))
+
+const _PlanOutputName = "tablejson"
+
+var _PlanOutputNames = []string{
+	_PlanOutputName[0:5],
+	_PlanOutputName[5:9],
+}
+
+// PlanOutputNames returns a list of possible string values of PlanOutput.
+func PlanOutputNames() []string {
+	tmp := make([]string, len(_PlanOutputNames))
+	copy(tmp, _PlanOutputNames)
+	return tmp
+}
+
+// PlanOutputValues returns a list of the values for PlanOutput
+func PlanOutputValues() []PlanOutput {
+	return []PlanOutput{
+		PlanOutputTable,
+		PlanOutputJson,
+	}
+}
+
+var _PlanOutputMap = map[PlanOutput]string{
+	PlanOutputTable: _PlanOutputName[0:5],
+	PlanOutputJson:  _PlanOutputName[5:9],
+}
+
+// String implements the Stringer interface.
+func (x PlanOutput) String() string {
+	if str, ok := _PlanOutputMap[x]; ok {
+		return str
+	}
+	return fmt.Sprintf("PlanOutput(%d)", x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x PlanOutput) IsValid() bool {
+	_, ok := _PlanOutputMap[x]
+	return ok
+}
+
+var _PlanOutputValue = map[string]PlanOutput{
+	_PlanOutputName[0:5]:                  PlanOutputTable,
+	strings.ToLower(_PlanOutputName[0:5]): PlanOutputTable,
+	_PlanOutputName[5:9]:                  PlanOutputJson,
+	strings.ToLower(_PlanOutputName[5:9]): PlanOutputJson,
+}
+
+// ParsePlanOutput attempts to convert a string to a PlanOutput.
+func ParsePlanOutput(name string) (PlanOutput, error) {
+	if x, ok := _PlanOutputValue[name]; ok {
+		return x, nil
+	}
+	// Case insensitive parse, do a separate lookup to prevent unnecessary cost of lowercasing a string if we don't need to.
+	if x, ok := _PlanOutputValue[strings.ToLower(name)]; ok {
+		return x, nil
+	}
+	return PlanOutput(0), fmt.Errorf("%s is %w", name, ErrInvalidPlanOutput)
+}
+
+// Set implements the Golang flag.Value interface func.
+func (x *PlanOutput) Set(val string) error {
+	v, err := ParsePlanOutput(val)
+	*x = v
+	return err
+}
+
+// Get implements the Golang flag.Getter interface func.
+func (x *PlanOutput) Get() interface{} {
+	return *x
+}
+
+// Type implements the github.com/spf13/pFlag Value interface.
+func (x *PlanOutput) Type() string {
+	return "PlanOutput"
+}
@@ -94,7 +94,7 @@ func (p *progress) fail(t *pw.Tracker, elem uploader.Elem, err error) {
 }
 
 func (p *progress) tuple(elem uploader.Elem) tuple {
-	return tuple{elem.(*iterElem).file.File.Name(), elem.(*iterElem).to.ID()}
+	return tuple{elem.(*iterElem).file.path, elem.(*iterElem).to.ID()}
 }
 
 func (p *progress) processMessage(elem uploader.Elem) string {
@@ -103,5 +103,5 @@ func (p *progress) processMessage(elem uploader.Elem) string {
 
 func (p *progress) elemString(elem uploader.Elem) string {
 	e := elem.(*iterElem)
-	return fmt.Sprintf("%s -> %s(%d)", e.file.File.Name(), e.to.VisibleName(), e.to.ID())
+	return fmt.Sprintf("%s -> %s(%d)", e.file.path, e.to.VisibleName(), e.to.ID())
 }
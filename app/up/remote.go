@@ -0,0 +1,184 @@
+package up
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/go-faster/errors"
+
+	"github.com/lshcx/tdl/core/util/mediautil"
+	"github.com/lshcx/tdl/pkg/consts"
+)
+
+// stdinPath reads file from stdin
+const stdinPath = "-"
+
+// sniffSize is the size of header used to detect MIME type of remote file
+const sniffSize = 3072
+
+func isURL(p string) bool {
+	return strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://")
+}
+
+func httpGet(ctx context.Context, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "new request")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "request")
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, errors.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return resp, nil
+}
+
+// remoteName returns file name from Content-Disposition, or the last segment of URL path
+func remoteName(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if name := filepath.Base(params["filename"]); params["filename"] != "" && name != "." && name != string(filepath.Separator) {
+			return name
+		}
+	}
+
+	// URL of the last request if redirected
+	if name := path.Base(resp.Request.URL.Path); name != "." && name != "/" {
+		return name
+	}
+
+	return "file"
+}
+
+// buildRemote builds file from HTTP(S) URL. The file is streamed when uploading if its size is known,
+// otherwise it's downloaded to a temporary file first.
+func buildRemote(ctx context.Context, u, work string, forceMp4 bool) (*file, error) {
+	resp, err := httpGet(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	name := remoteName(resp)
+	if resp.ContentLength <= 0 {
+		fmt.Printf("Download %s to temporary file because its size is unknown\n", u)
+		return stageFile(ctx, resp.Body, name, work, forceMp4)
+	}
+
+	header := make([]byte, sniffSize)
+	n, err := io.ReadFull(resp.Body, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, errors.Wrap(err, "read header")
+	}
+
+	f := &file{
+		file:   u,
+		name:   name,
+		size:   resp.ContentLength,
+		mime:   mimetype.Detect(header[:n]).String(),
+		remote: true,
+	}
+
+	// video info is probed by ffprobe, which can read URL
+	if mediautil.IsVideo(f.mime) {
+		if forceMp4 {
+			f.mime = "video/mp4"
+		}
+		if info, err := mediautil.ProbeVideo(ctx, consts.FFmpegPath, u); err == nil {
+			f.info = info
+		}
+	}
+
+	return f, nil
+}
+
+// stageFile writes r to a temporary file with the name in work dir, which is removed after uploading
+func stageFile(ctx context.Context, r io.Reader, name, work string, forceMp4 bool) (_ *file, rerr error) {
+	dir, err := os.MkdirTemp(work, "remote-")
+	if err != nil {
+		return nil, errors.Wrap(err, "create temp dir")
+	}
+	defer func() {
+		if rerr != nil {
+			_ = os.RemoveAll(dir)
+		}
+	}()
+
+	p := filepath.Join(dir, filepath.Base(name))
+	out, err := os.Create(p)
+	if err != nil {
+		return nil, errors.Wrap(err, "create temp file")
+	}
+	_, err = io.Copy(out, r)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "write temp file")
+	}
+
+	f, err := buildFile(ctx, p, forceMp4)
+	if err != nil {
+		return nil, err
+	}
+	f.temp = true
+	return f, nil
+}
+
+// remoteFile is a HTTP response body which can be uploaded. It only supports sequential reading.
+type remoteFile struct {
+	body   io.ReadCloser
+	offset int64
+}
+
+// openRemote requests the URL again when uploading, and checks that the size is not changed
+func openRemote(ctx context.Context, u string, size int64) (*remoteFile, error) {
+	resp, err := httpGet(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ContentLength != size {
+		_ = resp.Body.Close()
+		return nil, errors.Errorf("size of remote file changed from %d to %d", size, resp.ContentLength)
+	}
+
+	return &remoteFile{body: resp.Body}, nil
+}
+
+func (r *remoteFile) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+// Seek only supports getting current offset, and seeking to it
+func (r *remoteFile) Seek(offset int64, whence int) (int64, error) {
+	target := offset
+	switch whence {
+	case io.SeekCurrent:
+		target = r.offset + offset
+	case io.SeekEnd:
+		return 0, errors.New("seek from end is not supported by remote file")
+	}
+
+	if target != r.offset {
+		return 0, errors.Errorf("remote file can't seek from %d to %d", r.offset, target)
+	}
+	return r.offset, nil
+}
+
+func (r *remoteFile) Close() error {
+	return r.body.Close()
+}
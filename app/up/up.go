@@ -3,7 +3,10 @@ package up
 import (
 	"context"
 	"fmt"
+	"os"
 
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
 	"github.com/fatih/color"
 	"github.com/go-faster/errors"
 	"github.com/gotd/td/telegram"
@@ -16,9 +19,13 @@ import (
 	"github.com/lshcx/tdl/core/storage"
 	"github.com/lshcx/tdl/core/tclient"
 	"github.com/lshcx/tdl/core/uploader"
+	"github.com/lshcx/tdl/core/util/fsutil"
+	"github.com/lshcx/tdl/core/util/mediautil"
+	"github.com/lshcx/tdl/core/util/textutil"
 	"github.com/lshcx/tdl/core/util/tutil"
 	"github.com/lshcx/tdl/pkg/consts"
 	"github.com/lshcx/tdl/pkg/prog"
+	"github.com/lshcx/tdl/pkg/texpr"
 	"github.com/lshcx/tdl/pkg/utils"
 )
 
@@ -31,62 +38,194 @@ type Caption struct {
 
 type Options struct {
 	Chat         string
-	Paths        []string
+	To           string // router based on expression engine, overrides Chat and Topic
+	Topic        int
+	Reply        int
+	Paths        []string // local paths, HTTP(S) URLs, or '-' for stdin
+	StdinName    string   // file name of stdin
 	Excludes     []string
 	Remove       bool
 	Photo        bool
+	KeepGPS      bool // keep location in EXIF of photos
 	AsAlbum      bool
 	MaxAlbumSize int
+	Group        string // album group key based on expression engine
+	As           string // upload mode, can be a mode name or based on expression engine
 	ThumbTime    string
-	ForceMp4     bool
-	MaxFileSize  float64 // GB
+	ForceMp4     bool // remux videos to mp4, same as Transcode remux
+	Transcode    mediautil.TranscodeProfile
+	MaxHeight    int     // cap short side of transcoded video
+	MaxBitrate   int64   // kb/s, cap bitrate of transcoded video
+	MaxFileSize  float64 // GB, 0 means account limit
+	Split        fsutil.SplitMode
+	ContactSheet string  // grid of contact sheet of long videos, e.g. 4x4, empty to disable
+	PreviewClip  bool    // generate animated preview clip of long videos
+	PreviewMin   float64 // seconds, only videos not shorter than this have previews
 	Caption      Caption
+	ParseMode    textutil.ParseMode
+	DryRun       bool // print the plan without uploading
+	PlanOutput   PlanOutput
 }
 
 func Run(ctx context.Context, c *telegram.Client, kvd storage.Storage, opts Options) (rerr error) {
+	if opts.To == "-" {
+		fg := texpr.NewFieldsGetter(nil)
 
-	files, err := walk(ctx, opts.Paths, opts.Excludes, opts.ForceMp4)
+		fields, err := fg.Walk(exprEnv(nil))
+		if err != nil {
+			return fmt.Errorf("failed to walk fields: %w", err)
+		}
+
+		fmt.Print(fg.Sprint(fields, true))
+		return nil
+	}
+
+	if len(opts.Paths) == 0 {
+		return errors.New("at least one path is required")
+	}
+
+	pool := dcpool.NewPool(c,
+		int64(viper.GetInt(consts.FlagPoolSize)),
+		tclient.NewDefaultMiddlewares(ctx, viper.GetDuration(consts.FlagReconnectTimeout))...)
+	defer multierr.AppendInvoke(&rerr, multierr.Close(pool))
+
+	limit, err := uploader.GetLimit(ctx, pool.Default(ctx))
+	if err != nil {
+		return errors.Wrap(err, "get upload limit")
+	}
+	maxSize := resolveSizeLimit(limit, opts.MaxFileSize)
+	color.Blue("Max file size: %s", maxSize)
+
+	// generated files are written to work dir, which is removed after uploading
+	work, err := os.MkdirTemp("", "tdl-up-")
+	if err != nil {
+		return errors.Wrap(err, "create work dir")
+	}
+	defer func() { _ = os.RemoveAll(work) }()
+
+	files, err := walk(ctx, opts.Paths, opts.Excludes, work, opts.StdinName, opts.ForceMp4)
 	if err != nil {
 		return errors.Wrap(err, "walk")
 	}
 
-	files = filterFileSize(ctx, files, opts.MaxFileSize, opts.Remove, opts.ForceMp4)
+	profile := opts.Transcode
+	if opts.ForceMp4 && profile == mediautil.TranscodeProfileNone {
+		profile = mediautil.TranscodeProfileRemux
+	}
+	files = transcodeFiles(ctx, files, work, mediautil.TranscodeOptions{
+		Profile:    profile,
+		MaxHeight:  opts.MaxHeight,
+		MaxBitrate: opts.MaxBitrate,
+	}, opts.Remove, opts.DryRun)
 
-	if err := handleCaption(files, opts.AsAlbum, opts.Caption); err != nil {
-		return errors.Wrap(err, "handle caption")
+	columns, rows, err := parseGrid(opts.ContactSheet)
+	if err != nil {
+		return errors.Wrap(err, "parse contact sheet grid")
 	}
+	files = previewFiles(ctx, files, work, previewOptions{
+		columns:     columns,
+		rows:        rows,
+		clip:        opts.PreviewClip,
+		minDuration: opts.PreviewMin,
+	}, opts.DryRun)
+
+	files = filterFileSize(ctx, files, work, maxSize, opts.Remove, opts.ForceMp4, opts.Split, opts.DryRun)
 
-	// show files
-	for _, f := range files {
-		fmt.Printf("File: %s, Size: %d, Mime: %s, Thumb: %s, Info: %+v\n", f.file, f.size, f.mime, f.thumb, f.info)
+	mode, err := resolveMode(opts.As)
+	if err != nil {
+		return errors.Wrap(err, "resolve mode")
+	}
+	if err = modeFiles(files, mode); err != nil {
+		return errors.Wrap(err, "mode files")
 	}
 
-	color.Blue("Files count: %d", len(files))
+	files = photoFiles(ctx, files, work, opts.Photo, mediautil.PhotoOptions{KeepGPS: opts.KeepGPS}, opts.Remove, opts.DryRun)
 
-	pool := dcpool.NewPool(c,
-		int64(viper.GetInt(consts.FlagPoolSize)),
-		tclient.NewDefaultMiddlewares(ctx, viper.GetDuration(consts.FlagReconnectTimeout))...)
-	defer multierr.AppendInvoke(&rerr, multierr.Close(pool))
+	if opts.AsAlbum {
+		group, err := resolveGroup(opts.Group)
+		if err != nil {
+			return errors.Wrap(err, "resolve group")
+		}
+
+		if files, err = groupFiles(files, group); err != nil {
+			return errors.Wrap(err, "group files")
+		}
+	}
 
 	manager := peers.Options{Storage: storage.NewPeers(kvd)}.Build(pool.Default(ctx))
 
-	to, err := resolveDestPeer(ctx, manager, opts.Chat)
-	if err != nil {
+	var (
+		to     peers.Peer
+		router *vm.Program
+	)
+	if opts.To != "" {
+		if router, err = resolveRouter(ctx, manager, opts.To); err != nil {
+			return errors.Wrap(err, "resolve router")
+		}
+	} else if to, err = resolveDestPeer(ctx, manager, opts.Chat); err != nil {
 		return errors.Wrap(err, "get target peer")
 	}
 
+	it := newIter(iterOptions{
+		manager:   manager,
+		files:     files,
+		to:        to,
+		router:    router,
+		topic:     opts.Topic,
+		reply:     opts.Reply,
+		photo:     opts.Photo,
+		remove:    opts.Remove,
+		delay:     viper.GetDuration(consts.FlagDelay),
+		thumbTime: opts.ThumbTime,
+	})
+
+	plan, err := buildPlan(ctx, it, opts.AsAlbum, opts.MaxAlbumSize)
+	if err != nil {
+		return errors.Wrap(err, "build plan")
+	}
+
+	// albums are known after routing and splitting by media compatibility and size
+	var albums [][]*file
+	if opts.AsAlbum {
+		albums = planGroups(files, plan)
+	}
+	if err = handleCaption(files, albums, opts.Caption); err != nil {
+		return errors.Wrap(err, "handle caption")
+	}
+	for i, e := range plan {
+		e.Caption = files[i].caption
+		files[i].album = e.Album
+	}
+	if opts.DryRun {
+		return printPlan(plan, opts.PlanOutput)
+	}
+	color.Blue("Files count: %d", len(files))
+
 	upProgress := prog.New(utils.Byte.FormatBinaryBytes)
 	upProgress.SetNumTrackersExpected(len(files))
 	prog.EnablePS(ctx, upProgress)
 
+	// files may be skipped when uploading, e.g. remote file is gone
+	skipped := 0
+	it.opts.onSkip = func(_ *file) {
+		skipped++
+		upProgress.SetNumTrackersExpected(len(files) - skipped)
+	}
+	defer func() {
+		if skipped > 0 {
+			color.Yellow("%d files are skipped because they can't be opened, see log for details", skipped)
+		}
+	}()
+
 	options := uploader.Options{
 		Client:       pool.Default(ctx),
 		Threads:      viper.GetInt(consts.FlagThreads),
 		Limit:        viper.GetInt(consts.FlagLimit),
-		Iter:         newIter(files, to, opts.Photo, opts.Remove, viper.GetDuration(consts.FlagDelay), opts.ThumbTime),
+		Iter:         it,
 		Progress:     newProgress(upProgress),
 		AsAlbum:      opts.AsAlbum,
 		MaxAlbumSize: opts.MaxAlbumSize,
+		ParseMode:    opts.ParseMode,
 	}
 
 	up := uploader.New(options)
@@ -104,3 +243,73 @@ func resolveDestPeer(ctx context.Context, manager *peers.Manager, chat string) (
 
 	return tutil.GetInputPeer(ctx, manager, chat)
 }
+
+// resolveRouter parses the input string and returns a vm.Program. It can be a CHAT, a text or a file based on expression engine.
+func resolveRouter(ctx context.Context, manager *peers.Manager, input string) (*vm.Program, error) {
+	compile := func(i string) (*vm.Program, error) {
+		// we pass empty file to enable type checking
+		return expr.Compile(i, expr.Env(exprEnv(nil)))
+	}
+
+	// file
+	if exp, err := os.ReadFile(input); err == nil {
+		return compile(string(exp))
+	}
+
+	// chat
+	if _, err := tutil.GetInputPeer(ctx, manager, input); err == nil {
+		// convert to const string
+		return compile(fmt.Sprintf(`"%s"`, input))
+	}
+
+	// text
+	return compile(input)
+}
+
+// resolveGroup returns nil if input is empty, otherwise it returns a vm.Program. It can be a text or a file based on expression engine.
+func resolveGroup(input string) (*vm.Program, error) {
+	compile := func(i string) (*vm.Program, error) {
+		// we pass empty file to enable type checking
+		return expr.Compile(i, expr.Env(exprEnv(nil)))
+	}
+
+	// no group, nil program
+	if input == "" {
+		return nil, nil
+	}
+
+	// file
+	if exp, err := os.ReadFile(input); err == nil {
+		return compile(string(exp))
+	}
+
+	// text
+	return compile(input)
+}
+
+// resolveMode returns nil if input is empty, otherwise it returns a vm.Program. It can be a mode name, a text or a file based on expression engine.
+func resolveMode(input string) (*vm.Program, error) {
+	compile := func(i string) (*vm.Program, error) {
+		// we pass empty file to enable type checking
+		return expr.Compile(i, expr.Env(exprEnv(nil)))
+	}
+
+	// auto mode, nil program
+	if input == "" {
+		return nil, nil
+	}
+
+	// mode name
+	if _, err := uploader.ParseMode(input); err == nil {
+		// convert to const string
+		return compile(fmt.Sprintf(`"%s"`, input))
+	}
+
+	// file
+	if exp, err := os.ReadFile(input); err == nil {
+		return compile(string(exp))
+	}
+
+	// text
+	return compile(input)
+}
@@ -6,12 +6,19 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
+	"github.com/expr-lang/expr/vm"
 	"github.com/gabriel-vasile/mimetype"
+	"github.com/go-faster/errors"
 
+	"github.com/lshcx/tdl/core/uploader"
+	"github.com/lshcx/tdl/core/util/fsutil"
 	"github.com/lshcx/tdl/core/util/mediautil"
 	"github.com/lshcx/tdl/pkg/consts"
+	"github.com/lshcx/tdl/pkg/texpr"
+	"github.com/lshcx/tdl/pkg/utils"
 )
 
 type info struct {
@@ -25,7 +32,9 @@ type info struct {
 	videoDuration float64
 }
 
-func walk(ctx context.Context, paths, excludes []string, forceMp4 bool) ([]*file, error) {
+// walk builds files from paths. A path can be a local file or directory, a HTTP(S) URL, or '-' for stdin.
+// Remote files which must be downloaded first are written to work dir.
+func walk(ctx context.Context, paths, excludes []string, work, stdinName string, forceMp4 bool) ([]*file, error) {
 	files := make([]*file, 0)
 	excludesMap := map[string]struct{}{
 		consts.UploadThumbExt: {}, // ignore thumbnail files
@@ -36,6 +45,24 @@ func walk(ctx context.Context, paths, excludes []string, forceMp4 bool) ([]*file
 	}
 
 	for _, path := range paths {
+		switch {
+		case path == stdinPath:
+			f, err := stageFile(ctx, os.Stdin, stdinName, work, forceMp4)
+			if err != nil {
+				return nil, errors.Wrap(err, "read stdin")
+			}
+			files = append(files, f)
+			continue
+		case isURL(path):
+			f, err := buildRemote(ctx, path, work, forceMp4)
+			if err != nil {
+				fmt.Printf("Warning: Skip file %s because of error: %s \n", path, err)
+				continue
+			}
+			files = append(files, f)
+			continue
+		}
+
 		err := filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
 				return err
@@ -88,19 +115,24 @@ func buildFile(ctx context.Context, path string, forceMp4 bool) (*file, error) {
 		file.mime = "video/mp4"
 	}
 
-	if forceMp4 {
+	// video is remuxed to mp4 by transcoding, only MIME type is set here
+	if forceMp4 && mediautil.IsVideo(file.mime) {
 		file.mime = "video/mp4"
 	}
 
 	// get video info if is a video
 	if mediautil.IsVideo(file.mime) {
-		vp := mediautil.GetVideoProcessor(consts.FFmpegPath)
-		if vp != nil {
-			info, err := vp.GetVideoInfo(ctx, path)
-			if err != nil {
-				file.info = nil
-			}
-			file.info = info
+		info, err := mediautil.ProbeVideo(ctx, consts.FFmpegPath, path)
+		if err != nil {
+			info = nil
+		}
+		file.info = info
+	}
+
+	// get audio tags and duration if is an audio
+	if mediautil.IsAudio(file.mime) {
+		if info, err := mediautil.GetAudioInfo(path); err == nil {
+			file.audio = info
 		}
 	}
 
@@ -110,9 +142,13 @@ func buildFile(ctx context.Context, path string, forceMp4 bool) (*file, error) {
 func stats(files []*file) *info {
 	info := &info{}
 	for _, f := range files {
+		if f.source != nil {
+			continue
+		}
+
 		if mediautil.IsVideo(f.mime) {
 			info.videoNum++
-			info.videoSize += f.info.Size
+			info.videoSize += f.size
 			if f.info != nil {
 				info.videoDuration += f.info.Duration
 			}
@@ -128,7 +164,8 @@ func stats(files []*file) *info {
 	return info
 }
 
-func handleCaption(files []*file, asAlbum bool, optCaption Caption) error {
+// handleCaption sets caption of files. If albums isn't nil, each album gets its own caption on its first file.
+func handleCaption(files []*file, albums [][]*file, optCaption Caption) error {
 
 	if optCaption.NoCaption {
 		for _, f := range files {
@@ -147,60 +184,482 @@ func handleCaption(files []*file, asAlbum bool, optCaption Caption) error {
 		header += "\n"
 	}
 
-	caption := ""
-	if body == "" {
-		if asAlbum {
-			info := stats(files)
-			if info.imageNum > 0 {
-				body += fmt.Sprintf("【图片】%dP %.2fGB\n", info.imageNum, float64(info.imageSize)/1024/1024/1024)
-			}
-			if info.videoNum > 0 {
-				body += fmt.Sprintf("【视频】%dV %.2fGB\n", info.videoNum, float64(info.videoSize)/1024/1024/1024)
-				body += fmt.Sprintf("【时长】%.2f分钟\n", info.videoDuration/60)
+	if albums != nil {
+		// 每个相册单独生成标题，只设置在相册的第一个文件上
+		for _, group := range albums {
+			caption := header + body + footer
+			if body == "" {
+				caption = header + albumBody(group) + footer
 			}
-			if info.audioNum > 0 {
-				body += fmt.Sprintf("【音频】%dA\n", info.audioNum)
+
+			// 分卷说明只添加一次
+			parts := make(map[string]struct{})
+			for _, f := range group {
+				if f.part == nil {
+					continue
+				}
+				if _, ok := parts[f.part.name]; !ok {
+					parts[f.part.name] = struct{}{}
+					caption += partCaption(f.part)
+				}
 			}
-			if info.otherNum > 0 {
-				body += fmt.Sprintf("【其他】%d\n", info.otherNum)
+
+			for i, f := range group {
+				if i == 0 {
+					f.caption = caption
+				} else {
+					f.caption = ""
+				}
 			}
-			caption += header + body + footer
-		} else {
-			// base name
-			body += "【标题】%s\n%s"
-			caption += header + body + footer
 		}
+		return nil
+	}
+
+	caption := ""
+	if body == "" {
+		// base name
+		body += "【标题】%s\n%s"
+		caption += header + body + footer
 	} else {
 		caption = header + body + footer
 	}
 
-	if asAlbum {
-		for _, f := range files {
-			f.caption = caption
+	for _, f := range files {
+		if f.source != nil {
+			f.caption = header + fmt.Sprintf("【预览】%s\n", f.source.base()) + footer
+			continue
 		}
-	} else {
-		for _, f := range files {
-			if mediautil.IsVideo(f.mime) && f.info != nil {
-				tmpStr := ""
-				if f.info.Size > 0 {
-					tmpStr += fmt.Sprintf("【大小】%.2fMB\n", float64(f.info.Size)/1024/1024)
-				}
-				if f.info.Duration > 0 {
-					tmpStr += fmt.Sprintf("【时长】%.2f分钟\n", f.info.Duration/60)
+
+		if mediautil.IsVideo(f.mime) && f.info != nil {
+			tmpStr := ""
+			if f.info.Size > 0 {
+				tmpStr += fmt.Sprintf("【大小】%.2fMB\n", float64(f.info.Size)/1024/1024)
+			}
+			if f.info.Duration > 0 {
+				tmpStr += fmt.Sprintf("【时长】%.2f分钟\n", f.info.Duration/60)
+			}
+			tmpStr += streamsCaption(f.info)
+			f.caption = fmt.Sprintf(caption, f.base(), tmpStr)
+		} else {
+			f.caption = fmt.Sprintf(caption, f.base(), "")
+		}
+
+		if f.part != nil {
+			f.caption += partCaption(f.part)
+		}
+	}
+
+	return nil
+}
+
+// albumBody returns statistics of files as caption body
+func albumBody(files []*file) string {
+	body := ""
+	info := stats(files)
+	if info.imageNum > 0 {
+		body += fmt.Sprintf("【图片】%dP %.2fGB\n", info.imageNum, float64(info.imageSize)/1024/1024/1024)
+	}
+	if info.videoNum > 0 {
+		body += fmt.Sprintf("【视频】%dV %.2fGB\n", info.videoNum, float64(info.videoSize)/1024/1024/1024)
+		body += fmt.Sprintf("【时长】%.2f分钟\n", info.videoDuration/60)
+	}
+	if info.audioNum > 0 {
+		body += fmt.Sprintf("【音频】%dA\n", info.audioNum)
+	}
+	if info.otherNum > 0 {
+		body += fmt.Sprintf("【其他】%d\n", info.otherNum)
+	}
+	return body
+}
+
+// streamsCaption describes audio and subtitle tracks if there are multiple tracks or languages are known
+func streamsCaption(info *mediautil.VideoInfo) string {
+	describe := func(streams []mediautil.StreamInfo, withCodec bool) string {
+		items := make([]string, 0, len(streams))
+		known := false
+		for _, s := range streams {
+			item := s.Language
+			if withCodec {
+				item = s.Codec
+				if s.Language != "" {
+					item += "(" + s.Language + ")"
 				}
-				f.caption = fmt.Sprintf(caption, filepath.Base(f.file), tmpStr)
-			} else {
-				f.caption = fmt.Sprintf(caption, filepath.Base(f.file), "")
 			}
+			if item == "" {
+				item = "未知"
+			}
+			items = append(items, item)
+			known = known || s.Language != ""
 		}
+		if len(items) < 2 && !known {
+			return ""
+		}
+		return strings.Join(items, ", ")
+	}
+
+	caption := ""
+	if audio := describe(info.StreamsOf(mediautil.StreamAudio), true); audio != "" {
+		caption += fmt.Sprintf("【音轨】%s\n", audio)
+	}
+	if subtitle := describe(info.StreamsOf(mediautil.StreamSubtitle), false); subtitle != "" {
+		caption += fmt.Sprintf("【字幕】%s\n", subtitle)
+	}
+	return caption
+}
+
+// groupFiles evaluates album group key of each file, and reorders files to make the same group contiguous.
+// Order of groups is the order of their first file.
+func groupFiles(files []*file, group *vm.Program) ([]*file, error) {
+	if group == nil {
+		return files, nil
+	}
+
+	order := make(map[string]int)
+	for _, f := range files {
+		result, err := texpr.Run(group, exprEnv(f.origin()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "group file: %s", f.file)
+		}
+
+		f.group = fmt.Sprint(result)
+		if _, ok := order[f.group]; !ok {
+			order[f.group] = len(order)
+		}
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return order[files[i].group] < order[files[j].group]
+	})
+
+	return files, nil
+}
+
+// modeFiles evaluates upload mode of each file. Files which can't be sent in the mode fall back to auto.
+func modeFiles(files []*file, mode *vm.Program) error {
+	if mode == nil {
+		return nil
+	}
+
+	for _, f := range files {
+		// previews are always sent as photo or animation
+		if f.source != nil {
+			continue
+		}
+
+		result, err := texpr.Run(mode, exprEnv(f))
+		if err != nil {
+			return errors.Wrapf(err, "mode of file: %s", f.file)
+		}
+
+		m, err := uploader.ParseMode(fmt.Sprint(result))
+		if err != nil {
+			return errors.Wrapf(err, "mode of file: %s", f.file)
+		}
+
+		if reason, ok := modeCompatible(f, m); !ok {
+			fmt.Printf("Warning: Upload file %s in auto mode because %s\n", f.file, reason)
+			m = uploader.ModeAuto
+		}
+		f.mode = m
 	}
 
 	return nil
 }
 
-func filterFileSize(ctx context.Context, files []*file, maxFileSize float64, isRemove bool, forceMp4 bool) []*file {
+func modeCompatible(f *file, mode uploader.Mode) (string, bool) {
+	switch mode {
+	case uploader.ModeVoice:
+		if !mediautil.IsAudio(f.mime) {
+			return "voice note must be an audio", false
+		}
+	case uploader.ModeRound:
+		if !mediautil.IsVideo(f.mime) {
+			return "round video must be a video", false
+		}
+		// Telegram crops round video to a circle, so it's still sent but may look wrong
+		if f.info != nil && f.info.Width != f.info.Height {
+			fmt.Printf("Warning: Round video %s is not square (%dx%d)\n", f.file, f.info.Width, f.info.Height)
+		}
+	case uploader.ModeAnimation:
+		if !mediautil.IsVideo(f.mime) && f.mime != "image/gif" {
+			return "animation must be a video or gif", false
+		}
+	case uploader.ModeSticker:
+		switch {
+		case f.mime == "image/webp":
+			// Telegram needs image size to show webp as sticker
+			w, h, err := mediautil.WebPSize(f.file)
+			if err != nil {
+				return fmt.Sprintf("size of webp is unknown: %s", err), false
+			}
+			f.width, f.height = w, h
+		case f.mime == "video/webm", strings.HasSuffix(f.file, ".tgs"):
+		default:
+			return "sticker must be a webp, webm or tgs", false
+		}
+	}
+
+	return "", true
+}
+
+// transcodeFiles converts videos which can't be streamed in Telegram to mp4. Transcoded files are temporary and
+// written to work dir, which is removed after uploading.
+func transcodeFiles(ctx context.Context, files []*file, work string, opts mediautil.TranscodeOptions, isRemove, dryRun bool) []*file {
+	if opts.Profile == mediautil.TranscodeProfileNone {
+		return files
+	}
+
+	result := make([]*file, 0, len(files))
+	for _, f := range files {
+		plan, need := mediautil.PlanTranscode(f.info, opts)
+		if !mediautil.IsVideo(f.mime) || !need {
+			result = append(result, f)
+			continue
+		}
+
+		if dryRun {
+			f.notes = append(f.notes, fmt.Sprintf("transcode: %s", strings.Join(plan.Reasons, ", ")))
+			result = append(result, f)
+			continue
+		}
+
+		vp := mediautil.GetVideoProcessor(consts.FFmpegPath)
+		if vp == nil {
+			fmt.Printf("Warning: Upload file %s without transcoding because no video processor found\n", f.file)
+			result = append(result, f)
+			continue
+		}
+
+		dir, err := os.MkdirTemp(work, "transcode-")
+		if err != nil {
+			fmt.Printf("Warning: Upload file %s without transcoding because of error: %s\n", f.file, err)
+			result = append(result, f)
+			continue
+		}
+
+		fmt.Printf("Transcode video %s because %s\n", f.file, strings.Join(plan.Reasons, ", "))
+		o := opts
+		o.OutputPath = filepath.Join(dir, fsutil.GetNameWithoutExt(f.base())+".mp4")
+		if err = vp.Transcode(ctx, f.file, plan, o); err != nil {
+			fmt.Printf("Warning: Upload file %s without transcoding because of error: %s\n", f.file, err)
+			_ = os.RemoveAll(dir)
+			result = append(result, f)
+			continue
+		}
+
+		tf, err := buildFile(ctx, o.OutputPath, false)
+		if err != nil {
+			fmt.Printf("Warning: Skip file %s because of error: %s \n", o.OutputPath, err)
+			continue
+		}
+		tf.temp = true
+		result = append(result, tf)
+
+		if isRemove {
+			os.Remove(f.file)
+		}
+	}
+
+	return result
+}
+
+// photoFiles prepares images which are sent as photos, see mediautil.PreparePhoto.
+// Images which can't be sent as photos are uploaded as documents.
+func photoFiles(ctx context.Context, files []*file, work string, photo bool, opts mediautil.PhotoOptions, isRemove, dryRun bool) []*file {
+	// photos are prepared when uploading, which may convert or shrink them
+	if dryRun {
+		return files
+	}
+
+	// converted photos are removed with work dir after uploading
+	opts.OutputDir = work
+
+	result := make([]*file, 0, len(files))
+	for _, f := range files {
+		if !(photo || f.photo) || !mediautil.IsImage(f.mime) || f.mime == "image/webp" || f.mode != uploader.ModeAuto {
+			result = append(result, f)
+			continue
+		}
+
+		// remote photos are sent as is
+		if f.remote {
+			result = append(result, f)
+			continue
+		}
+
+		path, err := mediautil.PreparePhoto(ctx, consts.FFmpegPath, f.file, opts)
+		if err != nil {
+			fmt.Printf("Warning: Upload file %s as document because %s\n", f.file, err)
+			f.mode = uploader.ModeDocument
+			result = append(result, f)
+			continue
+		}
+		if path == f.file {
+			result = append(result, f)
+			continue
+		}
+
+		pf, err := buildFile(ctx, path, false)
+		if err != nil {
+			fmt.Printf("Warning: Skip file %s because of error: %s \n", path, err)
+			continue
+		}
+		pf.thumb = ""
+		pf.temp = true
+		pf.photo = f.photo
+		pf.source = f.source
+		result = append(result, pf)
+
+		if f.temp || isRemove {
+			os.Remove(f.file)
+		}
+	}
+
+	return result
+}
+
+// previewOptions controls preview generation of long videos
+type previewOptions struct {
+	columns, rows int     // grid of contact sheet, 0 to disable
+	clip          bool    // generate animated preview clip
+	minDuration   float64 // only videos not shorter than this have previews. Unit: Second
+}
+
+// parseGrid parses grid like 4x4, empty string means no grid
+func parseGrid(s string) (int, int, error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+
+	var columns, rows int
+	if _, err := fmt.Sscanf(strings.ToLower(s), "%dx%d", &columns, &rows); err != nil || columns <= 0 || rows <= 0 {
+		return 0, 0, errors.Errorf("invalid grid %q, should be like 4x4", s)
+	}
+	return columns, rows, nil
+}
+
+// previewFiles generates contact sheet and preview clip of long videos, and inserts them before the video.
+// Previews are temporary and written to work dir, which is removed after uploading.
+func previewFiles(ctx context.Context, files []*file, work string, opts previewOptions, dryRun bool) []*file {
+	if opts.columns <= 0 && !opts.clip {
+		return files
+	}
+
+	if dryRun {
+		for _, f := range files {
+			if !mediautil.IsVideo(f.mime) || f.info == nil || f.info.Duration < opts.minDuration {
+				continue
+			}
+			if opts.columns > 0 {
+				f.notes = append(f.notes, fmt.Sprintf("contact sheet: %dx%d", opts.columns, opts.rows))
+			}
+			if opts.clip {
+				f.notes = append(f.notes, "preview clip")
+			}
+		}
+		return files
+	}
+
+	vp := mediautil.GetVideoProcessor(consts.FFmpegPath)
+	if vp == nil {
+		fmt.Printf("Warning: Skip generating previews because no video processor found\n")
+		return files
+	}
+
+	result := make([]*file, 0, len(files))
+	for _, f := range files {
+		if !mediautil.IsVideo(f.mime) || f.info == nil || f.info.Duration < opts.minDuration {
+			result = append(result, f)
+			continue
+		}
+
+		dir, err := os.MkdirTemp(work, "preview-")
+		if err != nil {
+			fmt.Printf("Warning: Skip generating previews of %s because of error: %s\n", f.file, err)
+			result = append(result, f)
+			continue
+		}
+		name := fsutil.GetNameWithoutExt(f.base())
+
+		if opts.columns > 0 {
+			out := filepath.Join(dir, name+".sheet.jpg")
+			err = vp.ContactSheet(ctx, f.file, f.info, mediautil.ContactSheetOptions{
+				Columns:    opts.columns,
+				Rows:       opts.rows,
+				OutputPath: out,
+			})
+			if pf := buildPreview(ctx, f, out, err); pf != nil {
+				pf.thumb = ""
+				pf.photo = true
+				result = append(result, pf)
+			}
+		}
+
+		if opts.clip {
+			out := filepath.Join(dir, name+".preview.mp4")
+			err = vp.PreviewClip(ctx, f.file, f.info, mediautil.PreviewClipOptions{OutputPath: out})
+			if pf := buildPreview(ctx, f, out, err); pf != nil {
+				pf.mode = uploader.ModeAnimation
+				result = append(result, pf)
+			}
+		}
+
+		result = append(result, f)
+	}
+
+	return result
+}
+
+// buildPreview builds the generated preview of video f, err is the error of generation
+func buildPreview(ctx context.Context, f *file, path string, err error) *file {
+	if err != nil {
+		fmt.Printf("Warning: Skip preview of %s because of error: %s\n", f.file, err)
+		return nil
+	}
+
+	pf, err := buildFile(ctx, path, false)
+	if err != nil {
+		fmt.Printf("Warning: Skip preview of %s because of error: %s\n", f.file, err)
+		return nil
+	}
+	pf.temp = true
+	pf.source = f
+	return pf
+}
+
+// sizeLimit is the max size of uploaded file and where it comes from
+type sizeLimit struct {
+	size   int64
+	reason string
+}
+
+func (l sizeLimit) String() string {
+	return fmt.Sprintf("%s, %s", utils.Byte.FormatBinaryBytes(l.size), l.reason)
+}
+
+// resolveSizeLimit picks the max file size by account limit. maxFileSize(GB) is used only if it's smaller than account limit.
+func resolveSizeLimit(limit *uploader.Limit, maxFileSize float64) sizeLimit {
+	account := sizeLimit{size: limit.MaxSize, reason: "non-premium account limit"}
+	if limit.Premium {
+		account.reason = "premium account limit"
+	}
+
+	if maxFileSize <= 0 {
+		return account
+	}
+
+	custom := sizeLimit{size: int64(maxFileSize * 1024 * 1024 * 1024), reason: "--max-file-size"}
+	if custom.size > account.size {
+		fmt.Printf("Warning: --max-file-size %.2fGB is greater than account limit, use %s instead\n", maxFileSize, account)
+		return account
+	}
+
+	return custom
+}
+
+func filterFileSize(ctx context.Context, files []*file, work string, limit sizeLimit, isRemove bool, forceMp4 bool, split fsutil.SplitMode, dryRun bool) []*file {
 	filteredFiles := make([]*file, 0)
-	maxSize := int64(maxFileSize * 1000 * 1024 * 1024)
+	maxSize := limit.size
 
 	for _, f := range files {
 		if f.size == 0 {
@@ -214,58 +673,150 @@ func filterFileSize(ctx context.Context, files []*file, maxFileSize float64, isR
 			continue
 		}
 
+		if f.remote {
+			fmt.Printf("Warning: Skip file %s because its size %s exceeds max file size (%s) and remote file can't be split, download it first\n",
+				f.file, utils.Byte.FormatBinaryBytes(f.size), limit)
+			continue
+		}
+
+		vp := mediautil.GetVideoProcessor(consts.FFmpegPath)
+		canSplitVideo := mediautil.IsVideo(f.mime) && vp != nil && f.info != nil
+
+		// 不能按视频分割的文件，按分卷分割
+		if !canSplitVideo && split != fsutil.SplitModeNone {
+			fmt.Printf("Split file %s into %s parts because its size %s exceeds max file size (%s)\n",
+				f.file, split, utils.Byte.FormatBinaryBytes(f.size), limit)
+			if dryRun {
+				f.notes = append(f.notes, fmt.Sprintf("split: %d %s parts", (f.size+maxSize-1)/maxSize, split))
+				filteredFiles = append(filteredFiles, f)
+				continue
+			}
+			filteredFiles = append(filteredFiles, splitFile(ctx, f, work, maxSize, split, isRemove)...)
+			continue
+		}
+
 		// 如果不是视频文件，则跳过
 		if !mediautil.IsVideo(f.mime) {
 			// 如果文件不是视频，则跳过
-			fmt.Printf("Warning: Skip file %s because it is not a video but size is greater than maxFileSize\n", f.file)
+			fmt.Printf("Warning: Skip file %s because it is not a video but its size %s exceeds max file size (%s), use --split to upload it in parts\n",
+				f.file, utils.Byte.FormatBinaryBytes(f.size), limit)
 			continue
 		}
 
 		// 如果是视频文件，则需要分割
-		vp := mediautil.GetVideoProcessor(consts.FFmpegPath)
-		if vp == nil || f.info == nil {
-			fmt.Printf("Warning: Skip file %s because it is a video need to be split but no video processor found\n", f.file)
+		if !canSplitVideo {
+			fmt.Printf("Warning: Skip file %s because its size %s exceeds max file size (%s) and no video processor found to split it\n",
+				f.file, utils.Byte.FormatBinaryBytes(f.size), limit)
 			continue
 		}
 
-		// 计算分割的片段数量
-		parts := (f.info.Size + maxSize - 1) / maxSize
-		duration := f.info.Duration / float64(parts)
-		splitFiles := make([]string, 0)
+		fmt.Printf("Split video %s at keyframes because its size %s exceeds max file size (%s)\n",
+			f.file, utils.Byte.FormatBinaryBytes(f.size), limit)
 
-		for i := 0; i < int(parts); i++ {
-			splitPath := fmt.Sprintf("%s_part%d%s", strings.TrimSuffix(f.file, filepath.Ext(f.file)), i+1, filepath.Ext(f.file))
-			err := vp.SplitVideo(ctx, f.file, mediautil.SplitOptions{
-				StartTime:  duration * float64(i),
-				Duration:   duration,
-				OutputPath: splitPath,
-			})
-			if err != nil {
-				// 如果分割失败，清理已分割的文件
-				for k := 0; k < i; k++ {
-					os.Remove(splitFiles[k])
-				}
-				fmt.Printf("Warning: Split video %s failed: %s\n", f.file, err)
-				continue
-			}
-			splitFiles = append(splitFiles, splitPath)
+		if dryRun {
+			f.notes = append(f.notes, fmt.Sprintf("split: about %d video parts", vp.EstimateParts(ctx, f.file, f.info, maxSize)))
+			filteredFiles = append(filteredFiles, f)
+			continue
+		}
+
+		dir, err := os.MkdirTemp(work, "split-")
+		if err != nil {
+			fmt.Printf("Warning: Skip file %s because of error: %s\n", f.file, err)
+			continue
+		}
+		splitFiles, err := vp.SplitVideoBySize(ctx, f.file, dir, f.info, maxSize)
+		if err != nil {
+			fmt.Printf("Warning: Skip file %s because split video failed: %s\n", f.file, err)
+			_ = os.RemoveAll(dir)
+			continue
+		}
+
+		var source *splitSource
+		if isRemove {
+			source = newSplitSource(f.file, len(splitFiles))
 		}
 
 		// build split files
-		for _, splitPath := range splitFiles {
-			f, err := buildFile(ctx, splitPath, forceMp4)
+		for i, splitPath := range splitFiles {
+			pf, err := buildFile(ctx, splitPath, forceMp4)
 			if err != nil {
 				fmt.Printf("Warning: Skip file %s because of error: %s \n", splitPath, err)
 				continue
 			}
-			filteredFiles = append(filteredFiles, f)
+			// parts are generated by us, remove them after uploaded
+			pf.temp = true
+			pf.part = &part{
+				index:  i + 1,
+				total:  len(splitFiles),
+				name:   f.base(),
+				mode:   fsutil.SplitModeNone,
+				source: source, // 所有分段上传后才删除原始文件
+			}
+			filteredFiles = append(filteredFiles, pf)
 		}
+	}
 
-		// 如果需要删除原始文件，则删除
-		if isRemove {
-			os.Remove(f.file)
+	return filteredFiles
+}
+
+// splitFile splits f into parts by split mode in work dir, and returns built part files.
+// Original file is removed after all parts are uploaded if isRemove is true.
+func splitFile(ctx context.Context, f *file, work string, maxSize int64, split fsutil.SplitMode, isRemove bool) []*file {
+	dir, err := os.MkdirTemp(work, "split-")
+	if err != nil {
+		fmt.Printf("Warning: Skip file %s because of error: %s\n", f.file, err)
+		return nil
+	}
+
+	parts, err := fsutil.SplitFile(f.file, dir, maxSize, split)
+	if err != nil {
+		fmt.Printf("Warning: Skip file %s because split failed: %s\n", f.file, err)
+		_ = os.RemoveAll(dir)
+		return nil
+	}
+
+	var source *splitSource
+	if isRemove {
+		source = newSplitSource(f.file, len(parts))
+	}
+
+	files := make([]*file, 0, len(parts))
+	for i, partPath := range parts {
+		pf, err := buildFile(ctx, partPath, false)
+		if err != nil {
+			fmt.Printf("Warning: Skip file %s because of error: %s \n", partPath, err)
+			continue
 		}
+		// parts are always sent as documents, and never have thumbnails
+		pf.mime = "application/octet-stream"
+		pf.thumb = ""
+		pf.temp = true
+		pf.part = &part{
+			index:  i + 1,
+			total:  len(parts),
+			name:   f.base(),
+			mode:   split,
+			source: source,
+		}
+		files = append(files, pf)
 	}
 
-	return filteredFiles
+	return files
+}
+
+// partCaption describes how to reassemble parts. Keep the 【分卷】i/n format, it's parsed by `tdl dl --join`.
+// Video parts are playable by themselves, so only the position is described.
+func partCaption(p *part) string {
+	if p.mode == fsutil.SplitModeNone {
+		return fmt.Sprintf("【分段】%d/%d %s\n", p.index, p.total, p.name)
+	}
+
+	first := fsutil.PartName(p.name, 1)
+	join := fmt.Sprintf("cat %s.* > %s", p.name, p.name)
+	if p.mode == fsutil.SplitModeZip {
+		first = fsutil.PartName(p.name+".zip", 1)
+		join = fmt.Sprintf("用 7-Zip 打开 %s", first)
+	}
+
+	return fmt.Sprintf("【分卷】%d/%d %s\n【合并】%s，或使用 tdl dl --join\n", p.index, p.total, first, join)
 }
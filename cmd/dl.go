@@ -60,6 +60,7 @@ func NewDownload() *cobra.Command {
 	cmd.Flags().BoolVar(&opts.Desc, "desc", false, "download files from the newest to the oldest ones (may affect resume download)")
 	cmd.Flags().BoolVar(&opts.Takeout, "takeout", false, "takeout sessions let you export data from your account with lower flood wait limits.")
 	cmd.Flags().BoolVar(&opts.Group, "group", false, "auto detect grouped message and download all of them")
+	cmd.Flags().BoolVar(&opts.Join, "join", false, "detect parts uploaded by 'tdl up --split' (file.001, file.002, ...) and join them after download")
 
 	// resume flags, if both false then ask user
 	cmd.Flags().BoolVar(&opts.Continue, _continue, false, "continue the last download directly")
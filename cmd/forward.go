@@ -12,6 +12,7 @@ import (
 	"github.com/lshcx/tdl/core/forwarder"
 	"github.com/lshcx/tdl/core/logctx"
 	"github.com/lshcx/tdl/core/storage"
+	"github.com/lshcx/tdl/core/util/textutil"
 )
 
 func NewForward() *cobra.Command {
@@ -22,20 +23,54 @@ func NewForward() *cobra.Command {
 		Short:   "Forward messages with automatic fallback and message routing",
 		GroupID: groupTools.ID,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return tRun(cmd.Context(), func(ctx context.Context, c *telegram.Client, kvd storage.Storage) error {
+			var handler telegram.UpdateHandler
+			if opts.Watch {
+				opts.Updates = forward.NewUpdates(logctx.Named(cmd.Context(), "forward"))
+				handler = opts.Updates
+			}
+
+			return tRunWithUpdates(cmd.Context(), handler, func(ctx context.Context, c *telegram.Client, kvd storage.Storage) error {
 				return forward.Run(logctx.Named(ctx, "forward"), c, kvd, opts)
 			})
 		},
 	}
 
-	cmd.Flags().StringArrayVar(&opts.From, "from", []string{}, "messages to be forwarded, can be links or exported JSON files")
+	cmd.Flags().StringArrayVar(&opts.From, "from", []string{}, "messages to be forwarded, can be links or exported JSON files. Chats to be watched in watch mode")
 	cmd.Flags().StringVar(&opts.To, "to", "", "destination peer, can be a CHAT or router based on expression engine")
 	cmd.Flags().StringVar(&opts.Edit, "edit", "", "edit message or caption with expression engine. Empty means no edit")
+	cmd.Flags().Var(&opts.Parse, "parse-mode", fmt.Sprintf("parse mode of edited message: [%s]", strings.Join(textutil.ParseModeNames(), ", ")))
 	cmd.Flags().Var(&opts.Mode, "mode", fmt.Sprintf("forward mode: [%s]", strings.Join(forwarder.ModeNames(), ", ")))
 	cmd.Flags().BoolVar(&opts.Silent, "silent", false, "send messages silently")
 	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "do not actually send messages, just show how they would be sent")
 	cmd.Flags().BoolVar(&opts.Single, "single", false, "do not automatically detect and forward grouped messages")
 	cmd.Flags().BoolVar(&opts.Desc, "desc", false, "forward messages in reverse order for each input peer")
 
+	cmd.Flags().StringVar(&opts.Transform, "transform", "", "file of text transforms applied in order after edit, e.g. regex replace, link rewrite, signature. Transformed messages are cloned")
+
+	cmd.Flags().BoolVar(&opts.DropAuthor, "drop-author", false, "hide original author of messages forwarded in direct mode")
+	cmd.Flags().BoolVar(&opts.DropCaption, "drop-caption", false, "remove captions of media messages")
+	cmd.Flags().BoolVar(&opts.StripMarkup, "strip-markup", false, "remove buttons of messages, messages with buttons are cloned")
+	cmd.Flags().StringSliceVar(&opts.DropEntities, "drop-entities", nil, fmt.Sprintf("remove entities from messages, text of entities except text_url and mention_name is also removed, changed messages are cloned: [%s]", strings.Join(textutil.EntityTypeNames(), ", ")))
+
+	cmd.Flags().StringVar(&opts.Schedule, "schedule", "", "schedule messages at time, can be '2006-01-02 15:04[:05]' in local time, RFC3339, unix timestamp or duration from now like '+1h30m'. Router can override it by 'Schedule' field")
+	cmd.Flags().DurationVar(&opts.ScheduleInterval, "schedule-interval", 0, "spread scheduled messages from schedule time by interval, albums are counted as one message")
+	cmd.Flags().StringVar(&opts.SendAs, "send-as", "", "send messages as the CHAT, e.g. a channel you administer. Router can override it by 'SendAs' field")
+
+	cmd.Flags().BoolVar(&opts.Dedup, "dedup", false, "skip messages whose media or text is already in the destination chat, fingerprints of destination messages are saved for next runs")
+	cmd.Flags().IntVar(&opts.DedupScan, "dedup-scan", 1000, "max number of recent destination messages to scan for dedup, only messages newer than the last scan are scanned")
+
+	// resume flags, if both false then ask user
+	cmd.Flags().BoolVar(&opts.Continue, "continue", false, "continue the last forward job with the same sources, destination and mode directly")
+	cmd.Flags().BoolVar(&opts.Restart, "restart", false, "restart the last forward job directly, forwarded messages will be sent again")
+	cmd.MarkFlagsMutuallyExclusive("continue", "restart")
+
+	cmd.Flags().BoolVar(&opts.Watch, "watch", false, "watch chats and forward new messages in real time, edits and deletions are also mirrored. Messages sent while not watching are not forwarded")
+	cmd.MarkFlagsMutuallyExclusive("watch", "continue")
+	cmd.MarkFlagsMutuallyExclusive("watch", "restart")
+	cmd.MarkFlagsMutuallyExclusive("watch", "desc")
+	// schedule is computed from start of the run, which drifts for messages received later
+	cmd.MarkFlagsMutuallyExclusive("watch", "schedule")
+	cmd.MarkFlagsMutuallyExclusive("watch", "schedule-interval")
+
 	return cmd
 }
@@ -233,10 +233,16 @@ func tOptions(ctx context.Context) (tclient.Options, error) {
 }
 
 func tRun(ctx context.Context, f func(ctx context.Context, c *telegram.Client, kvd storage.Storage) error, middlewares ...telegram.Middleware) error {
+	return tRunWithUpdates(ctx, nil, f, middlewares...)
+}
+
+// tRunWithUpdates is the same as tRun, but updates of the client are passed to handler
+func tRunWithUpdates(ctx context.Context, handler telegram.UpdateHandler, f func(ctx context.Context, c *telegram.Client, kvd storage.Storage) error, middlewares ...telegram.Middleware) error {
 	o, err := tOptions(ctx)
 	if err != nil {
 		return errors.Wrap(err, "build telegram options")
 	}
+	o.UpdateHandler = handler
 
 	client, err := tclient.New(ctx, o, false, middlewares...)
 	if err != nil {
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"github.com/gotd/td/telegram"
 	"github.com/spf13/cobra"
@@ -10,6 +12,10 @@ import (
 	"github.com/lshcx/tdl/app/up"
 	"github.com/lshcx/tdl/core/logctx"
 	"github.com/lshcx/tdl/core/storage"
+	"github.com/lshcx/tdl/core/uploader"
+	"github.com/lshcx/tdl/core/util/fsutil"
+	"github.com/lshcx/tdl/core/util/mediautil"
+	"github.com/lshcx/tdl/core/util/textutil"
 	"github.com/lshcx/tdl/pkg/logger"
 )
 
@@ -47,25 +53,49 @@ func NewUpload() *cobra.Command {
 
 	const (
 		_chat = "chat"
+		to    = "to"
+		topic = "topic"
+		reply = "reply"
 		path  = "path"
 	)
 	cmd.Flags().StringVarP(&opts.Chat, _chat, "c", "", "chat id or domain, and empty means 'Saved Messages'")
-	cmd.Flags().StringSliceVarP(&opts.Paths, path, "p", []string{}, "dirs or files")
+	cmd.Flags().StringVar(&opts.To, to, "", "destination router based on expression engine, evaluated for each file. Use '-' to list available fields")
+	cmd.Flags().IntVar(&opts.Topic, topic, 0, "forum topic id to upload to, 0 means no topic")
+	cmd.Flags().IntVar(&opts.Reply, reply, 0, "message id to reply to, 0 means no reply")
+	cmd.Flags().StringSliceVarP(&opts.Paths, path, "p", []string{}, "dirs, files, HTTP(S) URLs, or '-' for stdin. URLs with known size are streamed without saving to disk")
+	cmd.Flags().StringVar(&opts.StdinName, "stdin-name", "stdin", "file name of stdin when path is '-'")
 	cmd.Flags().StringSliceVarP(&opts.Excludes, "excludes", "e", []string{}, "exclude the specified file extensions")
 	cmd.Flags().BoolVar(&opts.Remove, "rm", false, "remove the uploaded files after uploading")
-	cmd.Flags().BoolVar(&opts.Photo, "photo", false, "upload the image as a photo instead of a file")
+	cmd.Flags().BoolVar(&opts.Photo, "photo", false, "upload the image as a photo instead of a file. HEIC/AVIF/TIFF are converted to JPEG, large images are downsized to fit Telegram limits, and images which can't be sent as photos are uploaded as files")
+	cmd.Flags().BoolVar(&opts.KeepGPS, "keep-gps", false, "keep EXIF location of photos, which is stripped by default")
 	cmd.Flags().BoolVar(&opts.AsAlbum, "as-album", false, "upload as an album")
 	cmd.Flags().IntVar(&opts.MaxAlbumSize, "max-album-size", 10, "max album size, only works when --as-album is true")
+	cmd.Flags().StringVar(&opts.Group, "group", "", "album group key based on expression engine, e.g. 'Dir' groups files by source directory. Each group has its own albums and caption, only works when --as-album is true")
+	cmd.Flags().StringVar(&opts.As, "as", "", fmt.Sprintf("upload mode: [%s], or expression returning mode name, evaluated for each file. e.g. 'Ext == \".ogg\" ? \"voice\" : \"auto\"'", strings.Join(uploader.ModeNames(), ", ")))
 	cmd.Flags().StringVar(&opts.Caption.CaptionHeader, "caption", "", "custom caption header(end with \\n)")
 	cmd.Flags().StringVar(&opts.Caption.CaptionBody, "caption-body", "", "custom caption body(end with \\n)")
 	cmd.Flags().StringVar(&opts.Caption.CaptionFooter, "caption-footer", "", "custom caption footer")
-	cmd.Flags().Float64Var(&opts.MaxFileSize, "max-file-size", 2, "max file size(GB), if the file size is greater than this value, it will be split into multiple files")
+	cmd.Flags().Float64Var(&opts.MaxFileSize, "max-file-size", 0, "max file size(GB), if the file size is greater than this value, it will be split into multiple files. 0 means the limit of account (2000MB, or 4000MB for premium), and it can't exceed the limit")
+	cmd.Flags().Var(&opts.Split, "split", fmt.Sprintf("split files which can't be split as video and are greater than --max-file-size into parts: [%s]. 'raw' creates .001/.002 parts, 'zip' creates 7-Zip compatible .zip.001/.zip.002 volumes", strings.Join(fsutil.SplitModeNames(), ", ")))
 	cmd.Flags().StringVar(&opts.ThumbTime, "thumb-time", "00:00:01", "thumbnail time")
-	cmd.Flags().BoolVar(&opts.ForceMp4, "force-mp4", false, "force to convert video to mp4")
+	cmd.Flags().BoolVar(&opts.ForceMp4, "force-mp4", false, "force to convert video to mp4, same as '--transcode remux'")
+	cmd.Flags().Var(&opts.Transcode, "transcode", fmt.Sprintf("transcode profile of videos: [%s]. 'remux' converts to faststart mp4 and only re-encodes streams Telegram can't play, 'h264' always re-encodes video to H.264/AAC", strings.Join(mediautil.TranscodeProfileNames(), ", ")))
+	cmd.Flags().IntVar(&opts.MaxHeight, "max-height", 0, "cap the short side of videos by transcoding, e.g. 720. 0 means no limit, only works with --transcode")
+	cmd.Flags().Int64Var(&opts.MaxBitrate, "max-bitrate", 0, "cap bitrate(kb/s) of videos by transcoding. 0 means no limit, only works with --transcode")
+	cmd.Flags().StringVar(&opts.ContactSheet, "contact-sheet", "", "generate a contact sheet of videos with timestamps and send it as photo before the video (inside the album with --as-album), e.g. 4x4. Empty means no contact sheet")
+	cmd.Flags().BoolVar(&opts.PreviewClip, "preview-clip", false, "generate a short silent preview clip of videos and send it as animation before the video")
+	cmd.Flags().Float64Var(&opts.PreviewMin, "preview-min-duration", 0, "only generate previews for videos not shorter than this duration(seconds)")
 	cmd.Flags().BoolVar(&opts.Caption.NoCaption, "no-caption", false, "no caption")
+	cmd.Flags().Var(&opts.ParseMode, "parse-mode", fmt.Sprintf("parse mode of caption: [%s]", strings.Join(textutil.ParseModeNames(), ", ")))
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "do not actually upload files, just print the plan: destination, album, thumbnail, caption and splitting of each file")
+	cmd.Flags().Var(&opts.PlanOutput, "plan-output", fmt.Sprintf("output format of plan printed by --dry-run: [%s]", strings.Join(up.PlanOutputNames(), ", ")))
 
 	// completion and validation
-	_ = cmd.MarkFlagRequired(path)
+	// path is checked when running, so that '--to -' can be used without path
+	// router decides chat and topic for each file
+	cmd.MarkFlagsMutuallyExclusive(to, _chat)
+	cmd.MarkFlagsMutuallyExclusive(to, topic)
+	cmd.MarkFlagsMutuallyExclusive(to, reply)
 
 	return cmd
 }
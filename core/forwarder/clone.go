@@ -11,8 +11,11 @@ import (
 	"github.com/gotd/td/tg"
 	"go.uber.org/atomic"
 	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 
 	tdownloader "github.com/lshcx/tdl/core/downloader"
+	"github.com/lshcx/tdl/core/logctx"
 	"github.com/lshcx/tdl/core/tmedia"
 	tuploader "github.com/lshcx/tdl/core/uploader"
 	"github.com/lshcx/tdl/core/util/tutil"
@@ -28,7 +31,7 @@ type progressAdd interface {
 	add(n int64)
 }
 
-func (f *Forwarder) cloneMedia(ctx context.Context, opts cloneOptions, dryRun bool) (_ tg.InputFileClass, rerr error) {
+func (f *Forwarder) cloneMedia(ctx context.Context, opts cloneOptions, dryRun bool) (tg.InputFileClass, error) {
 	// if dry run, just return empty input file
 	if dryRun {
 		// directly call progress callback
@@ -37,6 +40,88 @@ func (f *Forwarder) cloneMedia(ctx context.Context, opts cloneOptions, dryRun bo
 		return &tg.InputFile{}, nil
 	}
 
+	threads := tutil.BestThreads(opts.media.Size, f.opts.Threads)
+
+	// uploader must know the size of file before reading, otherwise it's staged in temp file
+	if opts.media.Size > 0 {
+		counter := &countProgress{progressAdd: opts.progress}
+		file, err := f.clonePipe(ctx, cloneOptions{
+			elem:     opts.elem,
+			media:    opts.media,
+			progress: counter,
+		}, threads)
+		if err == nil {
+			return file, nil
+		}
+		if errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+
+		logctx.From(ctx).Warn("Clone media via pipe failed, fallback to temp file",
+			zap.String("name", opts.media.Name),
+			zap.Error(err))
+		// transferred bytes are counted again
+		opts.progress.add(-counter.n.Load())
+	}
+
+	return f.cloneTemp(ctx, opts, threads)
+}
+
+// clonePipe uploads parts as soon as they are downloaded, at most pipeParts(threads) parts are kept in memory
+func (f *Forwarder) clonePipe(ctx context.Context, opts cloneOptions, threads int) (tg.InputFileClass, error) {
+	p := newPipe(opts.media.Size, pipeParts(threads)*tdownloader.MaxPartSize)
+
+	wg, wgctx := errgroup.WithContext(ctx)
+	// unblock the other side if context is canceled or one side fails
+	stop := context.AfterFunc(wgctx, func() { p.CloseWithError(wgctx.Err()) })
+	defer stop()
+
+	wg.Go(func() error {
+		_, err := downloader.NewDownloader().
+			WithPartSize(tdownloader.MaxPartSize).
+			Download(f.opts.Pool.Client(wgctx, opts.media.DC), opts.media.InputFileLoc).
+			WithThreads(threads).
+			Parallel(wgctx, writeAt{
+				f:    p,
+				opts: opts,
+			})
+		if err != nil {
+			return errors.Wrap(err, "download")
+		}
+		return nil
+	})
+
+	var file tg.InputFileClass
+	wg.Go(func() (err error) {
+		upload := uploader.NewUpload(opts.media.Name, p, opts.media.Size)
+		file, err = uploader.NewUploader(f.opts.Pool.Default(wgctx)).
+			WithPartSize(tuploader.MaxPartSize).
+			WithThreads(threads).
+			WithProgress(uploaded{
+				opts: opts,
+				prev: atomic.NewInt64(0),
+			}).
+			Upload(wgctx, upload)
+		if err != nil {
+			return errors.Wrap(err, "upload")
+		}
+		return nil
+	})
+
+	if err := wg.Wait(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// pipeParts returns number of parts kept in pipe. Downloader writes parts in a single loop,
+// and at most 2*threads parts are downloading or waiting to be written, so the window must cover them.
+func pipeParts(threads int) int64 {
+	return int64(2*max(threads, 1) + 2)
+}
+
+// cloneTemp downloads the whole file to temp file, then uploads it
+func (f *Forwarder) cloneTemp(ctx context.Context, opts cloneOptions, threads int) (_ tg.InputFileClass, rerr error) {
 	temp, err := os.CreateTemp("", "tdl_*")
 	if err != nil {
 		return nil, errors.Wrap(err, "create temp file")
@@ -46,8 +131,6 @@ func (f *Forwarder) cloneMedia(ctx context.Context, opts cloneOptions, dryRun bo
 		multierr.AppendInto(&rerr, os.Remove(temp.Name()))
 	}()
 
-	threads := tutil.BestThreads(opts.media.Size, f.opts.Threads)
-
 	_, err = downloader.NewDownloader().
 		WithPartSize(tdownloader.MaxPartSize).
 		Download(f.opts.Pool.Client(ctx, opts.media.DC), opts.media.InputFileLoc).
@@ -82,6 +165,17 @@ func (f *Forwarder) cloneMedia(ctx context.Context, opts cloneOptions, dryRun bo
 	return file, nil
 }
 
+// countProgress counts transferred bytes, which are reverted if the clone is retried
+type countProgress struct {
+	progressAdd
+	n atomic.Int64
+}
+
+func (c *countProgress) add(n int64) {
+	c.n.Add(n)
+	c.progressAdd.add(n)
+}
+
 type writeAt struct {
 	f    io.WriterAt
 	opts cloneOptions
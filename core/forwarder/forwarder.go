@@ -3,6 +3,7 @@ package forwarder
 import (
 	"context"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/go-faster/errors"
@@ -11,6 +12,7 @@ import (
 	"github.com/gotd/td/tg"
 	"go.uber.org/atomic"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/lshcx/tdl/core/dcpool"
 	"github.com/lshcx/tdl/core/logctx"
@@ -27,14 +29,18 @@ type Mode int
 type Options struct {
 	Pool     dcpool.Pool
 	Threads  int
+	Limit    int // max number of messages which are prepared concurrently, e.g. cloning media
 	Iter     Iter
 	Progress Progress
+	Recorder Recorder // optional, record sent messages
 }
 
 type Forwarder struct {
-	sent map[tuple]struct{} // used to filter grouped messages which are already sent
-	rand *rand.Rand
-	opts Options
+	sent   map[tuple]struct{} // used to filter grouped messages which are already sent
+	mapped map[target]int     // destination message IDs of messages sent in this run, used to keep reply chains
+	mu     sync.Mutex         // protects mapped and rand
+	rand   *rand.Rand
+	opts   Options
 }
 
 type tuple struct {
@@ -42,56 +48,119 @@ type tuple struct {
 	msg  int
 }
 
+type target struct {
+	tuple
+	to int64
+}
+
 func New(opts Options) *Forwarder {
 	return &Forwarder{
-		sent: make(map[tuple]struct{}),
-		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
-		opts: opts,
+		sent:   make(map[tuple]struct{}),
+		mapped: make(map[target]int),
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		opts:   opts,
 	}
 }
 
+// Forward prepares messages concurrently, and sends messages to the same destination in iteration order
 func (f *Forwarder) Forward(ctx context.Context) error {
-	for f.opts.Iter.Next(ctx) {
+	wg, wgctx := errgroup.WithContext(ctx)
+	wg.SetLimit(max(f.opts.Limit, 1))
+	seq := newSequencer()
+
+	for f.opts.Iter.Next(wgctx) {
 		elem := f.opts.Iter.Value()
 		if _, ok := f.sent[f.tuple(elem.From(), elem.Msg())]; ok {
 			// skip grouped messages
 			continue
 		}
 
+		var grouped []*tg.Message
 		if _, ok := elem.Msg().GetGroupedID(); ok && elem.AsGrouped() {
-			grouped, err := tutil.GetGroupedMessages(ctx, f.opts.Pool.Default(ctx), elem.From().InputPeer(), elem.Msg())
+			var err error
+			grouped, err = tutil.GetGroupedMessages(wgctx, f.opts.Pool.Default(wgctx), elem.From().InputPeer(), elem.Msg())
 			if err != nil {
 				continue
 			}
+		}
+
+		// mark as sent before dispatching, so that rest of album won't be dispatched again
+		f.sent[f.tuple(elem.From(), elem.Msg())] = struct{}{}
+		for _, m := range grouped {
+			f.sent[f.tuple(elem.From(), m)] = struct{}{}
+		}
 
-			if err = f.forwardMessage(ctx, elem, grouped...); err != nil {
+		if ge, ok := elem.(GroupedElem); ok && len(grouped) > 0 {
+			var err error
+			if grouped, err = ge.Grouped(wgctx, grouped); err != nil {
+				logctx.From(wgctx).Warn("Prepare grouped messages",
+					zap.Int64("from", elem.From().ID()),
+					zap.Int("message", elem.Msg().ID),
+					zap.Error(err))
+				continue
+			}
+			if len(grouped) == 0 { // all messages are dropped
 				continue
 			}
-
-			continue
 		}
 
-		if err := f.forwardMessage(ctx, elem); err != nil {
-			// canceled by user, so we directly return error to stop all
-			if errors.Is(err, context.Canceled) {
-				return err
+		wait, done := seq.next(elem.To().ID())
+		wg.Go(func() error {
+			defer done()
+
+			if err := f.forwardMessage(wgctx, elem, wait, grouped...); err != nil {
+				// canceled by user, so we directly return error to stop all
+				if errors.Is(err, context.Canceled) {
+					return err
+				}
 			}
-			continue
-		}
+			return nil
+		})
 	}
 
+	if err := wg.Wait(); err != nil {
+		return err
+	}
 	return f.opts.Iter.Err()
 }
 
-func (f *Forwarder) forwardMessage(ctx context.Context, elem Elem, grouped ...*tg.Message) (rerr error) {
+// forwardMessage prepares the message, and calls wait before sending it to keep the order of destination
+func (f *Forwarder) forwardMessage(ctx context.Context, elem Elem, wait func(ctx context.Context) error, grouped ...*tg.Message) (rerr error) {
+	// source message ID -> destination message ID
+	var sent map[int]int
+
 	f.opts.Progress.OnAdd(elem)
 	defer func() {
-		f.sent[f.tuple(elem.From(), elem.Msg())] = struct{}{}
+		if elem.Schedule() != 0 {
+			// scheduled messages get new IDs when they are sent, so they can't be mapped
+			for src := range sent {
+				sent[src] = 0
+			}
+		}
 
-		// grouped message also should be marked as sent
-		for _, m := range grouped {
-			f.sent[f.tuple(elem.From(), m)] = struct{}{}
+		if rerr == nil && !elem.AsDryRun() {
+			f.mu.Lock()
+			for src, dst := range sent {
+				if dst != 0 {
+					f.mapped[target{tuple: tuple{from: elem.From().ID(), msg: src}, to: elem.To().ID()}] = dst
+				}
+			}
+			f.mu.Unlock()
 		}
+
+		if rerr == nil && f.opts.Recorder != nil && !elem.AsDryRun() {
+			// grouped messages which are skipped are also marked as sent, same as f.sent
+			if sent == nil {
+				sent = make(map[int]int)
+			}
+			for _, m := range append(grouped, elem.Msg()) {
+				if _, ok := sent[m.ID]; !ok {
+					sent[m.ID] = 0
+				}
+			}
+			f.opts.Recorder.OnSent(elem, sent)
+		}
+
 		f.opts.Progress.OnDone(elem, rerr)
 	}()
 
@@ -108,9 +177,14 @@ func (f *Forwarder) forwardMessage(ctx context.Context, elem Elem, grouped ...*t
 	done := atomic.NewInt64(0)
 
 	forwardTextOnly := func(msg *tg.Message) error {
-		if msg.Message == "" {
+		text, entities := caption(elem, msg)
+		if text == "" {
 			return errors.Errorf("empty message content, skip send: %d", msg.ID)
 		}
+		if err := wait(ctx); err != nil {
+			return err
+		}
+		random := f.random()
 		req := &tg.MessagesSendMessageRequest{
 			NoWebpage:              false,
 			Silent:                 elem.AsSilent(),
@@ -119,19 +193,21 @@ func (f *Forwarder) forwardMessage(ctx context.Context, elem Elem, grouped ...*t
 			Noforwards:             false,
 			UpdateStickersetsOrder: false,
 			Peer:                   elem.To().InputPeer(),
-			ReplyTo:                getReplyTo(elem.Thread()),
-			Message:                msg.Message,
-			RandomID:               f.rand.Int63(),
+			ReplyTo:                f.replyTo(elem, msg),
+			Message:                text,
+			RandomID:               random,
 			ReplyMarkup:            msg.ReplyMarkup,
-			Entities:               msg.Entities,
-			ScheduleDate:           0,
-			SendAs:                 nil,
+			Entities:               entities,
+			ScheduleDate:           elem.Schedule(),
+			SendAs:                 sendAs(elem),
 		}
 		req.SetFlags()
 
-		if _, err := f.forwardClient(ctx, elem).MessagesSendMessage(ctx, req); err != nil {
+		updates, err := f.forwardClient(ctx, elem).MessagesSendMessage(ctx, req)
+		if err != nil {
 			return errors.Wrap(err, "send message")
 		}
+		sent = sentIDs(updates, map[int64]int{random: msg.ID})
 		return nil
 	}
 
@@ -252,29 +328,37 @@ func (f *Forwarder) forwardMessage(ctx context.Context, elem Elem, grouped ...*t
 		if !protectedDialog(elem.From()) && !protectedMessage(elem.Msg()) {
 			directForward := func(ids ...int) error {
 				randIDs := make([]int64, 0, len(ids))
-				for range ids {
-					randIDs = append(randIDs, f.rand.Int63())
+				randoms := make(map[int64]int, len(ids))
+				for _, id := range ids {
+					random := f.random()
+					randIDs = append(randIDs, random)
+					randoms[random] = id
 				}
 
 				req := &tg.MessagesForwardMessagesRequest{
 					Silent:            elem.AsSilent(),
 					Background:        false,
 					WithMyScore:       false,
-					DropAuthor:        false,
-					DropMediaCaptions: false,
+					DropAuthor:        elem.AsDropAuthor(),
+					DropMediaCaptions: elem.AsDropCaption(),
 					Noforwards:        false,
 					FromPeer:          elem.From().InputPeer(),
 					ID:                ids,
 					RandomID:          randIDs,
 					ToPeer:            elem.To().InputPeer(),
 					TopMsgID:          elem.Thread(),
-					ScheduleDate:      0,
-					SendAs:            nil,
+					ScheduleDate:      elem.Schedule(),
+					SendAs:            sendAs(elem),
 				}
 				req.SetFlags()
-				if _, err := f.forwardClient(ctx, elem).MessagesForwardMessages(ctx, req); err != nil {
+				if err := wait(ctx); err != nil {
+					return err
+				}
+				updates, err := f.forwardClient(ctx, elem).MessagesForwardMessages(ctx, req)
+				if err != nil {
 					return errors.Wrap(err, "directly forward")
 				}
+				sent = sentIDs(updates, randoms)
 				return nil
 			}
 
@@ -301,6 +385,7 @@ func (f *Forwarder) forwardMessage(ctx context.Context, elem Elem, grouped ...*t
 	case ModeClone:
 		if len(grouped) > 0 {
 			media := make([]tg.InputSingleMedia, 0, len(grouped))
+			randoms := make(map[int64]int, len(grouped))
 			for _, gm := range grouped {
 				m, err := convForwardedMedia(gm)
 				if err != nil {
@@ -308,11 +393,14 @@ func (f *Forwarder) forwardMessage(ctx context.Context, elem Elem, grouped ...*t
 					continue
 				}
 
+				random := f.random()
+				randoms[random] = gm.ID
+				text, entities := caption(elem, gm)
 				single := tg.InputSingleMedia{
 					Media:    m,
-					RandomID: f.rand.Int63(),
-					Message:  gm.Message,
-					Entities: gm.Entities,
+					RandomID: random,
+					Message:  text,
+					Entities: entities,
 				}
 				single.SetFlags()
 
@@ -320,6 +408,9 @@ func (f *Forwarder) forwardMessage(ctx context.Context, elem Elem, grouped ...*t
 			}
 
 			if len(media) > 0 {
+				if err := wait(ctx); err != nil {
+					return err
+				}
 				req := &tg.MessagesSendMultiMediaRequest{
 					Silent:                 elem.AsSilent(),
 					Background:             false,
@@ -327,15 +418,17 @@ func (f *Forwarder) forwardMessage(ctx context.Context, elem Elem, grouped ...*t
 					Noforwards:             false,
 					UpdateStickersetsOrder: false,
 					Peer:                   elem.To().InputPeer(),
-					ReplyTo:                getReplyTo(elem.Thread()),
+					ReplyTo:                f.replyTo(elem, grouped[0]),
 					MultiMedia:             media,
-					ScheduleDate:           0,
-					SendAs:                 nil,
+					ScheduleDate:           elem.Schedule(),
+					SendAs:                 sendAs(elem),
 				}
 				req.SetFlags()
-				if _, err := f.forwardClient(ctx, elem).MessagesSendMultiMedia(ctx, req); err != nil {
+				updates, err := f.forwardClient(ctx, elem).MessagesSendMultiMedia(ctx, req)
+				if err != nil {
 					return errors.Wrap(err, "send multi media")
 				}
+				sent = sentIDs(updates, randoms)
 				return nil
 			}
 
@@ -347,7 +440,12 @@ func (f *Forwarder) forwardMessage(ctx context.Context, elem Elem, grouped ...*t
 			log.Debug("Can't convert forwarded media", zap.Error(err))
 			return forwardTextOnly(elem.Msg())
 		}
+		if err := wait(ctx); err != nil {
+			return err
+		}
 		// send text copy with forwarded media
+		random := f.random()
+		text, entities := caption(elem, elem.Msg())
 		req := &tg.MessagesSendMediaRequest{
 			Silent:                 elem.AsSilent(),
 			Background:             false,
@@ -355,26 +453,35 @@ func (f *Forwarder) forwardMessage(ctx context.Context, elem Elem, grouped ...*t
 			Noforwards:             false,
 			UpdateStickersetsOrder: false,
 			Peer:                   elem.To().InputPeer(),
-			ReplyTo:                getReplyTo(elem.Thread()),
+			ReplyTo:                f.replyTo(elem, elem.Msg()),
 			Media:                  media,
-			Message:                elem.Msg().Message,
-			RandomID:               rand.Int63(),
+			Message:                text,
+			RandomID:               random,
 			ReplyMarkup:            elem.Msg().ReplyMarkup,
-			Entities:               elem.Msg().Entities,
-			ScheduleDate:           0,
-			SendAs:                 nil,
+			Entities:               entities,
+			ScheduleDate:           elem.Schedule(),
+			SendAs:                 sendAs(elem),
 		}
 		req.SetFlags()
 
-		if _, err := f.forwardClient(ctx, elem).MessagesSendMedia(ctx, req); err != nil {
+		updates, err := f.forwardClient(ctx, elem).MessagesSendMedia(ctx, req)
+		if err != nil {
 			return errors.Wrap(err, "send single media")
 		}
+		sent = sentIDs(updates, map[int64]int{random: elem.Msg().ID})
 		return nil
 	}
 
 	return errors.Errorf("unsupported mode %v", elem.Mode())
 }
 
+func (f *Forwarder) random() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.rand.Int63()
+}
+
 func (f *Forwarder) tuple(peer peers.Peer, msg *tg.Message) tuple {
 	return tuple{
 		from: peer.ID(),
@@ -460,6 +567,71 @@ func mediaSizeSum(msg *tg.Message, grouped ...*tg.Message) (int64, error) {
 	return m.Size, nil
 }
 
+// replyTo keeps the reply of source message if the replied message was forwarded to the same destination,
+// otherwise it replies to the thread.
+func (f *Forwarder) replyTo(elem Elem, msg *tg.Message) tg.InputReplyToClass {
+	header, ok := msg.ReplyTo.(*tg.MessageReplyHeader)
+	if !ok {
+		return getReplyTo(elem.Thread())
+	}
+
+	id, ok := header.GetReplyToMsgID()
+	// reply to message of other chat can't be kept
+	if _, other := header.GetReplyToPeerID(); !ok || other {
+		return getReplyTo(elem.Thread())
+	}
+
+	dst, ok := f.lookup(elem.From().ID(), id, elem.To().ID())
+	if !ok {
+		return getReplyTo(elem.Thread())
+	}
+
+	// quote isn't kept, because the replied message may be edited when forwarding
+	replyTo := &tg.InputReplyToMessage{
+		ReplyToMsgID: dst,
+		TopMsgID:     elem.Thread(),
+	}
+	replyTo.SetFlags()
+
+	return replyTo
+}
+
+// lookup returns destination message ID of forwarded source message, in this run or previous runs
+func (f *Forwarder) lookup(from int64, msg int, to int64) (int, bool) {
+	f.mu.Lock()
+	dst, ok := f.mapped[target{tuple: tuple{from: from, msg: msg}, to: to}]
+	f.mu.Unlock()
+	if ok {
+		return dst, true
+	}
+
+	if f.opts.Recorder != nil {
+		return f.opts.Recorder.Lookup(from, msg, to)
+	}
+	return 0, false
+}
+
+func sendAs(elem Elem) tg.InputPeerClass {
+	if p := elem.SendAs(); p != nil {
+		return p.InputPeer()
+	}
+	return nil
+}
+
+// caption returns text of message, which is empty if it's a caption of media and captions are dropped
+func caption(elem Elem, msg *tg.Message) (string, []tg.MessageEntityClass) {
+	if !elem.AsDropCaption() {
+		return msg.Message, msg.Entities
+	}
+
+	switch msg.Media.(type) {
+	case nil, *tg.MessageMediaEmpty, *tg.MessageMediaWebPage:
+		// text message with link preview
+		return msg.Message, msg.Entities
+	}
+	return "", nil
+}
+
 func getReplyTo(thread int) tg.InputReplyToClass {
 	replyTo := &tg.InputReplyToMessage{
 		ReplyToMsgID: thread,
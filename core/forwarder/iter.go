@@ -19,9 +19,23 @@ type Elem interface {
 	From() peers.Peer
 	Msg() *tg.Message
 	To() peers.Peer
-	Thread() int // reply to message/topic
+	Thread() int        // reply to message/topic
+	Schedule() int      // unix time to send scheduled message, 0 means sending now
+	SendAs() peers.Peer // send as channel, nil means sending as self
 
 	AsSilent() bool
 	AsDryRun() bool
-	AsGrouped() bool // detect and forward grouped messages
+	AsGrouped() bool     // detect and forward grouped messages
+	AsDropAuthor() bool  // hide original author in direct mode, cloned messages never have it
+	AsDropCaption() bool // remove captions of media messages
+}
+
+// GroupedElem is an optional interface of Elem to prepare grouped messages fetched by forwarder,
+// e.g. modify their content like Msg
+type GroupedElem interface {
+	Elem
+
+	// Grouped is called with all messages of the album including Msg, and returns messages which should be sent.
+	// Nothing is sent if it returns no message.
+	Grouped(ctx context.Context, msgs []*tg.Message) ([]*tg.Message, error)
 }
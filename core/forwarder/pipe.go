@@ -0,0 +1,120 @@
+package forwarder
+
+import (
+	"io"
+	"sync"
+
+	"github.com/go-faster/errors"
+)
+
+// pipe is a bounded ring buffer between parallel downloader and uploader.
+// Downloader writes parts at any offset within the window, and uploader reads them sequentially
+// as soon as they are contiguous, so that the whole file is never kept in memory or disk.
+type pipe struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	buf   []byte
+	size  int64           // total size of the file
+	read  int64           // offset of the reader
+	avail int64           // end of contiguous written data from the reader
+	parts map[int64]int64 // start -> end of written parts after avail
+	err   error
+}
+
+func newPipe(size, capacity int64) *pipe {
+	p := &pipe{
+		buf:   make([]byte, min(size, capacity)),
+		size:  size,
+		parts: make(map[int64]int64),
+	}
+	p.cond = sync.NewCond(&p.mu)
+
+	return p
+}
+
+// WriteAt blocks until the part fits in the window, parts must not overlap
+func (p *pipe) WriteAt(b []byte, off int64) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	end := off + int64(len(b))
+	if off < p.avail || end > p.size {
+		return 0, errors.Errorf("invalid part [%d, %d) of pipe, available %d, size %d", off, end, p.avail, p.size)
+	}
+	if int64(len(b)) > int64(len(p.buf)) {
+		return 0, errors.Errorf("part size %d is larger than pipe capacity %d", len(b), len(p.buf))
+	}
+
+	for p.err == nil && end > p.read+int64(len(p.buf)) {
+		p.cond.Wait()
+	}
+	if p.err != nil {
+		return 0, p.err
+	}
+
+	p.copyIn(b, off)
+	p.parts[off] = end
+	for {
+		e, ok := p.parts[p.avail]
+		if !ok {
+			break
+		}
+		delete(p.parts, p.avail)
+		p.avail = e
+	}
+	p.cond.Broadcast()
+
+	return len(b), nil
+}
+
+// Read blocks until the next contiguous data is written
+func (p *pipe) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for p.err == nil && p.read == p.avail && p.read < p.size {
+		p.cond.Wait()
+	}
+	if p.read == p.size {
+		return 0, io.EOF
+	}
+	if p.read == p.avail {
+		return 0, p.err
+	}
+
+	n := p.copyOut(b[:min(int64(len(b)), p.avail-p.read)])
+	p.read += int64(n)
+	p.cond.Broadcast()
+
+	return n, nil
+}
+
+// CloseWithError unblocks reader and writer, and they return the err
+func (p *pipe) CloseWithError(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err == nil {
+		p.err = err
+	}
+	p.cond.Broadcast()
+}
+
+func (p *pipe) copyIn(b []byte, off int64) {
+	for len(b) > 0 {
+		i := off % int64(len(p.buf))
+		n := copy(p.buf[i:], b)
+		b, off = b[n:], off+int64(n)
+	}
+}
+
+func (p *pipe) copyOut(b []byte) int {
+	off, total := p.read, 0
+	for len(b) > 0 {
+		i := off % int64(len(p.buf))
+		n := copy(b, p.buf[i:])
+		b, off, total = b[n:], off+int64(n), total+n
+	}
+	return total
+}
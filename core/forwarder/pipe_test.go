@@ -0,0 +1,159 @@
+package forwarder
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipeOutOfOrder(t *testing.T) {
+	data := make([]byte, 64)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	p := newPipe(int64(len(data)), 64)
+
+	// write parts in reverse order, nothing can be read until the first part arrives
+	for off := 48; off > 0; off -= 16 {
+		n, err := p.WriteAt(data[off:off+16], int64(off))
+		require.NoError(t, err)
+		assert.Equal(t, 16, n)
+		assert.Equal(t, int64(0), p.avail)
+	}
+	_, err := p.WriteAt(data[:16], 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), p.avail)
+	assert.Empty(t, p.parts)
+
+	got, err := io.ReadAll(p)
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+}
+
+func TestPipeWrapAround(t *testing.T) {
+	data := make([]byte, 100)
+	rand.New(rand.NewSource(2)).Read(data)
+
+	// capacity isn't a multiple of part size, so parts are split at the end of buffer
+	p := newPipe(int64(len(data)), 24)
+
+	done := make(chan error, 1)
+	go func() {
+		for off := 0; off < len(data); off += 10 {
+			if _, err := p.WriteAt(data[off:min(off+10, len(data))], int64(off)); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	got := &bytes.Buffer{}
+	b := make([]byte, 7)
+	for {
+		n, err := p.Read(b)
+		got.Write(b[:n])
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, <-done)
+	assert.Equal(t, data, got.Bytes())
+}
+
+func TestPipeInvalidPart(t *testing.T) {
+	p := newPipe(32, 16)
+
+	_, err := p.WriteAt(make([]byte, 8), 28)
+	assert.Error(t, err, "exceeds size")
+	_, err = p.WriteAt(make([]byte, 17), 0)
+	assert.Error(t, err, "exceeds capacity")
+
+	_, err = p.WriteAt(make([]byte, 8), 0)
+	require.NoError(t, err)
+	_, err = p.WriteAt(make([]byte, 8), 0)
+	assert.Error(t, err, "already written")
+}
+
+func TestPipeClose(t *testing.T) {
+	errClosed := errors.New("closed")
+
+	t.Run("reader", func(t *testing.T) {
+		p := newPipe(32, 16)
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := p.Read(make([]byte, 8))
+			done <- err
+		}()
+
+		assertBlocked(t, done)
+		p.CloseWithError(errClosed)
+		assert.ErrorIs(t, waitDone(t, done), errClosed)
+	})
+
+	t.Run("writer", func(t *testing.T) {
+		p := newPipe(32, 16)
+		_, err := p.WriteAt(make([]byte, 16), 0)
+		require.NoError(t, err)
+
+		// window is full until reader consumes data
+		done := make(chan error, 1)
+		go func() {
+			_, err := p.WriteAt(make([]byte, 8), 16)
+			done <- err
+		}()
+
+		assertBlocked(t, done)
+		p.CloseWithError(errClosed)
+		assert.ErrorIs(t, waitDone(t, done), errClosed)
+	})
+
+	t.Run("buffered", func(t *testing.T) {
+		p := newPipe(32, 16)
+		_, err := p.WriteAt([]byte("12345678"), 0)
+		require.NoError(t, err)
+		p.CloseWithError(errClosed)
+		p.CloseWithError(errors.New("ignored"))
+
+		// written data is still readable, and the first error is returned after it
+		b := make([]byte, 16)
+		n, err := p.Read(b)
+		require.NoError(t, err)
+		assert.Equal(t, "12345678", string(b[:n]))
+
+		_, err = p.Read(b)
+		assert.ErrorIs(t, err, errClosed)
+		_, err = p.WriteAt(make([]byte, 8), 8)
+		assert.ErrorIs(t, err, errClosed)
+	})
+}
+
+func assertBlocked(t *testing.T, done <-chan error) {
+	t.Helper()
+
+	select {
+	case err := <-done:
+		t.Fatalf("expected blocked, returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func waitDone(t *testing.T, done <-chan error) error {
+	t.Helper()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(time.Second):
+		t.Fatal("not unblocked")
+		return nil
+	}
+}
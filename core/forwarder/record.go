@@ -0,0 +1,49 @@
+package forwarder
+
+import (
+	"github.com/gotd/td/tg"
+)
+
+// Recorder records messages which are sent successfully, it's optional
+type Recorder interface {
+	// OnSent is called with source message IDs mapped to destination message IDs.
+	// Destination ID is 0 if it can't be found in updates.
+	OnSent(elem Elem, ids map[int]int)
+	// Lookup returns destination message ID of the source message which was forwarded to the peer,
+	// it's used to keep reply chains across runs.
+	Lookup(from int64, msg int, to int64) (int, bool)
+}
+
+// sentIDs maps source message IDs to destination message IDs by random IDs of the request
+func sentIDs(updates tg.UpdatesClass, randoms map[int64]int) map[int]int {
+	ids := make(map[int]int, len(randoms))
+	for _, src := range randoms {
+		ids[src] = 0
+	}
+
+	var list []tg.UpdateClass
+	switch u := updates.(type) {
+	case *tg.Updates:
+		list = u.Updates
+	case *tg.UpdatesCombined:
+		list = u.Updates
+	case *tg.UpdateShort:
+		list = []tg.UpdateClass{u.Update}
+	case *tg.UpdateShortSentMessage:
+		// only returned by sending single message
+		for _, src := range randoms {
+			ids[src] = u.ID
+		}
+		return ids
+	}
+
+	for _, update := range list {
+		if m, ok := update.(*tg.UpdateMessageID); ok {
+			if src, ok := randoms[m.RandomID]; ok {
+				ids[src] = m.ID
+			}
+		}
+	}
+
+	return ids
+}
@@ -0,0 +1,56 @@
+package forwarder
+
+import (
+	"context"
+	"sync"
+)
+
+// sequencer keeps messages sent to the same destination in dispatch order,
+// while media of them can be prepared concurrently.
+type sequencer struct {
+	mu   sync.Mutex
+	last map[int64]chan struct{} // destination -> done channel of the last dispatched message
+}
+
+func newSequencer() *sequencer {
+	return &sequencer{last: make(map[int64]chan struct{})}
+}
+
+// next must be called in dispatch order. It returns wait, which blocks until previous message to
+// the destination is done, and done, which must be called when the current message is done.
+func (s *sequencer) next(to int64) (wait func(ctx context.Context) error, done func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, cur := s.last[to], make(chan struct{})
+	s.last[to] = cur
+
+	wait = func(ctx context.Context) error {
+		if prev == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-prev:
+			return nil
+		}
+	}
+
+	once := sync.Once{}
+	done = func() {
+		once.Do(func() {
+			close(cur)
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			// nobody is waiting for it, release the channel
+			if s.last[to] == cur {
+				delete(s.last, to)
+			}
+		})
+	}
+
+	return wait, done
+}
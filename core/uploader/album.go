@@ -0,0 +1,135 @@
+package uploader
+
+import (
+	"sort"
+
+	"github.com/lshcx/tdl/core/util/mediautil"
+	"github.com/lshcx/tdl/core/util/tutil"
+)
+
+// albumKey decides which media can be sent in the same album
+type albumKey struct {
+	peer  int64
+	topic int
+	reply int
+	group string
+	kind  albumKind
+}
+
+// albumKind is the media compatibility of album.
+// Telegram only allows photos and videos to be mixed, documents and audios must be grouped separately.
+type albumKind int
+
+const (
+	albumKindMedia albumKind = iota
+	albumKindAudio
+	albumKindDocument
+	albumKindSingle // voice, round video, animation and sticker can't be sent in album
+)
+
+func albumKeyOf(elem Elem) albumKey {
+	return albumKey{
+		peer:  tutil.GetInputPeerID(elem.To()),
+		topic: elem.Topic(),
+		reply: elem.Reply(),
+		group: elem.Group(),
+		kind:  albumKindOf(elem),
+	}
+}
+
+// albumKindOf must be consistent with media building in uploadFile
+func albumKindOf(elem Elem) albumKind {
+	mime := elem.Mime()
+
+	switch elem.Mode() {
+	case ModeVoice, ModeRound, ModeAnimation, ModeSticker:
+		return albumKindSingle
+	case ModeDocument:
+		return albumKindDocument
+	}
+
+	switch {
+	case asPhoto(elem), mediautil.IsVideo(mime):
+		return albumKindMedia
+	case mediautil.IsAudio(mime):
+		return albumKindAudio
+	default:
+		return albumKindDocument
+	}
+}
+
+func asPhoto(elem Elem) bool {
+	mime := elem.Mime()
+	return elem.Mode() == ModeAuto && mediautil.IsImage(mime) && mime != "image/webp" && elem.AsPhoto()
+}
+
+// addMedia inserts uploaded media to pending list by index. Caller must hold u.mu.
+func (u *Uploader) addMedia(mb mediaBinding) {
+	u.albumMedia = append(u.albumMedia, mb)
+	sort.Slice(u.albumMedia, func(i, j int) bool {
+		return u.albumMedia[i].index < u.albumMedia[j].index
+	})
+}
+
+// addFailed marks the index as failed, so that it won't block following albums. Caller must hold u.mu.
+func (u *Uploader) addFailed(index int) {
+	u.failed[index] = struct{}{}
+}
+
+// nextAlbum pops the next album which is ready to be sent, or returns nil if it's not ready.
+// An album is ready when it reaches MaxAlbumSize or the following media belongs to another album.
+// If final is true, remaining media is returned without waiting for missing indexes. Caller must hold u.mu.
+func (u *Uploader) nextAlbum(final bool) []mediaBinding {
+	u.albumIndex = u.skipFailed(u.albumIndex)
+
+	if len(u.albumMedia) == 0 {
+		return nil
+	}
+	if !final && u.albumMedia[0].index != u.albumIndex {
+		return nil
+	}
+
+	size := max(u.opts.MaxAlbumSize, 1)
+	key := albumKeyOf(u.albumMedia[0].elem)
+	if key.kind == albumKindSingle {
+		size = 1
+	}
+	n, ready := 1, final
+	for n < size {
+		if n >= len(u.albumMedia) {
+			break
+		}
+
+		mb := u.albumMedia[n]
+		if !final && mb.index != u.skipFailed(u.albumMedia[n-1].index+1) {
+			// wait for missing media
+			break
+		}
+		if albumKeyOf(mb.elem) != key {
+			ready = true
+			break
+		}
+		n++
+	}
+	if n == size {
+		ready = true
+	}
+	if !ready {
+		return nil
+	}
+
+	album := u.albumMedia[:n]
+	u.albumMedia = u.albumMedia[n:]
+	u.albumIndex = album[n-1].index + 1
+
+	return album
+}
+
+func (u *Uploader) skipFailed(index int) int {
+	for {
+		if _, ok := u.failed[index]; !ok {
+			return index
+		}
+		index++
+	}
+}
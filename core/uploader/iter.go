@@ -23,12 +23,18 @@ type Elem interface {
 	File() File
 	Thumb() (string, bool)
 	To() tg.InputPeerClass
+	Topic() int // forum topic id, 0 means no topic
+	Reply() int // reply to message id, 0 means no reply
 	AsPhoto() bool
+	Mode() Mode
 	Mime() string
 	Duration() float64
 	Width() int
 	Height() int
 	Codec() string
+	Title() string     // audio title
+	Performer() string // audio performer
 	Caption() string
+	Group() string // album group key, media in different groups are never sent in the same album
 	DoRemove() error
 }
@@ -0,0 +1,80 @@
+package uploader
+
+import (
+	"context"
+
+	"github.com/go-faster/errors"
+	"github.com/gotd/td/tg"
+)
+
+// default file parts limits, refer to https://core.telegram.org/api/config#upload-max-fileparts-default
+const (
+	defaultMaxFileParts = 4000
+	premiumMaxFileParts = 8000
+)
+
+// Limit is the upload size limit of the logged-in account
+type Limit struct {
+	Premium  bool
+	MaxParts int   // max parts of a file, each part is MaxPartSize
+	MaxSize  int64 // max file size in bytes
+}
+
+// GetLimit detects premium status of the logged-in user and upload_max_fileparts of server app config.
+// If app config is unavailable, default Telegram limits are used.
+func GetLimit(ctx context.Context, client *tg.Client) (*Limit, error) {
+	users, err := client.UsersGetUsers(ctx, []tg.InputUserClass{&tg.InputUserSelf{}})
+	if err != nil {
+		return nil, errors.Wrap(err, "get self")
+	}
+	if len(users) == 0 {
+		return nil, errors.New("self user not found")
+	}
+	self, ok := users[0].(*tg.User)
+	if !ok {
+		return nil, errors.Errorf("unexpected self user type: %T", users[0])
+	}
+
+	limit := &Limit{Premium: self.Premium, MaxParts: defaultMaxFileParts}
+	if limit.Premium {
+		limit.MaxParts = premiumMaxFileParts
+	}
+
+	key := "upload_max_fileparts_default"
+	if limit.Premium {
+		key = "upload_max_fileparts_premium"
+	}
+	if parts, ok := appConfigInt(ctx, client, key); ok && parts > 0 {
+		limit.MaxParts = parts
+	}
+
+	limit.MaxSize = int64(limit.MaxParts) * MaxPartSize
+	return limit, nil
+}
+
+// appConfigInt returns number value of key in app config, refer to https://core.telegram.org/api/config#client-configuration
+func appConfigInt(ctx context.Context, client *tg.Client, key string) (int, bool) {
+	cfg, err := client.HelpGetAppConfig(ctx, 0)
+	if err != nil {
+		return 0, false
+	}
+	modified, ok := cfg.AsModified()
+	if !ok {
+		return 0, false
+	}
+	obj, ok := modified.Config.(*tg.JSONObject)
+	if !ok {
+		return 0, false
+	}
+
+	for _, v := range obj.Value {
+		if v.Key != key {
+			continue
+		}
+		if n, ok := v.Value.(*tg.JSONNumber); ok {
+			return int(n.Value), true
+		}
+	}
+
+	return 0, false
+}
@@ -16,10 +16,10 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/gotd/td/telegram/message/entity"
-	"github.com/gotd/td/telegram/message/html"
 	"github.com/gotd/td/telegram/message/styling"
 	"github.com/lshcx/tdl/core/tmedia"
 	"github.com/lshcx/tdl/core/util/mediautil"
+	"github.com/lshcx/tdl/core/util/textutil"
 	"github.com/lshcx/tdl/pkg/logger"
 	"github.com/samber/lo"
 )
@@ -35,10 +35,18 @@ type mediaBinding struct {
 
 type Uploader struct {
 	opts       Options
-	albumMedia []mediaBinding
+	albumMedia []mediaBinding   // uploaded media waiting to be sent, sorted by index
+	albumIndex int              // index of the first media of next album
+	failed     map[int]struct{} // indexes of media failed to upload
 	mu         sync.Mutex
 }
 
+//go:generate go-enum --values --names --flag --nocase
+
+// Mode decides how the file is sent. Auto means it's decided by MIME type.
+// ENUM(auto, document, voice, round, animation, sticker)
+type Mode int
+
 type Options struct {
 	Client       *tg.Client
 	Threads      int
@@ -47,6 +55,7 @@ type Options struct {
 	Progress     Progress
 	AsAlbum      bool
 	MaxAlbumSize int
+	ParseMode    textutil.ParseMode // parse mode of caption
 }
 
 func New(o Options) *Uploader {
@@ -58,9 +67,9 @@ func (u *Uploader) Upload(ctx context.Context) error {
 	wg.SetLimit(u.opts.Limit)
 
 	u.albumMedia = make([]mediaBinding, 0)
+	u.albumIndex = 0
+	u.failed = make(map[int]struct{})
 	index := 0
-	albumIndex := 0
-	hasCaption := true
 
 	// 用于跟踪是否被用户取消
 	var canceled bool
@@ -80,7 +89,13 @@ func (u *Uploader) Upload(ctx context.Context) error {
 			defer func() { u.opts.Progress.OnDone(currentElem, rerr) }()
 
 			media, err := u.uploadFile(wgctx, currentElem)
+
+			u.mu.Lock()
+			defer u.mu.Unlock()
+
 			if err != nil {
+				u.addFailed(currentID)
+
 				if errors.Is(err, context.Canceled) {
 					canceled = true
 					// 不立即返回错误，让已上传的文件能被处理
@@ -90,36 +105,21 @@ func (u *Uploader) Upload(ctx context.Context) error {
 				// don't return error, just log it
 				logger.Error("Error: upload file", zap.String("file: ", currentElem.File().Name()), zap.Error(err))
 				fmt.Printf("Error: upload file %s failed: %v\n", currentElem.File().Name(), err)
-				return nil
+			} else {
+				u.addMedia(mediaBinding{
+					index: currentID,
+					elem:  currentElem,
+					media: media,
+				})
 			}
 
-			u.mu.Lock()
-			defer u.mu.Unlock()
-
-			// insert media to u.albumMedia by index
-			u.albumMedia = append(u.albumMedia, mediaBinding{
-				index: currentID,
-				elem:  currentElem,
-				media: media,
-			})
-			sort.Slice(u.albumMedia, func(i, j int) bool {
-				return u.albumMedia[i].index < u.albumMedia[j].index
-			})
-
-			// 如果前maxAlbumSize个元素的index是连续的并且第一个元素的index是albumIndex，则取出前maxAlbumSize个元素
-			if len(u.albumMedia) >= u.opts.MaxAlbumSize && u.albumMedia[0].index == albumIndex && u.albumMedia[0].index+u.opts.MaxAlbumSize-1 == u.albumMedia[u.opts.MaxAlbumSize-1].index {
-				albumMedia := u.albumMedia[:u.opts.MaxAlbumSize]
-				u.albumMedia = u.albumMedia[u.opts.MaxAlbumSize:]
-				if err := u.send(albumMedia, hasCaption); err != nil {
+			// send all albums which are ready, failed media may also unblock following albums
+			for album := u.nextAlbum(false); album != nil; album = u.nextAlbum(false) {
+				if err := u.send(album); err != nil {
 					// don't return error, just log it
 					logger.Error("Error: send uploaded files", zap.Error(err))
 					fmt.Printf("Error: send uploaded files failed: %v\n", err)
-					return nil
-				}
-				if hasCaption {
-					hasCaption = false
 				}
-				albumIndex += u.opts.MaxAlbumSize
 			}
 
 			return nil
@@ -143,8 +143,10 @@ func (u *Uploader) Upload(ctx context.Context) error {
 	}
 
 	// 发送已上传的文件
-	if err := u.send(u.albumMedia, hasCaption); err != nil {
-		return errors.Wrap(err, "send uploaded files")
+	for album := u.nextAlbum(true); album != nil; album = u.nextAlbum(true) {
+		if err := u.send(album); err != nil {
+			return errors.Wrap(err, "send uploaded files")
+		}
 	}
 
 	// 如果是用户取消，最后再返回取消错误
@@ -155,7 +157,7 @@ func (u *Uploader) Upload(ctx context.Context) error {
 	return nil
 }
 
-func (u *Uploader) send(mbs []mediaBinding, hasCaption bool) error {
+func (u *Uploader) send(mbs []mediaBinding) error {
 	if len(mbs) > 0 {
 		// 创建新的 context 用于发送
 		sendCtx := context.Background()
@@ -166,8 +168,9 @@ func (u *Uploader) send(mbs []mediaBinding, hasCaption bool) error {
 		})
 
 		// 发送已上传的文件
-		if u.opts.AsAlbum {
-			if err := u.sendMultiMedia(sendCtx, mbs, hasCaption); err != nil {
+		// album with only one media is sent as a single message
+		if u.opts.AsAlbum && len(mbs) > 1 {
+			if err := u.sendMultiMedia(sendCtx, mbs); err != nil {
 				return errors.Wrap(err, "send multi media")
 			}
 		} else {
@@ -214,54 +217,126 @@ func (u *Uploader) uploadFile(ctx context.Context, elem Elem) (tg.InputMediaClas
 	// get mine
 	mime := elem.Mime()
 
-	// build media based on mime
+	// build media based on mode and mime
 	var media tg.InputMediaClass
-	switch {
-	case mediautil.IsImage(mime) && mime != "image/webp" && elem.AsPhoto():
+	switch mode := elem.Mode(); {
+	case asPhoto(elem):
 		photo := &tg.InputMediaUploadedPhoto{
 			File: f,
 		}
 		photo.SetFlags()
 		media = photo
-	case mediautil.IsVideo(mime):
+	case mode == ModeDocument:
+		doc := &tg.InputMediaUploadedDocument{
+			File:       f,
+			MimeType:   mime,
+			Attributes: attributes,
+			ForceFile:  true,
+		}
+		doc.Thumb = u.uploadThumb(ctx, elem)
 
-		videoAttributes := &tg.DocumentAttributeVideo{
-			SupportsStreaming: true,
+		doc.SetFlags()
+		media = doc
+	case mode == ModeVoice:
+		voice := &tg.DocumentAttributeAudio{
+			Voice:    true,
+			Duration: int(elem.Duration()),
 		}
-		if elem.Duration() > 0 {
-			videoAttributes.Duration = elem.Duration()
+		voice.SetFlags()
+		attributes = append(attributes, voice)
+		doc := &tg.InputMediaUploadedDocument{
+			File:       f,
+			MimeType:   mime,
+			Attributes: attributes,
 		}
-		if elem.Width() > 0 {
-			videoAttributes.W = elem.Width()
+
+		doc.SetFlags()
+		media = doc
+	case mode == ModeRound:
+		video := videoAttribute(elem)
+		video.RoundMessage = true
+		video.SetFlags()
+		attributes = append(attributes, video)
+		doc := &tg.InputMediaUploadedDocument{
+			File:       f,
+			MimeType:   mime,
+			Attributes: attributes,
 		}
-		if elem.Height() > 0 {
-			videoAttributes.H = elem.Height()
+		doc.Thumb = u.uploadThumb(ctx, elem)
+
+		doc.SetFlags()
+		media = doc
+	case mode == ModeAnimation:
+		// GIF-like animation is a muted video with animated attribute
+		attributes = append(attributes, &tg.DocumentAttributeAnimated{})
+		if mediautil.IsVideo(mime) {
+			attributes = append(attributes, videoAttribute(elem))
 		}
-		videoAttributes.SetFlags()
-		attributes = append(attributes, videoAttributes)
 		doc := &tg.InputMediaUploadedDocument{
 			File:         f,
 			MimeType:     mime,
 			Attributes:   attributes,
 			NosoundVideo: true,
 		}
-		// set thumbnail if has
-		if thumbPath, ok := elem.Thumb(); ok {
-			if thumb, err := uploader.NewUploader(u.opts.Client).
-				FromPath(ctx, thumbPath); err == nil {
-				doc.Thumb = thumb
-			}
+		doc.Thumb = u.uploadThumb(ctx, elem)
+
+		doc.SetFlags()
+		media = doc
+	case mode == ModeSticker:
+		sticker := &tg.DocumentAttributeSticker{
+			Alt:        "",
+			Stickerset: &tg.InputStickerSetEmpty{},
+		}
+		sticker.SetFlags()
+		attributes = append(attributes, sticker)
+
+		switch {
+		case strings.HasSuffix(elem.File().Name(), ".tgs"): // animated sticker
+			mime = "application/x-tgsticker"
+		case mediautil.IsVideo(mime): // video sticker
+			attributes = append(attributes, videoAttribute(elem))
+		case elem.Width() > 0 && elem.Height() > 0:
+			attributes = append(attributes, &tg.DocumentAttributeImageSize{
+				W: elem.Width(),
+				H: elem.Height(),
+			})
+		}
+		doc := &tg.InputMediaUploadedDocument{
+			File:       f,
+			MimeType:   mime,
+			Attributes: attributes,
 		}
 
+		doc.SetFlags()
+		media = doc
+	case mediautil.IsVideo(mime):
+		attributes = append(attributes, videoAttribute(elem))
+		doc := &tg.InputMediaUploadedDocument{
+			File:         f,
+			MimeType:     mime,
+			Attributes:   attributes,
+			NosoundVideo: true,
+		}
+		// set thumbnail if has
+		doc.Thumb = u.uploadThumb(ctx, elem)
+
 		doc.SetFlags()
 		media = doc
 	case mediautil.IsAudio(mime):
-		attributes = append(attributes, &tg.DocumentAttributeAudio{})
+		audioAttribute := &tg.DocumentAttributeAudio{
+			Duration:  int(elem.Duration()),
+			Title:     elem.Title(),
+			Performer: elem.Performer(),
+		}
+		audioAttribute.SetFlags()
+		attributes = append(attributes, audioAttribute)
 		audio := &tg.InputMediaUploadedDocument{
 			File:       f,
 			MimeType:   mime,
 			Attributes: attributes,
 		}
+		audio.Thumb = u.uploadThumb(ctx, elem)
+
 		audio.SetFlags()
 		media = audio
 	default:
@@ -299,6 +374,39 @@ func (u *Uploader) uploadFile(ctx context.Context, elem Elem) (tg.InputMediaClas
 	return inputMedia, nil
 }
 
+func videoAttribute(elem Elem) *tg.DocumentAttributeVideo {
+	video := &tg.DocumentAttributeVideo{
+		SupportsStreaming: true,
+	}
+	if elem.Duration() > 0 {
+		video.Duration = elem.Duration()
+	}
+	if elem.Width() > 0 {
+		video.W = elem.Width()
+	}
+	if elem.Height() > 0 {
+		video.H = elem.Height()
+	}
+	video.SetFlags()
+
+	return video
+}
+
+// uploadThumb returns nil if elem has no thumbnail or it fails to upload
+func (u *Uploader) uploadThumb(ctx context.Context, elem Elem) tg.InputFileClass {
+	thumbPath, ok := elem.Thumb()
+	if !ok {
+		return nil
+	}
+
+	thumb, err := uploader.NewUploader(u.opts.Client).FromPath(ctx, thumbPath)
+	if err != nil {
+		return nil
+	}
+
+	return thumb
+}
+
 func (u *Uploader) sendSingleMedia(ctx context.Context, mb mediaBinding) error {
 	// media := mb.media
 	// elem := mb.elem
@@ -316,6 +424,7 @@ func (u *Uploader) sendSingleMedia(ctx context.Context, mb mediaBinding) error {
 
 	req := &tg.MessagesSendMediaRequest{
 		Peer:     mb.elem.To(),
+		ReplyTo:  getReplyTo(mb.elem.Topic(), mb.elem.Reply()),
 		Media:    single.Media,
 		Message:  single.Message,
 		Entities: single.Entities,
@@ -338,9 +447,10 @@ func (u *Uploader) sendSingleMedia(ctx context.Context, mb mediaBinding) error {
 	return nil
 }
 
-func (u *Uploader) sendMultiMedia(ctx context.Context, mbs []mediaBinding, hasCaption bool) error {
+func (u *Uploader) sendMultiMedia(ctx context.Context, mbs []mediaBinding) error {
 
-	isFirst := true
+	// only the first media with caption is captioned, which is the first file of album group
+	captioned := false
 	// build inputSingleMedia list
 	inputSingleMedias := make([]tg.InputSingleMedia, 0, len(mbs))
 	elems := make([]Elem, 0, len(mbs))
@@ -349,12 +459,11 @@ func (u *Uploader) sendMultiMedia(ctx context.Context, mbs []mediaBinding, hasCa
 			Media:    mb.media,
 			RandomID: time.Now().UnixNano(),
 		}
-		if hasCaption && isFirst {
-			// single.Message = mb.elem.Caption()
+		if !captioned && mb.elem.Caption() != "" {
 			if err := u.formatCaption(&single, mb.elem.Caption()); err != nil {
 				return errors.Wrap(err, "format caption")
 			}
-			isFirst = false
+			captioned = true
 		}
 		single.SetFlags()
 		inputSingleMedias = append(inputSingleMedias, single)
@@ -364,13 +473,14 @@ func (u *Uploader) sendMultiMedia(ctx context.Context, mbs []mediaBinding, hasCa
 	}
 
 	// split into batches and send
-	maxAlbumSize := min(u.opts.MaxAlbumSize, 10)
+	maxAlbumSize := min(max(u.opts.MaxAlbumSize, 1), 10)
 	// fmt.Printf("maxAlbumSize: %d\n", maxAlbumSize)
 	for i := 0; i < len(inputSingleMedias); i += maxAlbumSize {
 		// fmt.Printf("Index: %d, total: %d\n", i, len(inputSingleMedias))
 		batch := inputSingleMedias[i:min(i+maxAlbumSize, len(inputSingleMedias))]
 		req := &tg.MessagesSendMultiMediaRequest{
 			Peer:       mbs[0].elem.To(),
+			ReplyTo:    getReplyTo(mbs[0].elem.Topic(), mbs[0].elem.Reply()),
 			MultiMedia: batch,
 			Silent:     false,
 		}
@@ -395,11 +505,8 @@ func (u *Uploader) sendMultiMedia(ctx context.Context, mbs []mediaBinding, hasCa
 func (u *Uploader) formatCaption(media *tg.InputSingleMedia, caption string) error {
 
 	cb := &entity.Builder{}
-	if err := html.HTML(strings.NewReader(caption), cb, html.Options{
-		UserResolver:          nil,
-		DisableTelegramEscape: false,
-	}); err != nil {
-		return errors.Wrap(err, "parse caption HTML")
+	if err := textutil.Parse(u.opts.ParseMode, caption, cb); err != nil {
+		return errors.Wrapf(err, "parse caption %s", u.opts.ParseMode)
 	}
 
 	caption_opts := styling.Custom(func(eb *entity.Builder) error {
@@ -421,3 +528,24 @@ func (u *Uploader) formatCaption(media *tg.InputSingleMedia, caption string) err
 	return nil
 
 }
+
+// getReplyTo returns nil if neither topic nor reply is set.
+func getReplyTo(topic, reply int) tg.InputReplyToClass {
+	if topic == 0 && reply == 0 {
+		return nil
+	}
+
+	replyTo := &tg.InputReplyToMessage{}
+	switch {
+	case reply == 0: // message in topic is a reply to topic's top message
+		replyTo.ReplyToMsgID = topic
+	case topic == 0:
+		replyTo.ReplyToMsgID = reply
+	default:
+		replyTo.ReplyToMsgID = reply
+		replyTo.TopMsgID = topic
+	}
+	replyTo.SetFlags()
+
+	return replyTo
+}
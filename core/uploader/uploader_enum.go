@@ -0,0 +1,127 @@
+// Code generated by go-enum DO NOT EDIT.
+// Version: 0.5.8
+// Revision: 3d844c8ecc59661ed7aa17bfd65727bc06a60ad8
+// Build Date: 2023-09-18T14:55:21Z
+// Built By: goreleaser
+
+package uploader
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// ModeAuto is a Mode of type Auto.
+	ModeAuto Mode = iota
+	// ModeDocument is a Mode of type Document.
+	ModeDocument
+	// ModeVoice is a Mode of type Voice.
+	ModeVoice
+	// ModeRound is a Mode of type Round.
+	ModeRound
+	// ModeAnimation is a Mode of type Animation.
+	ModeAnimation
+	// ModeSticker is a Mode of type Sticker.
+	ModeSticker
+)
+
+var ErrInvalidMode = fmt.Errorf("not a valid Mode, try [%s]", strings.Join(_ModeNames, ", "))
+
+const _ModeName = "autodocumentvoiceroundanimationsticker"
+
+var _ModeNames = []string{
+	_ModeName[0:4],
+	_ModeName[4:12],
+	_ModeName[12:17],
+	_ModeName[17:22],
+	_ModeName[22:31],
+	_ModeName[31:38],
+}
+
+// ModeNames returns a list of possible string values of Mode.
+func ModeNames() []string {
+	tmp := make([]string, len(_ModeNames))
+	copy(tmp, _ModeNames)
+	return tmp
+}
+
+// ModeValues returns a list of the values for Mode
+func ModeValues() []Mode {
+	return []Mode{
+		ModeAuto,
+		ModeDocument,
+		ModeVoice,
+		ModeRound,
+		ModeAnimation,
+		ModeSticker,
+	}
+}
+
+var _ModeMap = map[Mode]string{
+	ModeAuto:      _ModeName[0:4],
+	ModeDocument:  _ModeName[4:12],
+	ModeVoice:     _ModeName[12:17],
+	ModeRound:     _ModeName[17:22],
+	ModeAnimation: _ModeName[22:31],
+	ModeSticker:   _ModeName[31:38],
+}
+
+// String implements the Stringer interface.
+func (x Mode) String() string {
+	if str, ok := _ModeMap[x]; ok {
+		return str
+	}
+	return fmt.Sprintf("Mode(%d)", x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x Mode) IsValid() bool {
+	_, ok := _ModeMap[x]
+	return ok
+}
+
+var _ModeValue = map[string]Mode{
+	_ModeName[0:4]:                    ModeAuto,
+	strings.ToLower(_ModeName[0:4]):   ModeAuto,
+	_ModeName[4:12]:                   ModeDocument,
+	strings.ToLower(_ModeName[4:12]):  ModeDocument,
+	_ModeName[12:17]:                  ModeVoice,
+	strings.ToLower(_ModeName[12:17]): ModeVoice,
+	_ModeName[17:22]:                  ModeRound,
+	strings.ToLower(_ModeName[17:22]): ModeRound,
+	_ModeName[22:31]:                  ModeAnimation,
+	strings.ToLower(_ModeName[22:31]): ModeAnimation,
+	_ModeName[31:38]:                  ModeSticker,
+	strings.ToLower(_ModeName[31:38]): ModeSticker,
+}
+
+// ParseMode attempts to convert a string to a Mode.
+func ParseMode(name string) (Mode, error) {
+	if x, ok := _ModeValue[name]; ok {
+		return x, nil
+	}
+	// Case insensitive parse, do a separate lookup to prevent unnecessary cost of lowercasing a string if we don't need to.
+	if x, ok := _ModeValue[strings.ToLower(name)]; ok {
+		return x, nil
+	}
+	return Mode(0), fmt.Errorf("%s is %w", name, ErrInvalidMode)
+}
+
+// Set implements the Golang flag.Value interface func.
+func (x *Mode) Set(val string) error {
+	v, err := ParseMode(val)
+	*x = v
+	return err
+}
+
+// Get implements the Golang flag.Getter interface func.
+func (x *Mode) Get() interface{} {
+	return *x
+}
+
+// Type implements the github.com/spf13/pFlag Value interface.
+func (x *Mode) Type() string {
+	return "Mode"
+}
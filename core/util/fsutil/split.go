@@ -0,0 +1,249 @@
+package fsutil
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/go-faster/errors"
+)
+
+//go:generate go-enum --values --names --flag --nocase
+
+// SplitMode is the format of split parts.
+// Raw is plain byte-range parts: file.ext.001, file.ext.002, ...
+// Zip is a stored zip archive split into volumes: file.ext.zip.001, file.ext.zip.002, ..., which can be opened by 7-Zip directly.
+// ENUM(none, raw, zip)
+type SplitMode int
+
+var partRe = regexp.MustCompile(`^(.+)\.(\d{3,})$`)
+
+// PartName returns path of the n-th (1-based) part of base
+func PartName(base string, n int) string {
+	return fmt.Sprintf("%s.%03d", base, n)
+}
+
+// ParsePartName returns base path and 1-based index of part path like `file.zip.001`
+func ParsePartName(path string) (string, int, bool) {
+	m := partRe.FindStringSubmatch(path)
+	if m == nil {
+		return "", 0, false
+	}
+
+	n, err := strconv.Atoi(m[2])
+	if err != nil || n < 1 {
+		return "", 0, false
+	}
+
+	return m[1], n, true
+}
+
+// SplitFile splits file at path into parts which are not larger than partSize, and returns paths of parts.
+// Parts are created in dir with the name of path. Created parts are removed if error occurs.
+func SplitFile(path, dir string, partSize int64, mode SplitMode) (_ []string, rerr error) {
+	if partSize <= 0 {
+		return nil, errors.Errorf("invalid part size: %d", partSize)
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "open file")
+	}
+	defer src.Close()
+
+	stat, err := src.Stat()
+	if err != nil {
+		return nil, errors.Wrap(err, "stat file")
+	}
+
+	base := filepath.Join(dir, filepath.Base(path))
+	if mode == SplitModeZip {
+		base += ".zip"
+	}
+	pw := &partWriter{base: base, size: partSize}
+	defer func() {
+		if err := pw.Close(); err != nil && rerr == nil {
+			rerr = err
+		}
+		if rerr != nil {
+			pw.remove()
+		}
+	}()
+
+	switch mode {
+	case SplitModeRaw:
+		if _, err = io.Copy(pw, src); err != nil {
+			return nil, errors.Wrap(err, "write parts")
+		}
+	case SplitModeZip:
+		zw := zip.NewWriter(pw)
+		w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     filepath.Base(path),
+			Method:   zip.Store, // media files are mostly compressed, storing is faster
+			Modified: stat.ModTime(),
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "create zip entry")
+		}
+		if _, err = io.Copy(w, src); err != nil {
+			return nil, errors.Wrap(err, "write parts")
+		}
+		if err = zw.Close(); err != nil {
+			return nil, errors.Wrap(err, "close zip")
+		}
+	default:
+		return nil, errors.Errorf("unsupported split mode: %s", mode)
+	}
+
+	return pw.parts, nil
+}
+
+// JoinFiles concatenates parts in order into dst.
+func JoinFiles(parts []string, dst string) (rerr error) {
+	tmp := dst + ".joining"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return errors.Wrap(err, "create file")
+	}
+	defer func() {
+		if rerr != nil {
+			_ = out.Close()
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	for _, part := range parts {
+		if err = appendFile(out, part); err != nil {
+			return errors.Wrapf(err, "append part: %s", part)
+		}
+	}
+
+	if err = out.Close(); err != nil {
+		return errors.Wrap(err, "close file")
+	}
+
+	return os.Rename(tmp, dst)
+}
+
+// UnzipSingle extracts the only entry of zip file at path into dir, and returns path of extracted file.
+func UnzipSingle(path, dir string) (_ string, rerr error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return "", errors.Wrap(err, "open zip")
+	}
+	defer zr.Close()
+
+	if len(zr.File) != 1 {
+		return "", errors.Errorf("zip should contain only one file, got %d", len(zr.File))
+	}
+
+	entry := zr.File[0]
+	src, err := entry.Open()
+	if err != nil {
+		return "", errors.Wrap(err, "open zip entry")
+	}
+	defer src.Close()
+
+	// keep base name only to avoid path traversal
+	dst := filepath.Join(dir, filepath.Base(entry.Name))
+	out, err := os.Create(dst)
+	if err != nil {
+		return "", errors.Wrap(err, "create file")
+	}
+	defer func() {
+		if err := out.Close(); err != nil && rerr == nil {
+			rerr = errors.Wrap(err, "close file")
+		}
+		if rerr != nil {
+			_ = os.Remove(dst)
+		}
+	}()
+
+	if _, err = io.Copy(out, src); err != nil {
+		return "", errors.Wrap(err, "extract zip entry")
+	}
+
+	return dst, nil
+}
+
+func appendFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// partWriter writes data into parts, and rotates to next part when current part is full
+type partWriter struct {
+	base    string
+	size    int64
+	cur     *os.File
+	written int64
+	parts   []string
+}
+
+func (w *partWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		if w.cur == nil || w.written >= w.size {
+			if err := w.next(); err != nil {
+				return n, err
+			}
+		}
+
+		chunk := p
+		if remain := w.size - w.written; int64(len(chunk)) > remain {
+			chunk = chunk[:remain]
+		}
+
+		m, err := w.cur.Write(chunk)
+		n += m
+		w.written += int64(m)
+		if err != nil {
+			return n, err
+		}
+		p = p[m:]
+	}
+
+	return n, nil
+}
+
+func (w *partWriter) next() error {
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	path := PartName(w.base, len(w.parts)+1)
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "create part")
+	}
+	w.cur, w.written = f, 0
+	w.parts = append(w.parts, path)
+
+	return nil
+}
+
+func (w *partWriter) Close() error {
+	if w.cur == nil {
+		return nil
+	}
+
+	err := w.cur.Close()
+	w.cur = nil
+	return err
+}
+
+func (w *partWriter) remove() {
+	for _, part := range w.parts {
+		_ = os.Remove(part)
+	}
+}
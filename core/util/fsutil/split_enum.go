@@ -0,0 +1,106 @@
+// Code generated by go-enum DO NOT EDIT.
+// Version: 0.5.8
+// Revision: 3d844c8ecc59661ed7aa17bfd65727bc06a60ad8
+// Build Date: 2023-09-18T14:55:21Z
+// Built By: goreleaser
+
+package fsutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// SplitModeNone is a SplitMode of type None.
+	SplitModeNone SplitMode = iota
+	// SplitModeRaw is a SplitMode of type Raw.
+	SplitModeRaw
+	// SplitModeZip is a SplitMode of type Zip.
+	SplitModeZip
+)
+
+var ErrInvalidSplitMode = fmt.Errorf("not a valid SplitMode, try [%s]", strings.Join(_SplitModeNames, ", "))
+
+const _SplitModeName = "nonerawzip"
+
+var _SplitModeNames = []string{
+	_SplitModeName[0:4],
+	_SplitModeName[4:7],
+	_SplitModeName[7:10],
+}
+
+// SplitModeNames returns a list of possible string values of SplitMode.
+func SplitModeNames() []string {
+	tmp := make([]string, len(_SplitModeNames))
+	copy(tmp, _SplitModeNames)
+	return tmp
+}
+
+// SplitModeValues returns a list of the values for SplitMode
+func SplitModeValues() []SplitMode {
+	return []SplitMode{
+		SplitModeNone,
+		SplitModeRaw,
+		SplitModeZip,
+	}
+}
+
+var _SplitModeMap = map[SplitMode]string{
+	SplitModeNone: _SplitModeName[0:4],
+	SplitModeRaw:  _SplitModeName[4:7],
+	SplitModeZip:  _SplitModeName[7:10],
+}
+
+// String implements the Stringer interface.
+func (x SplitMode) String() string {
+	if str, ok := _SplitModeMap[x]; ok {
+		return str
+	}
+	return fmt.Sprintf("SplitMode(%d)", x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x SplitMode) IsValid() bool {
+	_, ok := _SplitModeMap[x]
+	return ok
+}
+
+var _SplitModeValue = map[string]SplitMode{
+	_SplitModeName[0:4]:                   SplitModeNone,
+	strings.ToLower(_SplitModeName[0:4]):  SplitModeNone,
+	_SplitModeName[4:7]:                   SplitModeRaw,
+	strings.ToLower(_SplitModeName[4:7]):  SplitModeRaw,
+	_SplitModeName[7:10]:                  SplitModeZip,
+	strings.ToLower(_SplitModeName[7:10]): SplitModeZip,
+}
+
+// ParseSplitMode attempts to convert a string to a SplitMode.
+func ParseSplitMode(name string) (SplitMode, error) {
+	if x, ok := _SplitModeValue[name]; ok {
+		return x, nil
+	}
+	// Case insensitive parse, do a separate lookup to prevent unnecessary cost of lowercasing a string if we don't need to.
+	if x, ok := _SplitModeValue[strings.ToLower(name)]; ok {
+		return x, nil
+	}
+	return SplitMode(0), fmt.Errorf("%s is %w", name, ErrInvalidSplitMode)
+}
+
+// Set implements the Golang flag.Value interface func.
+func (x *SplitMode) Set(val string) error {
+	v, err := ParseSplitMode(val)
+	*x = v
+	return err
+}
+
+// Get implements the Golang flag.Getter interface func.
+func (x *SplitMode) Get() interface{} {
+	return *x
+}
+
+// Type implements the github.com/spf13/pFlag Value interface.
+func (x *SplitMode) Type() string {
+	return "SplitMode"
+}
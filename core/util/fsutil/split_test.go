@@ -0,0 +1,86 @@
+package fsutil
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePartName(t *testing.T) {
+	tests := []struct {
+		path  string
+		base  string
+		index int
+		ok    bool
+	}{
+		{path: "file.bin.001", base: "file.bin", index: 1, ok: true},
+		{path: "dir/file.bin.zip.012", base: "dir/file.bin.zip", index: 12, ok: true},
+		{path: "file.bin.1000", base: "file.bin", index: 1000, ok: true},
+		{path: "file.bin.000", ok: false},
+		{path: "file.mp4", ok: false},
+		{path: "file.01", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			base, index, ok := ParsePartName(tt.path)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.base, base)
+			assert.Equal(t, tt.index, index)
+		})
+	}
+}
+
+func TestSplitJoin(t *testing.T) {
+	data := make([]byte, 10*1024+7)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+
+	for _, mode := range []SplitMode{SplitModeRaw, SplitModeZip} {
+		t.Run(mode.String(), func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "file.bin")
+			require.NoError(t, os.WriteFile(path, data, 0o644))
+
+			out := t.TempDir()
+			parts, err := SplitFile(path, out, 4096, mode)
+			require.NoError(t, err)
+			require.Greater(t, len(parts), 1)
+
+			// source dir is kept untouched
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			assert.Len(t, entries, 1)
+
+			for i, part := range parts {
+				assert.Equal(t, out, filepath.Dir(part))
+
+				stat, err := os.Stat(part)
+				require.NoError(t, err)
+				assert.LessOrEqual(t, stat.Size(), int64(4096))
+
+				_, index, ok := ParsePartName(part)
+				assert.True(t, ok)
+				assert.Equal(t, i+1, index)
+			}
+
+			out = filepath.Join(t.TempDir(), "joined")
+			require.NoError(t, JoinFiles(parts, out))
+
+			if mode == SplitModeZip {
+				out, err = UnzipSingle(out, filepath.Dir(out))
+				require.NoError(t, err)
+				assert.Equal(t, "file.bin", filepath.Base(out))
+			}
+
+			joined, err := os.ReadFile(out)
+			require.NoError(t, err)
+			assert.True(t, bytes.Equal(data, joined))
+		})
+	}
+}
@@ -0,0 +1,601 @@
+package mediautil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/go-faster/errors"
+)
+
+// AudioInfo 存储音频文件的标签和时长
+type AudioInfo struct {
+	Title     string  `json:"title"`     // 标题
+	Performer string  `json:"performer"` // 艺术家
+	Duration  float64 `json:"duration"`  // 时长（秒）
+}
+
+// GetAudioInfo 读取 MP3(ID3)、FLAC、Ogg(Vorbis/Opus)、MP4(M4A) 的标签和时长
+func GetAudioInfo(path string) (*AudioInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "open audio")
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, errors.Wrap(err, "stat audio")
+	}
+
+	header := make([]byte, 12)
+	if _, err = io.ReadFull(f, header); err != nil {
+		return nil, errors.Wrap(err, "read header")
+	}
+
+	info := &AudioInfo{}
+	switch {
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		err = parseFLAC(f, info)
+	case bytes.HasPrefix(header, []byte("OggS")):
+		err = parseOgg(f, stat.Size(), info)
+	case bytes.Equal(header[4:8], []byte("ftyp")):
+		err = parseMP4Audio(f, stat.Size(), info)
+	case bytes.HasPrefix(header, []byte("ID3")), header[0] == 0xFF && header[1]&0xE0 == 0xE0:
+		err = parseMP3(f, stat.Size(), info)
+	default:
+		return nil, errors.New("unsupported audio format")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// ---------- MP3 ----------
+
+func parseMP3(r io.ReadSeeker, size int64, info *AudioInfo) error {
+	header := make([]byte, 10)
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(r, header); err != nil {
+		return errors.Wrap(err, "read id3 header")
+	}
+
+	audioStart := int64(0)
+	if bytes.HasPrefix(header, []byte("ID3")) {
+		tagSize := int64(synchsafe(header[6:10]))
+		audioStart = 10 + tagSize
+		if header[5]&0x10 != 0 { // footer present
+			audioStart += 10
+		}
+
+		tag := make([]byte, tagSize)
+		if _, err := io.ReadFull(r, tag); err != nil {
+			return errors.Wrap(err, "read id3 tag")
+		}
+		parseID3v2(tag, header[3], header[5], info)
+	}
+
+	audioEnd := size
+	if v1, ok := readID3v1(r, size); ok {
+		audioEnd -= 128
+		if info.Title == "" {
+			info.Title = v1.Title
+		}
+		if info.Performer == "" {
+			info.Performer = v1.Performer
+		}
+	}
+
+	if info.Duration == 0 {
+		info.Duration = mp3Duration(r, audioStart, audioEnd)
+	}
+
+	return nil
+}
+
+func synchsafe(b []byte) uint32 {
+	return uint32(b[0]&0x7F)<<21 | uint32(b[1]&0x7F)<<14 | uint32(b[2]&0x7F)<<7 | uint32(b[3]&0x7F)
+}
+
+func parseID3v2(tag []byte, version, flags byte, info *AudioInfo) {
+	pos := 0
+	// skip extended header
+	if flags&0x40 != 0 && len(tag) >= 4 {
+		if version == 4 {
+			pos = int(synchsafe(tag[0:4]))
+		} else {
+			pos = int(binary.BigEndian.Uint32(tag[0:4])) + 4
+		}
+	}
+
+	idLen, headerLen := 4, 10
+	if version == 2 {
+		idLen, headerLen = 3, 6
+	}
+
+	for pos+headerLen <= len(tag) {
+		id := string(tag[pos : pos+idLen])
+		if id[0] == 0 { // padding
+			break
+		}
+
+		var frameSize int
+		switch version {
+		case 2:
+			frameSize = int(tag[pos+3])<<16 | int(tag[pos+4])<<8 | int(tag[pos+5])
+		case 4:
+			frameSize = int(synchsafe(tag[pos+4 : pos+8]))
+		default:
+			frameSize = int(binary.BigEndian.Uint32(tag[pos+4 : pos+8]))
+		}
+
+		start := pos + headerLen
+		end := start + frameSize
+		if frameSize <= 0 || end > len(tag) {
+			break
+		}
+
+		switch id {
+		case "TIT2", "TT2":
+			info.Title = id3Text(tag[start:end])
+		case "TPE1", "TP1":
+			info.Performer = id3Text(tag[start:end])
+		case "TLEN", "TLE":
+			var ms float64
+			if _, err := fmt.Sscan(id3Text(tag[start:end]), &ms); err == nil {
+				info.Duration = ms / 1000
+			}
+		}
+
+		pos = end
+	}
+}
+
+// id3Text decodes text frame with encoding byte
+func id3Text(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+
+	enc, data := b[0], b[1:]
+	var s string
+	switch enc {
+	case 1, 2: // UTF-16 with BOM, UTF-16BE
+		order := binary.ByteOrder(binary.BigEndian)
+		if len(data) >= 2 && enc == 1 {
+			if data[0] == 0xFF && data[1] == 0xFE {
+				order = binary.LittleEndian
+			}
+			if (data[0] == 0xFF && data[1] == 0xFE) || (data[0] == 0xFE && data[1] == 0xFF) {
+				data = data[2:]
+			}
+		}
+		u := make([]uint16, 0, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			u = append(u, order.Uint16(data[i:]))
+		}
+		s = string(utf16.Decode(u))
+	case 3: // UTF-8
+		s = string(data)
+	default: // ISO-8859-1
+		s = latin1(data)
+	}
+
+	// multiple values are separated by null, we only use the first one
+	if i := strings.IndexRune(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+func latin1(b []byte) string {
+	r := make([]rune, 0, len(b))
+	for _, c := range b {
+		r = append(r, rune(c))
+	}
+	return string(r)
+}
+
+func readID3v1(r io.ReadSeeker, size int64) (*AudioInfo, bool) {
+	if size < 128 {
+		return nil, false
+	}
+
+	b := make([]byte, 128)
+	if _, err := r.Seek(size-128, io.SeekStart); err != nil {
+		return nil, false
+	}
+	if _, err := io.ReadFull(r, b); err != nil || !bytes.HasPrefix(b, []byte("TAG")) {
+		return nil, false
+	}
+
+	trim := func(b []byte) string {
+		return strings.TrimSpace(strings.TrimRight(latin1(b), "\x00"))
+	}
+
+	return &AudioInfo{
+		Title:     trim(b[3:33]),
+		Performer: trim(b[33:63]),
+	}, true
+}
+
+var (
+	// [version][layer] -> kbps list, version: 0 for MPEG1, 1 for MPEG2/2.5; layer: 0 for Layer1, 1 for Layer2, 2 for Layer3
+	mp3Bitrates = [2][3][16]int{
+		{
+			{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+			{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+		},
+		{
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+		},
+	}
+	mp3SampleRates = map[byte][3]int{
+		3: {44100, 48000, 32000}, // MPEG1
+		2: {22050, 24000, 16000}, // MPEG2
+		0: {11025, 12000, 8000},  // MPEG2.5
+	}
+)
+
+// mp3Duration calculates duration by Xing/VBRI header, or by bitrate of first frame for CBR
+func mp3Duration(r io.ReadSeeker, start, end int64) float64 {
+	// search first frame sync in the first 64KB
+	buf := make([]byte, 64*1024)
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return 0
+	}
+	n, _ := io.ReadFull(r, buf)
+	buf = buf[:n]
+
+	for i := 0; i+4 <= len(buf); i++ {
+		if buf[i] != 0xFF || buf[i+1]&0xE0 != 0xE0 {
+			continue
+		}
+
+		version := (buf[i+1] >> 3) & 0x03
+		layer := (buf[i+1] >> 1) & 0x03
+		bitrateIdx := buf[i+2] >> 4
+		rateIdx := (buf[i+2] >> 2) & 0x03
+		channelMode := buf[i+3] >> 6
+
+		rates, ok := mp3SampleRates[version]
+		if !ok || layer == 0 || rateIdx == 3 || bitrateIdx == 0 || bitrateIdx == 15 {
+			continue
+		}
+
+		v, l := 0, int(3-layer) // layer bits: 3 for Layer1, 1 for Layer3
+		if version != 3 {
+			v = 1
+		}
+		sampleRate := rates[rateIdx]
+		bitrate := mp3Bitrates[v][l][bitrateIdx] * 1000
+
+		samplesPerFrame := 1152
+		switch {
+		case l == 0:
+			samplesPerFrame = 384
+		case l == 2 && v == 1:
+			samplesPerFrame = 576
+		}
+
+		// Xing/Info header offset depends on version and channel mode
+		sideInfo := 32
+		switch {
+		case v == 0 && channelMode == 3:
+			sideInfo = 17
+		case v == 1 && channelMode != 3:
+			sideInfo = 17
+		case v == 1 && channelMode == 3:
+			sideInfo = 9
+		}
+
+		if x := i + 4 + sideInfo; x+12 <= len(buf) {
+			tag := string(buf[x : x+4])
+			if (tag == "Xing" || tag == "Info") && buf[x+7]&0x01 != 0 {
+				frames := binary.BigEndian.Uint32(buf[x+8 : x+12])
+				return float64(frames) * float64(samplesPerFrame) / float64(sampleRate)
+			}
+		}
+		if x := i + 4 + 32; x+18 <= len(buf) && string(buf[x:x+4]) == "VBRI" {
+			frames := binary.BigEndian.Uint32(buf[x+14 : x+18])
+			return float64(frames) * float64(samplesPerFrame) / float64(sampleRate)
+		}
+
+		// CBR
+		return float64(end-start-int64(i)) * 8 / float64(bitrate)
+	}
+
+	return 0
+}
+
+// ---------- FLAC ----------
+
+func parseFLAC(r io.ReadSeeker, info *AudioInfo) error {
+	if _, err := r.Seek(4, io.SeekStart); err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return errors.Wrap(err, "read flac block header")
+		}
+		last := header[0]&0x80 != 0
+		typ := header[0] & 0x7F
+		length := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+
+		switch typ {
+		case 0, 4: // STREAMINFO, VORBIS_COMMENT
+			block := make([]byte, length)
+			if _, err := io.ReadFull(r, block); err != nil {
+				return errors.Wrap(err, "read flac block")
+			}
+
+			if typ == 0 && len(block) >= 18 {
+				sampleRate := uint64(block[10])<<12 | uint64(block[11])<<4 | uint64(block[12])>>4
+				samples := uint64(block[13]&0x0F)<<32 | uint64(binary.BigEndian.Uint32(block[14:18]))
+				if sampleRate > 0 {
+					info.Duration = float64(samples) / float64(sampleRate)
+				}
+			} else {
+				parseVorbisComment(block, info)
+			}
+		default:
+			if _, err := r.Seek(int64(length), io.SeekCurrent); err != nil {
+				return err
+			}
+		}
+
+		if last {
+			return nil
+		}
+	}
+}
+
+// parseVorbisComment parses vorbis comment without framing bit, which is used by FLAC, Vorbis and Opus
+func parseVorbisComment(b []byte, info *AudioInfo) {
+	if len(b) < 4 {
+		return
+	}
+	vendorLen := int(binary.LittleEndian.Uint32(b))
+	pos := 4 + vendorLen
+	if pos+4 > len(b) {
+		return
+	}
+
+	count := int(binary.LittleEndian.Uint32(b[pos:]))
+	pos += 4
+	for i := 0; i < count && pos+4 <= len(b); i++ {
+		l := int(binary.LittleEndian.Uint32(b[pos:]))
+		pos += 4
+		if pos+l > len(b) {
+			return
+		}
+
+		kv := string(b[pos : pos+l])
+		pos += l
+
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		switch strings.ToUpper(k) {
+		case "TITLE":
+			info.Title = v
+		case "ARTIST":
+			if info.Performer == "" {
+				info.Performer = v
+			}
+		}
+	}
+}
+
+// ---------- Ogg ----------
+
+func parseOgg(r io.ReadSeeker, size int64, info *AudioInfo) error {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	// read the first two packets: identification header and comment header
+	packets := make([][]byte, 0, 2)
+	cur := make([]byte, 0)
+	header := make([]byte, 27)
+	for len(packets) < 2 {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return errors.Wrap(err, "read ogg page")
+		}
+		if !bytes.HasPrefix(header, []byte("OggS")) {
+			return errors.New("invalid ogg page")
+		}
+
+		segments := make([]byte, header[26])
+		if _, err := io.ReadFull(r, segments); err != nil {
+			return errors.Wrap(err, "read ogg segments")
+		}
+		for _, l := range segments {
+			seg := make([]byte, l)
+			if _, err := io.ReadFull(r, seg); err != nil {
+				return errors.Wrap(err, "read ogg segment")
+			}
+			cur = append(cur, seg...)
+			if l < 255 { // end of packet
+				packets = append(packets, cur)
+				cur = make([]byte, 0)
+			}
+		}
+	}
+
+	sampleRate, preSkip := uint64(0), uint64(0)
+	id, comment := packets[0], packets[1]
+	switch {
+	case bytes.HasPrefix(id, []byte("OpusHead")) && len(id) >= 12:
+		sampleRate = 48000 // granule position of opus is always in 48kHz
+		preSkip = uint64(binary.LittleEndian.Uint16(id[10:12]))
+		if bytes.HasPrefix(comment, []byte("OpusTags")) {
+			parseVorbisComment(comment[8:], info)
+		}
+	case bytes.HasPrefix(id, []byte("\x01vorbis")) && len(id) >= 16:
+		sampleRate = uint64(binary.LittleEndian.Uint32(id[12:16]))
+		if bytes.HasPrefix(comment, []byte("\x03vorbis")) {
+			parseVorbisComment(comment[7:], info)
+		}
+	default:
+		return errors.New("unsupported ogg codec")
+	}
+
+	// granule position of the last page is the total samples
+	tail := min(size, 64*1024)
+	buf := make([]byte, tail)
+	if _, err := r.Seek(size-tail, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return errors.Wrap(err, "read ogg tail")
+	}
+	if i := bytes.LastIndex(buf, []byte("OggS")); i >= 0 && i+14 <= len(buf) && sampleRate > 0 {
+		granule := binary.LittleEndian.Uint64(buf[i+6 : i+14])
+		if granule > preSkip {
+			info.Duration = float64(granule-preSkip) / float64(sampleRate)
+		}
+	}
+
+	return nil
+}
+
+// ---------- MP4 ----------
+
+func parseMP4Audio(r io.ReadSeeker, size int64, info *AudioInfo) error {
+	moov, ok, err := findBox(r, 0, size, "moov")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("moov box not found")
+	}
+
+	if mvhd, ok, err := findBox(r, moov.dataStart(), moov.end(), "mvhd"); err == nil && ok {
+		if b, err := readBox(r, mvhd); err == nil {
+			info.Duration, _ = parseTimeBox(b)
+		}
+	}
+
+	// moov/udta/meta/ilst
+	udta, ok, err := findBox(r, moov.dataStart(), moov.end(), "udta")
+	if err != nil || !ok {
+		return err
+	}
+	meta, ok, err := findBox(r, udta.dataStart(), udta.end(), "meta")
+	if err != nil || !ok {
+		return err
+	}
+	// meta is a full box with 4 bytes version and flags
+	ilst, ok, err := findBox(r, meta.dataStart()+4, meta.end(), "ilst")
+	if err != nil || !ok {
+		return err
+	}
+
+	tags := map[string]*string{
+		"\xa9nam": &info.Title,
+		"\xa9ART": &info.Performer,
+	}
+	for name, dst := range tags {
+		item, ok, err := findBox(r, ilst.dataStart(), ilst.end(), name)
+		if err != nil || !ok {
+			continue
+		}
+		data, ok, err := findBox(r, item.dataStart(), item.end(), "data")
+		if err != nil || !ok {
+			continue
+		}
+		b, err := readBox(r, data)
+		if err != nil || len(b) < 8 {
+			continue
+		}
+		// 4 bytes type and 4 bytes locale
+		*dst = string(b[8:])
+	}
+
+	return nil
+}
+
+type box struct {
+	typ        string
+	start      int64
+	size       int64
+	headerSize int64
+}
+
+func (b box) dataStart() int64 { return b.start + b.headerSize }
+
+func (b box) end() int64 { return b.start + b.size }
+
+// findBox finds the first box with given type in [start, end)
+func findBox(r io.ReadSeeker, start, end int64, typ string) (box, bool, error) {
+	header := make([]byte, 16)
+	for pos := start; pos+8 <= end; {
+		if _, err := r.Seek(pos, io.SeekStart); err != nil {
+			return box{}, false, err
+		}
+		if _, err := io.ReadFull(r, header[:8]); err != nil {
+			return box{}, false, errors.Wrap(err, "read box header")
+		}
+
+		b := box{
+			typ:        string(header[4:8]),
+			start:      pos,
+			size:       int64(binary.BigEndian.Uint32(header[0:4])),
+			headerSize: 8,
+		}
+		switch b.size {
+		case 0: // box extends to the end
+			b.size = end - pos
+		case 1: // 64-bit size
+			if _, err := io.ReadFull(r, header[8:16]); err != nil {
+				return box{}, false, errors.Wrap(err, "read box large size")
+			}
+			b.size = int64(binary.BigEndian.Uint64(header[8:16]))
+			b.headerSize = 16
+		}
+		// negative large size is also rejected here
+		if b.size < b.headerSize || b.size > end-pos {
+			return box{}, false, errors.Errorf("invalid box size %d of %s at %d", b.size, b.typ, pos)
+		}
+
+		if b.typ == typ {
+			return b, true, nil
+		}
+		pos += b.size
+	}
+
+	return box{}, false, nil
+}
+
+// mp4MaxBoxSize is the max size of box to be read into memory
+const mp4MaxBoxSize = 32 << 20
+
+func readBox(r io.ReadSeeker, b box) ([]byte, error) {
+	if n := b.size - b.headerSize; n < 0 || n > mp4MaxBoxSize {
+		return nil, errors.Errorf("box %s is too large: %d", b.typ, n)
+	}
+	if _, err := r.Seek(b.dataStart(), io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, b.size-b.headerSize)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, errors.Wrap(err, "read box")
+	}
+	return data, nil
+}
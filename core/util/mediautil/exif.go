@@ -0,0 +1,246 @@
+package mediautil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+const (
+	exifTagOrientation = 0x0112
+	exifTagGPSInfo     = 0x8825
+)
+
+var (
+	exifHeader = []byte("Exif\x00\x00")
+	xmpHeader  = []byte("http://ns.adobe.com/xap/1.0/\x00")
+)
+
+// jpegSegment 是 SOS 之前的一个段，start 指向 0xFF，end 指向段之后
+type jpegSegment struct {
+	marker     byte
+	start, end int
+}
+
+// jpegSegments 返回 SOS 之前的所有段
+func jpegSegments(data []byte) []jpegSegment {
+	segments := make([]jpegSegment, 0)
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return segments
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			break
+		}
+		marker := data[i+1]
+		// markers without length
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD8) {
+			i += 2
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) {
+			break
+		}
+		segments = append(segments, jpegSegment{marker: marker, start: i, end: end})
+		i = end
+	}
+
+	return segments
+}
+
+// payload 返回段的内容，不包含 marker 和长度
+func (s jpegSegment) payload(data []byte) []byte {
+	return data[s.start+4 : s.end]
+}
+
+// exifTIFF 返回 Exif 段中的 TIFF 数据，与 data 共享内存
+func exifTIFF(data []byte) *tiff {
+	for _, s := range jpegSegments(data) {
+		p := s.payload(data)
+		if s.marker != 0xE1 || !bytes.HasPrefix(p, exifHeader) {
+			continue
+		}
+		return parseTIFF(p[len(exifHeader):])
+	}
+	return nil
+}
+
+type tiff struct {
+	b    []byte
+	bo   binary.ByteOrder
+	ifd0 uint32
+}
+
+func parseTIFF(b []byte) *tiff {
+	if len(b) < 8 {
+		return nil
+	}
+
+	t := &tiff{b: b}
+	switch string(b[:2]) {
+	case "II":
+		t.bo = binary.LittleEndian
+	case "MM":
+		t.bo = binary.BigEndian
+	default:
+		return nil
+	}
+	t.ifd0 = t.bo.Uint32(b[4:])
+	return t
+}
+
+// ifdEntry 是 IFD 中的一项，offset 指向 12 字节的项
+type ifdEntry struct {
+	tag, typ uint16
+	count    uint32
+	offset   int
+}
+
+// entries 返回 IFD 的所有项，偏移越界时返回 nil
+func (t *tiff) entries(off uint32) []ifdEntry {
+	if int64(off)+2 > int64(len(t.b)) {
+		return nil
+	}
+	n := int(t.bo.Uint16(t.b[off:]))
+	if int(off)+2+n*12 > len(t.b) {
+		return nil
+	}
+
+	entries := make([]ifdEntry, 0, n)
+	for i := 0; i < n; i++ {
+		o := int(off) + 2 + i*12
+		entries = append(entries, ifdEntry{
+			tag:    t.bo.Uint16(t.b[o:]),
+			typ:    t.bo.Uint16(t.b[o+2:]),
+			count:  t.bo.Uint32(t.b[o+4:]),
+			offset: o,
+		})
+	}
+	return entries
+}
+
+// size 返回值的字节数，不超过 4 字节的值直接存储在项中
+func (e ifdEntry) size() int {
+	sizes := map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+	return sizes[e.typ] * int(e.count)
+}
+
+// value 返回值的数据，与 tiff 共享内存
+func (t *tiff) value(e ifdEntry) []byte {
+	size := e.size()
+	if size <= 4 {
+		return t.b[e.offset+8 : e.offset+8+size]
+	}
+	off := int(t.bo.Uint32(t.b[e.offset+8:]))
+	if off < 0 || off+size > len(t.b) {
+		return nil
+	}
+	return t.b[off : off+size]
+}
+
+// exifOrientation 返回 JPEG 的 Exif 方向，默认为 1
+func exifOrientation(data []byte) int {
+	t := exifTIFF(data)
+	if t == nil {
+		return 1
+	}
+
+	for _, e := range t.entries(t.ifd0) {
+		if e.tag == exifTagOrientation && e.typ == 3 && e.count == 1 {
+			if o := int(t.bo.Uint16(t.value(e))); o >= 1 && o <= 8 {
+				return o
+			}
+		}
+	}
+	return 1
+}
+
+// StripGPS 清除 JPEG 中的 Exif GPS 信息和包含位置的 XMP，其他元数据保持不变。
+// 返回新的数据和是否有位置信息被清除。
+func StripGPS(data []byte) ([]byte, bool) {
+	out := make([]byte, len(data))
+	copy(out, data)
+	stripped := false
+
+	if t := exifTIFF(out); t != nil {
+		for _, e := range t.entries(t.ifd0) {
+			if e.tag != exifTagGPSInfo || e.size() != 4 {
+				continue
+			}
+
+			gps := t.bo.Uint32(t.value(e))
+			entries := t.entries(gps)
+			if len(entries) == 0 {
+				continue
+			}
+
+			// zero values and entries, and leave an empty GPS IFD
+			for _, g := range entries {
+				clear(t.value(g))
+				clear(t.b[g.offset : g.offset+12])
+			}
+			t.bo.PutUint16(t.b[gps:], 0)
+			stripped = true
+		}
+	}
+
+	// XMP may also contain location, drop the whole segment
+	for _, s := range jpegSegments(out) {
+		p := s.payload(out)
+		if s.marker == 0xE1 && bytes.HasPrefix(p, xmpHeader) && bytes.Contains(p, []byte("GPS")) {
+			out = append(out[:s.start], out[s.end:]...)
+			stripped = true
+			break
+		}
+	}
+
+	return out, stripped
+}
+
+// orient 按 Exif 方向旋转或翻转图片
+func orient(src image.Image, o int) image.Image {
+	if o <= 1 || o > 8 {
+		return src
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+
+	// map destination point to source point
+	var at func(x, y int) (int, int)
+	switch o {
+	case 2:
+		at = func(x, y int) (int, int) { return w - 1 - x, y }
+	case 3:
+		at = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case 4:
+		at = func(x, y int) (int, int) { return x, h - 1 - y }
+	case 5:
+		at = func(x, y int) (int, int) { return y, x }
+	case 6:
+		at = func(x, y int) (int, int) { return y, h - 1 - x }
+	case 7:
+		at = func(x, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case 8:
+		at = func(x, y int) (int, int) { return w - 1 - y, x }
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			sx, sy := at(x, y)
+			dst.Set(x, y, src.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
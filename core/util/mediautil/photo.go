@@ -0,0 +1,212 @@
+package mediautil
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-faster/errors"
+)
+
+// Telegram 对照片的限制
+const (
+	MaxPhotoSize       = 10 * 1024 * 1024
+	MaxPhotoDimensions = 10000 // width + height
+	MaxPhotoRatio      = 20
+)
+
+// PhotoOptions 照片处理选项
+type PhotoOptions struct {
+	KeepGPS   bool   // keep EXIF and XMP location
+	OutputDir string // where converted photos are written
+}
+
+// PreparePhoto 使图片符合 Telegram 照片的要求：
+// HEIC/AVIF/TIFF 等格式使用 ffmpeg 转换为 JPEG，超过尺寸或大小限制时缩小，默认清除 JPEG 中的位置信息。
+// 返回需要上传的文件路径，未做任何修改时返回原路径。无法作为照片上传时返回错误。
+func PreparePhoto(ctx context.Context, ffmpegPath, path string, opts PhotoOptions) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrap(err, "read photo")
+	}
+
+	// 每张照片使用单独的目录，避免同名文件（如 a/x.heic 和 b/x.heic）互相覆盖，同时保留文件名
+	out := ""
+	output := func() (string, error) {
+		if out != "" {
+			return out, nil
+		}
+		dir, err := os.MkdirTemp(opts.OutputDir, "photo-")
+		if err != nil {
+			return "", errors.Wrap(err, "create photo dir")
+		}
+		out = filepath.Join(dir, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))+".jpg")
+		return out, nil
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || !isPhotoFormat(format) {
+		vp := GetVideoProcessor(ffmpegPath)
+		if vp == nil {
+			return "", errors.New("no video processor to convert photo")
+		}
+		if _, err = output(); err != nil {
+			return "", err
+		}
+		if err = vp.ConvertImage(ctx, path, out); err != nil {
+			return "", err
+		}
+
+		path = out
+		if data, err = os.ReadFile(path); err != nil {
+			return "", errors.Wrap(err, "read converted photo")
+		}
+		if cfg, format, err = image.DecodeConfig(bytes.NewReader(data)); err != nil {
+			return "", errors.Wrap(err, "decode converted photo")
+		}
+	}
+
+	if ratio := float64(max(cfg.Width, cfg.Height)) / float64(max(min(cfg.Width, cfg.Height), 1)); ratio > MaxPhotoRatio {
+		return "", errors.Errorf("aspect ratio %.1f exceeds %d", ratio, MaxPhotoRatio)
+	}
+
+	if cfg.Width+cfg.Height <= MaxPhotoDimensions && len(data) <= MaxPhotoSize {
+		if format != "jpeg" || opts.KeepGPS {
+			return path, nil
+		}
+
+		stripped, ok := StripGPS(data)
+		if !ok {
+			return path, nil
+		}
+		if _, err = output(); err != nil {
+			return "", err
+		}
+		if err = os.WriteFile(out, stripped, 0o644); err != nil {
+			return "", errors.Wrap(err, "write photo")
+		}
+		return out, nil
+	}
+
+	// re-encoding drops all metadata, including location
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", errors.Wrap(err, "decode photo")
+	}
+	if format == "jpeg" {
+		img = orient(img, exifOrientation(data))
+	}
+
+	encoded, err := shrinkPhoto(img)
+	if err != nil {
+		return "", err
+	}
+	if _, err = output(); err != nil {
+		return "", err
+	}
+	if err = os.WriteFile(out, encoded, 0o644); err != nil {
+		return "", errors.Wrap(err, "write photo")
+	}
+
+	return out, nil
+}
+
+// isPhotoFormat 是否为可以直接解码并作为照片上传的格式
+func isPhotoFormat(format string) bool {
+	return format == "jpeg" || format == "png" || format == "gif"
+}
+
+// shrinkPhoto 缩小图片直到满足尺寸和大小限制，返回 JPEG 数据
+func shrinkPhoto(img image.Image) ([]byte, error) {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	scale := math.Min(1, float64(MaxPhotoDimensions)/float64(w+h))
+
+	for i := 0; i < 5; i++ {
+		dst := img
+		if scale < 1 {
+			dst = resizeTo(img, max(int(float64(w)*scale), 1), max(int(float64(h)*scale), 1))
+		}
+
+		buf := &bytes.Buffer{}
+		if err := jpeg.Encode(buf, dst, &jpeg.Options{Quality: 90}); err != nil {
+			return nil, errors.Wrap(err, "encode photo")
+		}
+		if buf.Len() <= MaxPhotoSize {
+			return buf.Bytes(), nil
+		}
+
+		scale *= 0.8
+	}
+
+	return nil, errors.New("photo is still too large after shrinking")
+}
+
+// ConvertImage 使用 ffmpeg 将 HEIC/AVIF/TIFF 等图片转换为 JPEG
+func (p *VideoProcessor) ConvertImage(ctx context.Context, inputPath, outputPath string) error {
+	args := []string{
+		"-hide_banner",
+		"-v", "error",
+		"-i", inputPath,
+		"-frames:v", "1",
+		"-q:v", "2",
+		"-y",
+		outputPath,
+	}
+
+	cmd := exec.CommandContext(ctx, p.ffmpegPath, args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		_ = os.Remove(outputPath)
+		return errors.Wrapf(err, "convert image: %s", lastLine(string(output)))
+	}
+
+	return nil
+}
+
+// WebPSize 读取 WebP 图片的宽高，支持 VP8、VP8L 和 VP8X 格式
+func WebPSize(path string) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "open webp")
+	}
+	defer f.Close()
+
+	// RIFF header, chunk header and the first bytes of chunk data
+	header := make([]byte, 30)
+	if _, err = io.ReadFull(f, header); err != nil {
+		return 0, 0, errors.Wrap(err, "read webp header")
+	}
+	if !bytes.Equal(header[0:4], []byte("RIFF")) || !bytes.Equal(header[8:12], []byte("WEBP")) {
+		return 0, 0, errors.New("not a webp")
+	}
+
+	data := header[20:]
+	switch string(header[12:16]) {
+	case "VP8 ":
+		// 3 bytes frame tag, 3 bytes start code, 14 bits width and height
+		if !bytes.Equal(data[3:6], []byte{0x9d, 0x01, 0x2a}) {
+			return 0, 0, errors.New("invalid vp8 start code")
+		}
+		return int(binary.LittleEndian.Uint16(data[6:8]) & 0x3fff), int(binary.LittleEndian.Uint16(data[8:10]) & 0x3fff), nil
+	case "VP8L":
+		// 1 byte signature, 14 bits width-1 and height-1
+		if data[0] != 0x2f {
+			return 0, 0, errors.New("invalid vp8l signature")
+		}
+		bits := binary.LittleEndian.Uint32(data[1:5])
+		return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1, nil
+	case "VP8X":
+		// 4 bytes flags, 24 bits canvas width-1 and height-1
+		u24 := func(b []byte) int { return int(b[0]) | int(b[1])<<8 | int(b[2])<<16 }
+		return u24(data[4:7]) + 1, u24(data[7:10]) + 1, nil
+	}
+
+	return 0, 0, errors.Errorf("unknown webp chunk %q", header[12:16])
+}
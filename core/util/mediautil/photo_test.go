@@ -0,0 +1,267 @@
+package mediautil
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildExif builds little endian TIFF data with orientation, and GPS latitude if gps is true
+func buildExif(orientation uint16, gps bool) []byte {
+	le := binary.LittleEndian
+	b := []byte("II*\x00\x08\x00\x00\x00")
+
+	entry := func(tag, typ uint16, count, value uint32) []byte {
+		e := make([]byte, 12)
+		le.PutUint16(e, tag)
+		le.PutUint16(e[2:], typ)
+		le.PutUint32(e[4:], count)
+		le.PutUint32(e[8:], value)
+		return e
+	}
+
+	n := uint16(1)
+	if gps {
+		n = 2
+	}
+	b = le.AppendUint16(b, n)
+	b = append(b, entry(exifTagOrientation, 3, 1, uint32(orientation))...)
+	if gps {
+		// IFD0: 8 + 2 + 2*12 + 4 = 38
+		b = append(b, entry(exifTagGPSInfo, 4, 1, 38)...)
+	}
+	b = le.AppendUint32(b, 0)
+
+	if gps {
+		// GPS IFD: 38 + 2 + 2*12 + 4 = 68
+		b = le.AppendUint16(b, 2)
+		b = append(b, entry(0x0001, 2, 2, uint32('N'))...)
+		b = append(b, entry(0x0002, 5, 3, 68)...)
+		b = le.AppendUint32(b, 0)
+		for _, v := range []uint32{30, 1, 15, 1, 7, 1} {
+			b = le.AppendUint32(b, v)
+		}
+	}
+
+	return b
+}
+
+// buildJPEG encodes img and inserts APP1 segments after SOI
+func buildJPEG(t *testing.T, img image.Image, app1 ...[]byte) []byte {
+	buf := &bytes.Buffer{}
+	require.NoError(t, jpeg.Encode(buf, img, nil))
+	data := buf.Bytes()
+
+	out := append([]byte{}, data[:2]...)
+	for _, p := range app1 {
+		out = append(out, 0xFF, 0xE1)
+		out = binary.BigEndian.AppendUint16(out, uint16(len(p)+2))
+		out = append(out, p...)
+	}
+	return append(out, data[2:]...)
+}
+
+func solid(w, h int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 0x80
+	}
+	return img
+}
+
+func TestStripGPS(t *testing.T) {
+	data := buildJPEG(t, solid(16, 16), append(append([]byte{}, exifHeader...), buildExif(6, true)...))
+	assert.Equal(t, 6, exifOrientation(data))
+
+	stripped, ok := StripGPS(data)
+	require.True(t, ok)
+	require.Len(t, stripped, len(data))
+
+	tf := exifTIFF(stripped)
+	require.NotNil(t, tf)
+	assert.Empty(t, tf.entries(38))
+	assert.NotContains(t, string(tf.b[38:]), "N")
+	assert.Equal(t, make([]byte, 24), tf.b[68:92])
+	assert.Equal(t, 6, exifOrientation(stripped), "other tags are kept")
+
+	_, err := jpeg.Decode(bytes.NewReader(stripped))
+	assert.NoError(t, err)
+
+	// original data is not modified
+	assert.Equal(t, byte('N'), exifTIFF(data).b[38+2+8])
+
+	_, ok = StripGPS(buildJPEG(t, solid(16, 16), append(append([]byte{}, exifHeader...), buildExif(1, false)...)))
+	assert.False(t, ok)
+}
+
+func TestStripGPSXMP(t *testing.T) {
+	xmp := append(append([]byte{}, xmpHeader...), `<x:xmpmeta><rdf:Description exif:GPSLatitude="30,15.1N"/></x:xmpmeta>`...)
+	data := buildJPEG(t, solid(16, 16), xmp)
+
+	stripped, ok := StripGPS(data)
+	require.True(t, ok)
+	assert.Len(t, stripped, len(data)-len(xmp)-4)
+	assert.NotContains(t, string(stripped), "GPSLatitude")
+
+	_, err := jpeg.Decode(bytes.NewReader(stripped))
+	assert.NoError(t, err)
+}
+
+func TestOrient(t *testing.T) {
+	a, b := color.RGBA{R: 0xFF, A: 0xFF}, color.RGBA{B: 0xFF, A: 0xFF}
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.SetRGBA(0, 0, a)
+	src.SetRGBA(1, 0, b)
+
+	tests := []struct {
+		orientation int
+		w, h        int
+		first       color.RGBA
+	}{
+		{1, 2, 1, a},
+		{2, 2, 1, b},
+		{3, 2, 1, b},
+		{6, 1, 2, a},
+		{8, 1, 2, b},
+	}
+
+	for _, tt := range tests {
+		dst := orient(src, tt.orientation)
+		assert.Equal(t, image.Rect(0, 0, tt.w, tt.h), dst.Bounds(), tt.orientation)
+		assert.Equal(t, tt.first, color.RGBAModel.Convert(dst.At(0, 0)), tt.orientation)
+	}
+}
+
+func TestPreparePhoto(t *testing.T) {
+	dir := t.TempDir()
+	out := t.TempDir()
+	opts := PhotoOptions{OutputDir: out}
+	ctx := context.Background()
+
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+		return path
+	}
+	encodePNG := func(img image.Image) []byte {
+		buf := &bytes.Buffer{}
+		require.NoError(t, png.Encode(buf, img))
+		return buf.Bytes()
+	}
+
+	t.Run("unchanged", func(t *testing.T) {
+		path := write("plain.jpg", buildJPEG(t, solid(64, 48)))
+		result, err := PreparePhoto(ctx, "", path, opts)
+		require.NoError(t, err)
+		assert.Equal(t, path, result)
+	})
+
+	t.Run("strip gps", func(t *testing.T) {
+		exif := append(append([]byte{}, exifHeader...), buildExif(1, true)...)
+		path := write("gps.jpg", buildJPEG(t, solid(64, 48), exif))
+
+		result, err := PreparePhoto(ctx, "", path, opts)
+		require.NoError(t, err)
+		assert.Equal(t, out, filepath.Dir(filepath.Dir(result)))
+		assert.Equal(t, "gps.jpg", filepath.Base(result))
+
+		data, err := os.ReadFile(result)
+		require.NoError(t, err)
+		_, ok := StripGPS(data)
+		assert.False(t, ok)
+
+		// keep location
+		result, err = PreparePhoto(ctx, "", path, PhotoOptions{OutputDir: out, KeepGPS: true})
+		require.NoError(t, err)
+		assert.Equal(t, path, result)
+	})
+
+	t.Run("downsize", func(t *testing.T) {
+		path := write("large.png", encodePNG(solid(8000, 2100)))
+
+		result, err := PreparePhoto(ctx, "", path, opts)
+		require.NoError(t, err)
+		assert.Equal(t, out, filepath.Dir(filepath.Dir(result)))
+		assert.Equal(t, "large.jpg", filepath.Base(result))
+
+		f, err := os.Open(result)
+		require.NoError(t, err)
+		defer f.Close()
+		cfg, format, err := image.DecodeConfig(f)
+		require.NoError(t, err)
+		assert.Equal(t, "jpeg", format)
+		assert.LessOrEqual(t, cfg.Width+cfg.Height, MaxPhotoDimensions)
+		assert.InDelta(t, 8000.0/2100.0, float64(cfg.Width)/float64(cfg.Height), 0.01)
+	})
+
+	t.Run("same name", func(t *testing.T) {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "a"), 0o755))
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "b"), 0o755))
+		a := write(filepath.Join("a", "x.png"), encodePNG(solid(8000, 2100)))
+		b := write(filepath.Join("b", "x.png"), encodePNG(solid(2100, 8000)))
+
+		ra, err := PreparePhoto(ctx, "", a, opts)
+		require.NoError(t, err)
+		rb, err := PreparePhoto(ctx, "", b, opts)
+		require.NoError(t, err)
+		assert.NotEqual(t, ra, rb)
+
+		for path, landscape := range map[string]bool{ra: true, rb: false} {
+			f, err := os.Open(path)
+			require.NoError(t, err)
+			cfg, _, err := image.DecodeConfig(f)
+			require.NoError(t, f.Close())
+			require.NoError(t, err)
+			assert.Equal(t, landscape, cfg.Width > cfg.Height, path)
+		}
+	})
+
+	t.Run("aspect ratio", func(t *testing.T) {
+		path := write("long.png", encodePNG(solid(2100, 100)))
+		_, err := PreparePhoto(ctx, "", path, opts)
+		assert.Error(t, err)
+	})
+}
+
+func TestWebPSize(t *testing.T) {
+	webp := func(chunk string, data []byte) string {
+		b := append([]byte("RIFF\x00\x00\x00\x00WEBP"+chunk+"\x00\x00\x00\x00"), data...)
+		b = append(b, make([]byte, 16)...)
+
+		path := filepath.Join(t.TempDir(), "a.webp")
+		require.NoError(t, os.WriteFile(path, b, 0o644))
+		return path
+	}
+
+	tests := []struct {
+		name string
+		path string
+		w, h int
+	}{
+		{"vp8", webp("VP8 ", []byte{0, 0, 0, 0x9d, 0x01, 0x2a, 0x00, 0x02, 0x00, 0x01}), 512, 256},
+		{"vp8l", webp("VP8L", []byte{0x2f, 0xff, 0xc1, 0x3f, 0x00}), 512, 256},
+		{"vp8x", webp("VP8X", []byte{0, 0, 0, 0, 0xff, 0x01, 0x00, 0xff, 0x00, 0x00}), 512, 256},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, h, err := WebPSize(tt.path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.w, w)
+			assert.Equal(t, tt.h, h)
+		})
+	}
+
+	_, _, err := WebPSize(webp("ABCD", nil))
+	assert.Error(t, err)
+}
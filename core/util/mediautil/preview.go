@@ -0,0 +1,275 @@
+package mediautil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/go-faster/errors"
+)
+
+// ContactSheetOptions 预览图选项
+type ContactSheetOptions struct {
+	Columns    int
+	Rows       int
+	Width      int // width of each tile, default 320
+	OutputPath string
+}
+
+// PreviewClipOptions 动态预览选项
+type PreviewClipOptions struct {
+	Segments        int     // number of segments picked evenly from the video
+	SegmentDuration float64 // duration of each segment. Unit: Second
+	Width           int     // default 480
+	OutputPath      string
+}
+
+const (
+	sheetPadding   = 4
+	timestampScale = 2
+)
+
+// sampleTimes 在视频中均匀取 n 个时间点，取每个区间的中点以避开片头片尾
+func sampleTimes(duration float64, n int) []float64 {
+	times := make([]float64, 0, n)
+	for i := 0; i < n; i++ {
+		times = append(times, duration*(float64(i)+0.5)/float64(n))
+	}
+	return times
+}
+
+// tileSize 按显示宽高比计算每个格子的大小，高度取偶数
+func tileSize(info *VideoInfo, width int) (int, int) {
+	if info.Width <= 0 || info.Height <= 0 {
+		return width, width * 9 / 16 &^ 1
+	}
+	return width, (width*info.Height/info.Width + 1) &^ 1
+}
+
+// ContactSheet 生成 Columns×Rows 的视频截图网格，每张截图右下角标注时间，输出为 JPEG
+func (p *VideoProcessor) ContactSheet(ctx context.Context, inputPath string, info *VideoInfo, opts ContactSheetOptions) error {
+	if opts.Columns <= 0 || opts.Rows <= 0 {
+		return errors.Errorf("invalid grid %dx%d", opts.Columns, opts.Rows)
+	}
+	if info.Duration <= 0 {
+		return errors.New("unknown video duration")
+	}
+	if opts.Width <= 0 {
+		opts.Width = 320
+	}
+
+	w, h := tileSize(info, opts.Width)
+	times := sampleTimes(info.Duration, opts.Columns*opts.Rows)
+
+	frames := make([]image.Image, len(times))
+	extracted := 0
+	for i, t := range times {
+		frame, err := p.frameAt(ctx, inputPath, t, w, h)
+		if err != nil {
+			// frames near the end may be missing, leave the tile empty
+			continue
+		}
+		frames[i] = frame
+		extracted++
+	}
+	if extracted == 0 {
+		return errors.New("no frame extracted")
+	}
+
+	sheet := composeSheet(frames, times, opts.Columns, w, h)
+
+	out, err := os.Create(opts.OutputPath)
+	if err != nil {
+		return errors.Wrap(err, "create contact sheet")
+	}
+	err = jpeg.Encode(out, sheet, &jpeg.Options{Quality: 85})
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(opts.OutputPath)
+		return errors.Wrap(err, "write contact sheet")
+	}
+
+	return nil
+}
+
+// frameAt 截取指定时间的一帧并缩放到 w×h
+func (p *VideoProcessor) frameAt(ctx context.Context, inputPath string, t float64, w, h int) (image.Image, error) {
+	args := []string{
+		"-hide_banner",
+		"-v", "error",
+		"-ss", fmt.Sprintf("%.3f", t),
+		"-i", inputPath,
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale=%d:%d", w, h),
+		"-f", "image2pipe",
+		"-c:v", "png",
+		"-",
+	}
+
+	cmd := exec.CommandContext(ctx, p.ffmpegPath, args...)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrap(err, "extract frame")
+	}
+	if len(output) == 0 {
+		return nil, errors.New("no frame at time")
+	}
+
+	return png.Decode(bytes.NewReader(output))
+}
+
+// composeSheet 将截图按列排列到黑色背景上，nil 的截图留空
+func composeSheet(frames []image.Image, times []float64, columns, w, h int) *image.RGBA {
+	rows := (len(frames) + columns - 1) / columns
+	sheet := image.NewRGBA(image.Rect(0, 0,
+		columns*(w+sheetPadding)+sheetPadding,
+		rows*(h+sheetPadding)+sheetPadding))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	for i, frame := range frames {
+		if frame == nil {
+			continue
+		}
+
+		x := sheetPadding + i%columns*(w+sheetPadding)
+		y := sheetPadding + i/columns*(h+sheetPadding)
+		tile := image.Rect(x, y, x+w, y+h)
+		draw.Draw(sheet, tile, frame, frame.Bounds().Min, draw.Src)
+
+		drawTimestamp(sheet, tile, formatTimestamp(times[i]))
+	}
+
+	return sheet
+}
+
+// formatTimestamp 格式化为 m:ss 或 h:mm:ss
+func formatTimestamp(t float64) string {
+	s := int(t)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+// glyphs 3×5 点阵字体，只包含时间戳需要的字符
+var glyphs = map[rune][5]string{
+	'0': {"###", "#.#", "#.#", "#.#", "###"},
+	'1': {".#.", "##.", ".#.", ".#.", "###"},
+	'2': {"###", "..#", "###", "#..", "###"},
+	'3': {"###", "..#", "###", "..#", "###"},
+	'4': {"#.#", "#.#", "###", "..#", "..#"},
+	'5': {"###", "#..", "###", "..#", "###"},
+	'6': {"###", "#..", "###", "#.#", "###"},
+	'7': {"###", "..#", "..#", "..#", "..#"},
+	'8': {"###", "#.#", "###", "#.#", "###"},
+	'9': {"###", "#.#", "###", "..#", "###"},
+	':': {"...", ".#.", "...", ".#.", "..."},
+}
+
+// drawTimestamp 在格子右下角绘制白色文字和半透明背景
+func drawTimestamp(dst *image.RGBA, tile image.Rectangle, text string) {
+	const (
+		gw, gh  = 3 * timestampScale, 5 * timestampScale
+		spacing = timestampScale
+		margin  = 2 * timestampScale
+	)
+
+	tw := len(text)*(gw+spacing) - spacing
+	box := image.Rect(tile.Max.X-tw-2*margin, tile.Max.Y-gh-2*margin, tile.Max.X, tile.Max.Y).Intersect(tile)
+	draw.Draw(dst, box, image.NewUniform(color.RGBA{A: 0x99}), image.Point{}, draw.Over)
+
+	x := box.Min.X + margin
+	y := box.Min.Y + margin
+	for _, r := range text {
+		glyph, ok := glyphs[r]
+		if !ok {
+			x += gw + spacing
+			continue
+		}
+		for gy, line := range glyph {
+			for gx := range line {
+				if line[gx] != '#' {
+					continue
+				}
+				dot := image.Rect(x+gx*timestampScale, y+gy*timestampScale,
+					x+(gx+1)*timestampScale, y+(gy+1)*timestampScale).Intersect(tile)
+				draw.Draw(dst, dot, image.NewUniform(color.White), image.Point{}, draw.Src)
+			}
+		}
+		x += gw + spacing
+	}
+}
+
+// PreviewClip 从视频中均匀截取若干片段拼接成无声的 mp4 动图
+func (p *VideoProcessor) PreviewClip(ctx context.Context, inputPath string, info *VideoInfo, opts PreviewClipOptions) error {
+	if info.Duration <= 0 {
+		return errors.New("unknown video duration")
+	}
+	if opts.Segments <= 0 {
+		opts.Segments = 6
+	}
+	if opts.SegmentDuration <= 0 {
+		opts.SegmentDuration = 2
+	}
+	if opts.Width <= 0 {
+		opts.Width = 480
+	}
+
+	cmd := exec.CommandContext(ctx, p.ffmpegPath, previewClipArgs(inputPath, info.Duration, opts)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		_ = os.Remove(opts.OutputPath)
+		return errors.Wrapf(err, "preview clip: %s", lastLine(string(output)))
+	}
+
+	return nil
+}
+
+func previewClipArgs(inputPath string, duration float64, opts PreviewClipOptions) []string {
+	segments, segDuration := opts.Segments, opts.SegmentDuration
+	// short video is used as a whole
+	if float64(segments)*segDuration >= duration {
+		segments, segDuration = 1, duration
+	}
+
+	args := []string{"-hide_banner"}
+	filters := make([]string, 0, segments+1)
+	labels := ""
+	for i, t := range sampleTimes(duration, segments) {
+		start := t - segDuration/2
+		if start < 0 {
+			start = 0
+		}
+		args = append(args,
+			"-ss", fmt.Sprintf("%.3f", start),
+			"-t", fmt.Sprintf("%.3f", segDuration),
+			"-i", inputPath,
+		)
+		filters = append(filters, fmt.Sprintf("[%d:v:0]scale=%d:-2,setsar=1,fps=25[v%d]", i, opts.Width&^1, i))
+		labels += fmt.Sprintf("[v%d]", i)
+	}
+	filters = append(filters, fmt.Sprintf("%sconcat=n=%d:v=1:a=0[out]", labels, segments))
+
+	return append(args,
+		"-filter_complex", strings.Join(filters, ";"),
+		"-map", "[out]",
+		"-an",
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", "28",
+		"-pix_fmt", "yuv420p",
+		"-movflags", "+faststart",
+		"-f", "mp4",
+		"-y",
+		opts.OutputPath,
+	)
+}
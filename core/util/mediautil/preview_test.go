@@ -0,0 +1,84 @@
+package mediautil
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleTimes(t *testing.T) {
+	assert.Equal(t, []float64{12.5, 37.5, 62.5, 87.5}, sampleTimes(100, 4))
+}
+
+func TestTileSize(t *testing.T) {
+	w, h := tileSize(&VideoInfo{Width: 1920, Height: 1080}, 320)
+	assert.Equal(t, []int{320, 180}, []int{w, h})
+
+	w, h = tileSize(&VideoInfo{Width: 1080, Height: 1920}, 320)
+	assert.Equal(t, []int{320, 568}, []int{w, h})
+
+	w, h = tileSize(&VideoInfo{Width: 720, Height: 481}, 320)
+	assert.Equal(t, []int{320, 214}, []int{w, h})
+
+	w, h = tileSize(&VideoInfo{}, 320)
+	assert.Equal(t, []int{320, 180}, []int{w, h})
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	assert.Equal(t, "0:05", formatTimestamp(5.9))
+	assert.Equal(t, "12:34", formatTimestamp(754))
+	assert.Equal(t, "1:02:03", formatTimestamp(3723))
+}
+
+func TestComposeSheet(t *testing.T) {
+	red := image.NewRGBA(image.Rect(0, 0, 80, 60))
+	draw.Draw(red, red.Bounds(), image.NewUniform(color.RGBA{R: 0xFF, A: 0xFF}), image.Point{}, draw.Src)
+
+	// second tile is missing
+	sheet := composeSheet([]image.Image{red, nil, red}, []float64{1, 2, 3}, 2, 80, 60)
+	require.Equal(t, image.Rect(0, 0, 2*84+4, 2*64+4), sheet.Bounds())
+
+	assert.Equal(t, color.RGBA{A: 0xFF}, sheet.RGBAAt(0, 0), "padding")
+	assert.Equal(t, color.RGBA{R: 0xFF, A: 0xFF}, sheet.RGBAAt(4, 4), "first tile")
+	assert.Equal(t, color.RGBA{A: 0xFF}, sheet.RGBAAt(88, 4), "missing tile")
+	assert.Equal(t, color.RGBA{R: 0xFF, A: 0xFF}, sheet.RGBAAt(4, 68), "third tile")
+
+	// timestamp is drawn at bottom right of the tile
+	white := 0
+	for y := 4 + 60 - 20; y < 4+60; y++ {
+		for x := 4 + 80 - 40; x < 4+80; x++ {
+			if sheet.RGBAAt(x, y) == (color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}) {
+				white++
+			}
+		}
+	}
+	assert.Greater(t, white, 0)
+}
+
+func TestPreviewClipArgs(t *testing.T) {
+	args := previewClipArgs("in.mkv", 100, PreviewClipOptions{Segments: 2, SegmentDuration: 2, Width: 481, OutputPath: "out.mp4"})
+	assert.Equal(t, []string{
+		"-hide_banner",
+		"-ss", "24.000", "-t", "2.000", "-i", "in.mkv",
+		"-ss", "74.000", "-t", "2.000", "-i", "in.mkv",
+		"-filter_complex", "[0:v:0]scale=480:-2,setsar=1,fps=25[v0];[1:v:0]scale=480:-2,setsar=1,fps=25[v1];[v0][v1]concat=n=2:v=1:a=0[out]",
+		"-map", "[out]",
+		"-an",
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", "28",
+		"-pix_fmt", "yuv420p",
+		"-movflags", "+faststart",
+		"-f", "mp4",
+		"-y",
+		"out.mp4",
+	}, args)
+
+	// short video is used as a whole
+	args = previewClipArgs("in.mp4", 3, PreviewClipOptions{Segments: 6, SegmentDuration: 2, Width: 480, OutputPath: "out.mp4"})
+	assert.Equal(t, []string{"-hide_banner", "-ss", "0.000", "-t", "3.000", "-i", "in.mp4"}, args[:7])
+}
@@ -0,0 +1,1390 @@
+package mediautil
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	_ "image/gif" // register decoders of cover art
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/go-faster/errors"
+)
+
+// ProbeVideo 获取视频信息，优先使用内置解析器(MP4/MOV/MKV/WebM/TS)，信息不完整时回退到 ffmpeg
+func ProbeVideo(ctx context.Context, ffmpegPath, path string) (*VideoInfo, error) {
+	info, err := ProbeVideoNative(path)
+	if err == nil && info.Duration > 0 && info.Width > 0 && info.Height > 0 {
+		return info, nil
+	}
+
+	if vp := GetVideoProcessor(ffmpegPath); vp != nil {
+		if fallback, ferr := vp.GetVideoInfo(ctx, path); ferr == nil {
+			return fallback, nil
+		}
+	}
+
+	// incomplete native result is still better than nothing
+	if err != nil {
+		return nil, errors.Wrap(err, "probe video")
+	}
+	return info, nil
+}
+
+// ProbeVideoNative 不依赖 ffmpeg 获取视频信息，支持 MP4/MOV/MKV/WebM/TS
+func ProbeVideoNative(path string) (*VideoInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "open video")
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, errors.Wrap(err, "stat video")
+	}
+
+	header := make([]byte, 12)
+	if _, err = io.ReadFull(f, header); err != nil {
+		return nil, errors.Wrap(err, "read header")
+	}
+
+	info := &VideoInfo{
+		FilePath: path,
+		Size:     stat.Size(),
+	}
+
+	switch {
+	case bytes.Equal(header[4:8], []byte("ftyp")), bytes.Equal(header[4:8], []byte("moov")),
+		bytes.Equal(header[4:8], []byte("mdat")), bytes.Equal(header[4:8], []byte("wide")):
+		info.Container = "mp4"
+		if bytes.Equal(header[8:12], []byte("qt  ")) {
+			info.Container = "mov"
+		}
+		if err = probeMP4(f, stat.Size(), info); err != nil {
+			// e.g. moov box is broken, try gomedia demuxer which only reports the H.264 track
+			if derr := probeMP4Demuxer(f, info); derr == nil {
+				err = nil
+			}
+		}
+	case bytes.Equal(header[0:4], []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		info.Container = "matroska"
+		err = probeMatroska(f, stat.Size(), info)
+	case header[0] == tsSyncByte || header[4] == tsSyncByte:
+		info.Container = "mpegts"
+		err = probeTS(f, stat.Size(), info)
+	default:
+		err = errors.New("unsupported container")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Duration > 0 {
+		info.Bitrate = int64(float64(info.Size) * 8 / info.Duration / 1000) // kb/s
+	}
+
+	return info, nil
+}
+
+// ExtractCover 提取 MP4/MOV 封面或 MKV/WebM 附件中的封面图片，缩放后保存为 JPEG 缩略图。
+// 不解码 H.264 关键帧：依赖中没有纯 Go 的 H.264 解码器，没有封面的视频仍需要 ffmpeg 生成缩略图。
+func ExtractCover(path, outputPath string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "open video")
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return errors.Wrap(err, "stat video")
+	}
+
+	header := make([]byte, 8)
+	if _, err = io.ReadFull(f, header); err != nil {
+		return errors.Wrap(err, "read header")
+	}
+
+	var cover []byte
+	switch {
+	case bytes.Equal(header[0:4], []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		cover, err = matroskaCover(f, stat.Size())
+	default:
+		cover, err = mp4Cover(f, stat.Size())
+	}
+	if err != nil {
+		return err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(cover))
+	if err != nil {
+		return errors.Wrap(err, "decode cover")
+	}
+
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return errors.Wrap(err, "create thumbnail")
+	}
+
+	// Telegram thumbnail should not exceed 320px
+	err = jpeg.Encode(out, resize(img, 320), &jpeg.Options{Quality: 85})
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(outputPath)
+		return errors.Wrap(err, "write thumbnail")
+	}
+
+	return nil
+}
+
+// resize 等比缩放图片使最长边不超过 max，使用区域平均采样
+func resize(src image.Image, max int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= max && h <= max {
+		return src
+	}
+
+	scale := float64(max) / float64(w)
+	if h > w {
+		scale = float64(max) / float64(h)
+	}
+	return resizeTo(src, int(float64(w)*scale), int(float64(h)*scale))
+}
+
+// resizeTo 缩放图片到 dw×dh，使用区域平均采样
+func resizeTo(src image.Image, dw, dh int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+(y+1)*h/dh
+		for x := 0; x < dw; x++ {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+(x+1)*w/dw
+
+			var r, g, bl, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+cr, g+cg, bl+cb, a+ca, n+1
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(bl / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+
+	return dst
+}
+
+// ---------- MP4/MOV ----------
+
+var mp4Codecs = map[string]string{
+	"avc1": "h264",
+	"avc3": "h264",
+	"hvc1": "hevc",
+	"hev1": "hevc",
+	"av01": "av1",
+	"vp09": "vp9",
+	"vp08": "vp8",
+	"mp4v": "mpeg4",
+	"mp4a": "aac",
+	"ac-3": "ac3",
+	"ec-3": "eac3",
+	"Opus": "opus",
+	"fLaC": "flac",
+	".mp3": "mp3",
+	"tx3g": "mov_text",
+	"wvtt": "webvtt",
+	"stpp": "ttml",
+}
+
+// handler types of mdia/hdlr
+var mp4Handlers = map[string]string{
+	"vide": StreamVideo,
+	"soun": StreamAudio,
+	"text": StreamSubtitle,
+	"sbtl": StreamSubtitle,
+	"subt": StreamSubtitle,
+}
+
+func probeMP4(r io.ReadSeeker, size int64, info *VideoInfo) error {
+	moov, ok, err := findBox(r, 0, size, "moov")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("moov box not found")
+	}
+
+	if mvhd, ok, err := findBox(r, moov.dataStart(), moov.end(), "mvhd"); err == nil && ok {
+		if b, err := readBox(r, mvhd); err == nil {
+			info.Duration, _ = parseTimeBox(b)
+		}
+	}
+
+	traks, err := findBoxes(r, moov.dataStart(), moov.end(), "trak")
+	if err != nil {
+		return err
+	}
+
+	video := false
+	for i, trak := range traks {
+		track, err := probeMP4Track(r, trak)
+		if err != nil {
+			return err
+		}
+		if track == nil {
+			continue
+		}
+		track.stream.Index = i
+		info.Streams = append(info.Streams, track.stream)
+
+		if track.stream.Type != StreamVideo || video {
+			continue
+		}
+		video = true
+
+		info.Codec = track.stream.Codec
+		info.Width, info.Height = track.width, track.height
+		info.FrameRate = track.frameRate
+		info.Rotation = track.rotation
+		info.HDR = track.hdr
+		if info.Duration <= 0 {
+			info.Duration = track.duration
+		}
+	}
+	if !video {
+		return errors.New("no video track found")
+	}
+
+	return nil
+}
+
+// probeMP4Demuxer probes H.264 track by gomedia demuxer
+func probeMP4Demuxer(r io.ReadSeeker, info *VideoInfo) error {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	duration, width, height, err := GetMP4Info(r)
+	if err != nil {
+		return errors.Wrap(err, "demux mp4")
+	}
+
+	info.Duration = float64(duration)
+	info.Width, info.Height = width, height
+	info.Codec = "h264"
+	info.Streams = []StreamInfo{{Index: 0, Type: StreamVideo, Codec: "h264"}}
+	return nil
+}
+
+type mp4Track struct {
+	stream        StreamInfo
+	width, height int // display dimensions
+	rotation      int
+	duration      float64
+	frameRate     float64
+	hdr           bool
+}
+
+// probeMP4Track returns nil if trak is not a video, audio or subtitle track
+func probeMP4Track(r io.ReadSeeker, trak box) (*mp4Track, error) {
+	mdia, ok, err := findBox(r, trak.dataStart(), trak.end(), "mdia")
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	hdlr, ok, err := findBox(r, mdia.dataStart(), mdia.end(), "hdlr")
+	if err != nil || !ok {
+		return nil, err
+	}
+	b, err := readBox(r, hdlr)
+	if err != nil || len(b) < 12 {
+		return nil, err
+	}
+	typ, ok := mp4Handlers[string(b[8:12])]
+	if !ok {
+		return nil, nil
+	}
+
+	track := &mp4Track{stream: StreamInfo{Type: typ}}
+
+	// tkhd: width, height and rotation matrix
+	if tkhd, ok, err := findBox(r, trak.dataStart(), trak.end(), "tkhd"); err == nil && ok && typ == StreamVideo {
+		if b, err := readBox(r, tkhd); err == nil {
+			matrix := 40
+			if len(b) > 0 && b[0] == 1 { // version 1
+				matrix = 52
+			}
+			if len(b) >= matrix+44 {
+				// matrix is {a, b, u, c, d, v, x, y, w}, clockwise rotation is atan2(b, a)
+				ma := int32(binary.BigEndian.Uint32(b[matrix:]))
+				mb := int32(binary.BigEndian.Uint32(b[matrix+4:]))
+				rotation := int(math.Round(math.Atan2(float64(mb), float64(ma)) * 180 / math.Pi))
+				track.rotation = (rotation + 360) % 360
+				track.width = int(binary.BigEndian.Uint32(b[matrix+36:]) >> 16)
+				track.height = int(binary.BigEndian.Uint32(b[matrix+40:]) >> 16)
+			}
+		}
+	}
+	// tkhd dimensions are before matrix transform, use display dimensions
+	if track.rotation == 90 || track.rotation == 270 {
+		track.width, track.height = track.height, track.width
+	}
+
+	if mdhd, ok, err := findBox(r, mdia.dataStart(), mdia.end(), "mdhd"); err == nil && ok {
+		if b, err := readBox(r, mdhd); err == nil {
+			track.duration, _ = parseTimeBox(b)
+			track.stream.Language = mdhdLanguage(b)
+		}
+	}
+
+	minf, ok, err := findBox(r, mdia.dataStart(), mdia.end(), "minf")
+	if err != nil || !ok {
+		return track, err
+	}
+	stbl, ok, err := findBox(r, minf.dataStart(), minf.end(), "stbl")
+	if err != nil || !ok {
+		return track, err
+	}
+
+	// stsd: first sample entry is codec
+	if stsd, ok, err := findBox(r, stbl.dataStart(), stbl.end(), "stsd"); err == nil && ok {
+		if b, err := readBox(r, stsd); err == nil && len(b) >= 16 {
+			fourcc := string(b[12:16])
+			if codec, ok := mp4Codecs[fourcc]; ok {
+				track.stream.Codec = codec
+			} else {
+				track.stream.Codec = strings.TrimSpace(fourcc)
+			}
+			// audio sample entry: 6 reserved, 2 data reference index, 8 reserved, 2 channel count
+			if typ == StreamAudio && len(b) >= 34 {
+				track.stream.Channels = int(binary.BigEndian.Uint16(b[32:34]))
+			}
+			if typ == StreamVideo {
+				track.hdr = mp4HDR(b[8:])
+			}
+		}
+	}
+
+	// stts: total samples for frame rate
+	if stts, ok, err := findBox(r, stbl.dataStart(), stbl.end(), "stts"); err == nil && ok && typ == StreamVideo && track.duration > 0 {
+		if b, err := readBox(r, stts); err == nil && len(b) >= 8 {
+			n := int(binary.BigEndian.Uint32(b[4:8]))
+			samples := uint64(0)
+			for i := 0; i < n && 8+i*8+8 <= len(b); i++ {
+				samples += uint64(binary.BigEndian.Uint32(b[8+i*8:]))
+			}
+			track.frameRate = math.Round(float64(samples)/track.duration*100) / 100
+		}
+	}
+
+	return track, nil
+}
+
+// mp4HDR reports whether transfer characteristics of colr box in visual sample entry is PQ or HLG
+func mp4HDR(entry []byte) bool {
+	if len(entry) < 8 {
+		return false
+	}
+	size := min(int(binary.BigEndian.Uint32(entry)), len(entry))
+
+	// 8 bytes header and 78 bytes visual sample entry fields, then child boxes
+	for i := 86; i+8 <= size; {
+		n := int(binary.BigEndian.Uint32(entry[i:]))
+		if n < 8 || i+n > size {
+			return false
+		}
+		if string(entry[i+4:i+8]) == "colr" && n >= 16 && string(entry[i+8:i+12]) == "nclx" {
+			return isHDRTransfer(uint64(binary.BigEndian.Uint16(entry[i+14:])))
+		}
+		i += n
+	}
+
+	return false
+}
+
+// isHDRTransfer reports whether transfer characteristics is PQ(16) or HLG(18), refer to ITU-T H.273
+func isHDRTransfer(t uint64) bool {
+	return t == 16 || t == 18
+}
+
+// mdhdLanguage returns packed ISO 639-2 language of mdhd, or empty if undetermined
+func mdhdLanguage(b []byte) string {
+	offset := 20
+	if len(b) > 0 && b[0] == 1 { // version 1
+		offset = 32
+	}
+	if len(b) < offset+2 {
+		return ""
+	}
+
+	packed := binary.BigEndian.Uint16(b[offset:])
+	lang := string([]byte{
+		byte(packed>>10&0x1F) + 0x60,
+		byte(packed>>5&0x1F) + 0x60,
+		byte(packed&0x1F) + 0x60,
+	})
+	if lang == "und" || packed == 0 {
+		return ""
+	}
+	return lang
+}
+
+// parseTimeBox parses duration in seconds and timescale of mvhd or mdhd
+func parseTimeBox(b []byte) (float64, uint32) {
+	var timescale uint32
+	var duration uint64
+	switch {
+	case len(b) >= 32 && b[0] == 1: // version 1
+		timescale = binary.BigEndian.Uint32(b[20:24])
+		duration = binary.BigEndian.Uint64(b[24:32])
+	case len(b) >= 20:
+		timescale = binary.BigEndian.Uint32(b[12:16])
+		duration = uint64(binary.BigEndian.Uint32(b[16:20]))
+	}
+	if timescale == 0 {
+		return 0, 0
+	}
+
+	return float64(duration) / float64(timescale), timescale
+}
+
+func mp4Cover(r io.ReadSeeker, size int64) ([]byte, error) {
+	path := []string{"moov", "udta", "meta", "ilst", "covr", "data"}
+
+	b := box{start: 0, size: size}
+	for _, typ := range path {
+		start := b.dataStart()
+		if b.typ == "meta" { // meta is a full box with 4 bytes version and flags
+			start += 4
+		}
+
+		next, ok, err := findBox(r, start, b.end(), typ)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errors.Errorf("%s box not found", typ)
+		}
+		b = next
+	}
+
+	data, err := readBox(r, b)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) <= 8 {
+		return nil, errors.New("empty cover")
+	}
+	// 4 bytes type and 4 bytes locale
+	return data[8:], nil
+}
+
+// findBoxes finds all boxes with given type in [start, end)
+func findBoxes(r io.ReadSeeker, start, end int64, typ string) ([]box, error) {
+	boxes := make([]box, 0)
+	for start < end {
+		b, ok, err := findBox(r, start, end, typ)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		boxes = append(boxes, b)
+		start = b.end()
+	}
+
+	return boxes, nil
+}
+
+// ---------- Matroska/WebM ----------
+
+// EBML element IDs, refer to https://www.matroska.org/technical/elements.html
+const (
+	mkvSegment       = 0x18538067
+	mkvInfo          = 0x1549A966
+	mkvTimecodeScale = 0x2AD7B1
+	mkvDuration      = 0x4489
+	mkvTracks        = 0x1654AE6B
+	mkvTrackEntry    = 0xAE
+	mkvTrackType     = 0x83
+	mkvCodecID       = 0x86
+	mkvName          = 0x536E
+	mkvLanguage      = 0x22B59C
+	mkvLanguageBCP47 = 0x22B59D
+	mkvFlagDefault   = 0x88
+	mkvAudio         = 0xE1
+	mkvChannels      = 0x9F
+	mkvColour        = 0x55B0
+	mkvTransfer      = 0x55BA
+	mkvDefaultDur    = 0x23E383
+	mkvVideo         = 0xE0
+	mkvPixelWidth    = 0xB0
+	mkvPixelHeight   = 0xBA
+	mkvCluster       = 0x1F43B675
+	mkvAttachments   = 0x1941A469
+	mkvAttachedFile  = 0x61A7
+	mkvFileName      = 0x466E
+	mkvFileMimeType  = 0x4660
+	mkvFileData      = 0x465C
+
+	// max size of metadata element to be read into memory
+	mkvMaxElementSize = 32 << 20
+)
+
+// track types of TrackType element
+var mkvTrackTypes = map[uint64]string{
+	1:    StreamVideo,
+	2:    StreamAudio,
+	0x11: StreamSubtitle,
+}
+
+var mkvCodecs = map[string]string{
+	"A_AAC":            "aac",
+	"A_MPEG/L3":        "mp3",
+	"A_AC3":            "ac3",
+	"A_EAC3":           "eac3",
+	"A_DTS":            "dts",
+	"S_TEXT/UTF8":      "subrip",
+	"S_TEXT/ASS":       "ass",
+	"S_TEXT/SSA":       "ssa",
+	"S_TEXT/WEBVTT":    "webvtt",
+	"S_HDMV/PGS":       "hdmv_pgs_subtitle",
+	"S_VOBSUB":         "dvd_subtitle",
+	"V_MPEG4/ISO/AVC":  "h264",
+	"V_MPEGH/ISO/HEVC": "hevc",
+	"V_AV1":            "av1",
+	"V_VP9":            "vp9",
+	"V_VP8":            "vp8",
+	"V_MPEG4/ISO/ASP":  "mpeg4",
+	"V_MPEG2":          "mpeg2video",
+}
+
+// mkvElements iterates top level elements of segment. fn returns false to stop.
+func mkvElements(r io.ReadSeeker, size int64, fn func(id uint32, data []byte) bool) error {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	br := &countReader{r: r}
+
+	// EBML header
+	if _, err := readEBMLID(br); err != nil {
+		return errors.Wrap(err, "read EBML header")
+	}
+	headerSize, err := readEBMLSize(br)
+	if err != nil || headerSize < 0 {
+		return errors.New("invalid EBML header")
+	}
+	pos := br.n + headerSize
+
+	// segment
+	if _, err = r.Seek(pos, io.SeekStart); err != nil {
+		return err
+	}
+	br.n = pos
+	id, err := readEBMLID(br)
+	if err != nil || id != mkvSegment {
+		return errors.New("segment not found")
+	}
+	segSize, err := readEBMLSize(br)
+	if err != nil {
+		return err
+	}
+	end := size
+	if segSize >= 0 && br.n+segSize < end {
+		end = br.n + segSize
+	}
+
+	for pos = br.n; pos < end; {
+		if _, err = r.Seek(pos, io.SeekStart); err != nil {
+			return err
+		}
+		br.n = pos
+
+		id, err := readEBMLID(br)
+		if err != nil {
+			return nil // truncated file
+		}
+		n, err := readEBMLSize(br)
+		if err != nil || n < 0 {
+			// unknown size (live stream), can't skip it
+			return nil
+		}
+		dataStart := br.n
+
+		switch id {
+		case mkvInfo, mkvTracks, mkvAttachments:
+			if n > mkvMaxElementSize {
+				return errors.Errorf("element %x is too large", id)
+			}
+			data := make([]byte, n)
+			if _, err = io.ReadFull(br, data); err != nil {
+				return errors.Wrap(err, "read element")
+			}
+			if !fn(id, data) {
+				return nil
+			}
+		}
+
+		pos = dataStart + n
+	}
+
+	return nil
+}
+
+func probeMatroska(r io.ReadSeeker, size int64, info *VideoInfo) error {
+	scale := uint64(1000000) // default timecode scale, ns
+	duration := 0.0
+	tracks := false
+
+	err := mkvElements(r, size, func(id uint32, data []byte) bool {
+		switch id {
+		case mkvInfo:
+			eachEBML(data, func(id uint32, b []byte) {
+				switch id {
+				case mkvTimecodeScale:
+					scale = ebmlUint(b)
+				case mkvDuration:
+					duration = ebmlFloat(b)
+				}
+			})
+		case mkvTracks:
+			tracks = true
+			eachEBML(data, func(id uint32, b []byte) {
+				if id == mkvTrackEntry {
+					probeMatroskaTrack(b, info)
+				}
+			})
+		}
+
+		// tracks and info are before clusters in most files, but stop only when both are found
+		return !(tracks && duration > 0)
+	})
+	if err != nil {
+		return err
+	}
+	if len(info.StreamsOf(StreamVideo)) == 0 {
+		return errors.New("no video track found")
+	}
+
+	info.Duration = duration * float64(scale) / 1e9
+	return nil
+}
+
+func probeMatroskaTrack(entry []byte, info *VideoInfo) {
+	stream := StreamInfo{
+		Index:    len(info.Streams),
+		Language: "eng", // default value of Language element
+		Default:  true,  // default value of FlagDefault element
+	}
+	var width, height int
+	var frameRate float64
+	hdr := false
+
+	eachEBML(entry, func(id uint32, b []byte) {
+		switch id {
+		case mkvTrackType:
+			stream.Type = mkvTrackTypes[ebmlUint(b)]
+		case mkvCodecID:
+			codec := string(b)
+			if c, ok := mkvCodecs[codec]; ok {
+				stream.Codec = c
+			} else {
+				// V_VP9 -> vp9, A_OPUS -> opus
+				_, name, _ := strings.Cut(codec, "_")
+				stream.Codec = strings.ToLower(name)
+			}
+		case mkvName:
+			stream.Title = string(b)
+		case mkvLanguage, mkvLanguageBCP47:
+			stream.Language = string(b)
+		case mkvFlagDefault:
+			stream.Default = ebmlUint(b) == 1
+		case mkvDefaultDur:
+			if ns := ebmlUint(b); ns > 0 {
+				frameRate = math.Round(1e9/float64(ns)*100) / 100
+			}
+		case mkvVideo:
+			eachEBML(b, func(id uint32, b []byte) {
+				switch id {
+				case mkvPixelWidth:
+					width = int(ebmlUint(b))
+				case mkvPixelHeight:
+					height = int(ebmlUint(b))
+				case mkvColour:
+					eachEBML(b, func(id uint32, b []byte) {
+						if id == mkvTransfer {
+							hdr = isHDRTransfer(ebmlUint(b))
+						}
+					})
+				}
+			})
+		case mkvAudio:
+			eachEBML(b, func(id uint32, b []byte) {
+				if id == mkvChannels {
+					stream.Channels = int(ebmlUint(b))
+				}
+			})
+		}
+	})
+
+	if stream.Type == "" {
+		return
+	}
+	if stream.Language == "und" {
+		stream.Language = ""
+	}
+
+	// the first video track is main video
+	if stream.Type == StreamVideo && len(info.StreamsOf(StreamVideo)) == 0 {
+		info.Codec = stream.Codec
+		info.Width, info.Height = width, height
+		info.FrameRate = frameRate
+		info.HDR = hdr
+	}
+	info.Streams = append(info.Streams, stream)
+}
+
+func matroskaCover(r io.ReadSeeker, size int64) ([]byte, error) {
+	var cover []byte
+	score := 0
+
+	err := mkvElements(r, size, func(id uint32, data []byte) bool {
+		if id != mkvAttachments {
+			return true
+		}
+
+		eachEBML(data, func(id uint32, b []byte) {
+			if id != mkvAttachedFile {
+				return
+			}
+
+			var name, mime string
+			var file []byte
+			eachEBML(b, func(id uint32, b []byte) {
+				switch id {
+				case mkvFileName:
+					name = strings.ToLower(string(b))
+				case mkvFileMimeType:
+					mime = string(b)
+				case mkvFileData:
+					file = b
+				}
+			})
+			if !IsImage(mime) {
+				return
+			}
+
+			// prefer cover.* refer to https://www.matroska.org/technical/attachments.html
+			s := 1
+			if strings.HasPrefix(name, "cover") {
+				s = 2
+			}
+			if s > score {
+				cover, score = file, s
+			}
+		})
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cover == nil {
+		return nil, errors.New("no cover attachment found")
+	}
+
+	return cover, nil
+}
+
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countReader) ReadByte() (byte, error) {
+	b := make([]byte, 1)
+	if _, err := io.ReadFull(c, b); err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// readEBMLID reads element ID with its length marker
+func readEBMLID(r io.ByteReader) (uint32, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	length := bitsLen(first)
+	if length == 0 || length > 4 {
+		return 0, errors.Errorf("invalid EBML id %x", first)
+	}
+
+	id := uint32(first)
+	for i := 1; i < length; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		id = id<<8 | uint32(b)
+	}
+
+	return id, nil
+}
+
+// readEBMLSize reads element data size, -1 means unknown size
+func readEBMLSize(r io.ByteReader) (int64, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	length := bitsLen(first)
+	if length == 0 {
+		return 0, errors.Errorf("invalid EBML size %x", first)
+	}
+
+	mask := byte(0xFF >> length)
+	size := uint64(first & mask)
+	unknown := first&mask == mask
+	for i := 1; i < length; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		size = size<<8 | uint64(b)
+		unknown = unknown && b == 0xFF
+	}
+	if unknown {
+		return -1, nil
+	}
+
+	return int64(size), nil
+}
+
+// bitsLen returns length of EBML variable size integer by leading zeros of first byte
+func bitsLen(first byte) int {
+	for i := 0; i < 8; i++ {
+		if first&(0x80>>i) != 0 {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// eachEBML iterates child elements in data
+func eachEBML(data []byte, fn func(id uint32, b []byte)) {
+	r := bytes.NewReader(data)
+	for r.Len() > 0 {
+		id, err := readEBMLID(r)
+		if err != nil {
+			return
+		}
+		n, err := readEBMLSize(r)
+		if err != nil || n < 0 || n > int64(r.Len()) {
+			return
+		}
+
+		start := len(data) - r.Len()
+		fn(id, data[start:start+int(n)])
+		if _, err = r.Seek(n, io.SeekCurrent); err != nil {
+			return
+		}
+	}
+}
+
+func ebmlUint(b []byte) uint64 {
+	v := uint64(0)
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func ebmlFloat(b []byte) float64 {
+	switch len(b) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// ---------- MPEG-TS ----------
+
+const (
+	tsSyncByte   = 0x47
+	tsPacketSize = 188
+	// bytes to scan at head and tail of file
+	tsScanSize = 4 << 20
+)
+
+// stream types of PMT, refer to ISO/IEC 13818-1
+var tsCodecs = map[byte]StreamInfo{
+	0x01: {Type: StreamVideo, Codec: "mpeg1video"},
+	0x02: {Type: StreamVideo, Codec: "mpeg2video"},
+	0x10: {Type: StreamVideo, Codec: "mpeg4"},
+	0x1B: {Type: StreamVideo, Codec: "h264"},
+	0x24: {Type: StreamVideo, Codec: "hevc"},
+	0x03: {Type: StreamAudio, Codec: "mp2"},
+	0x04: {Type: StreamAudio, Codec: "mp3"},
+	0x0F: {Type: StreamAudio, Codec: "aac"},
+	0x11: {Type: StreamAudio, Codec: "aac_latm"},
+	0x81: {Type: StreamAudio, Codec: "ac3"},
+	0x87: {Type: StreamAudio, Codec: "eac3"},
+}
+
+// descriptors of private stream (0x06), refer to ETSI EN 300 468
+var tsDescriptorCodecs = map[byte]StreamInfo{
+	0x59: {Type: StreamSubtitle, Codec: "dvb_subtitle"},
+	0x56: {Type: StreamSubtitle, Codec: "dvb_teletext"},
+	0x6A: {Type: StreamAudio, Codec: "ac3"},
+	0x7A: {Type: StreamAudio, Codec: "eac3"},
+}
+
+type tsStream struct {
+	pid    int
+	stream StreamInfo
+}
+
+type tsPacket struct {
+	pid     uint16
+	start   bool // payload unit start indicator
+	payload []byte
+}
+
+func probeTS(r io.ReadSeeker, size int64, info *VideoInfo) error {
+	head, stride, offset, err := readTS(r, 0, min(size, tsScanSize))
+	if err != nil {
+		return err
+	}
+
+	packets := splitTS(head, stride, offset)
+
+	// PAT -> PMT -> video PID
+	pmtPID := -1
+	videoPID := -1
+	for _, p := range packets {
+		switch {
+		case p.pid == 0 && p.start && pmtPID < 0:
+			pmtPID = parsePAT(p.payload)
+		case pmtPID >= 0 && int(p.pid) == pmtPID && p.start && videoPID < 0:
+			for i, s := range parsePMT(p.payload) {
+				s.stream.Index = i
+				info.Streams = append(info.Streams, s.stream)
+				if s.stream.Type == StreamVideo && videoPID < 0 {
+					videoPID = s.pid
+					info.Codec = s.stream.Codec
+				}
+			}
+		}
+	}
+	if videoPID < 0 {
+		return errors.New("no video stream found")
+	}
+
+	// first PTS and SPS of video
+	firstPTS := int64(-1)
+	var es []byte
+	for _, p := range packets {
+		if int(p.pid) != videoPID {
+			continue
+		}
+		if p.start {
+			pts, payload, ok := parsePES(p.payload)
+			if !ok {
+				continue
+			}
+			if firstPTS < 0 && pts >= 0 {
+				firstPTS = pts
+			}
+			es = append(es, payload...)
+		} else if es != nil {
+			es = append(es, p.payload...)
+		}
+		if len(es) > 1<<20 {
+			break
+		}
+	}
+	if info.Codec == "h264" {
+		if sps, ok := findNAL(es, 7); ok {
+			if w, h, err := parseH264SPS(sps); err == nil {
+				info.Width, info.Height = w, h
+			}
+		}
+	}
+
+	// last PTS of video
+	tailStart := max(size-tsScanSize, 0)
+	tail, stride, offset, err := readTS(r, tailStart, size-tailStart)
+	if err != nil {
+		return err
+	}
+	lastPTS := int64(-1)
+	for _, p := range splitTS(tail, stride, offset) {
+		if int(p.pid) != videoPID || !p.start {
+			continue
+		}
+		if pts, _, ok := parsePES(p.payload); ok && pts >= 0 {
+			lastPTS = pts
+		}
+	}
+
+	if firstPTS >= 0 && lastPTS >= 0 {
+		diff := lastPTS - firstPTS
+		if diff < 0 { // 33 bits PTS wrapped
+			diff += 1 << 33
+		}
+		info.Duration = float64(diff) / 90000
+	}
+
+	return nil
+}
+
+// readTS reads n bytes at start and detects packet stride (188 or 192 for M2TS) and sync offset
+func readTS(r io.ReadSeeker, start, n int64) ([]byte, int, int, error) {
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return nil, 0, 0, err
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, 0, 0, errors.Wrap(err, "read ts")
+	}
+
+	for _, stride := range []int{tsPacketSize, tsPacketSize + 4} {
+		for offset := 0; offset < stride && offset+stride*3 < len(data); offset++ {
+			if data[offset] == tsSyncByte && data[offset+stride] == tsSyncByte && data[offset+stride*2] == tsSyncByte {
+				return data, stride, offset, nil
+			}
+		}
+	}
+
+	return nil, 0, 0, errors.New("ts sync byte not found")
+}
+
+func splitTS(data []byte, stride, offset int) []tsPacket {
+	packets := make([]tsPacket, 0, len(data)/stride)
+	for i := offset; i+tsPacketSize <= len(data); i += stride {
+		pkt := data[i : i+tsPacketSize]
+		if pkt[0] != tsSyncByte {
+			continue
+		}
+
+		p := tsPacket{
+			pid:   uint16(pkt[1]&0x1F)<<8 | uint16(pkt[2]),
+			start: pkt[1]&0x40 != 0,
+		}
+
+		payload := 4
+		adaptation := pkt[3] >> 4 & 0x3
+		if adaptation&0x1 == 0 { // no payload
+			continue
+		}
+		if adaptation&0x2 != 0 {
+			payload += 1 + int(pkt[4])
+		}
+		if payload >= tsPacketSize {
+			continue
+		}
+		p.payload = pkt[payload:]
+		packets = append(packets, p)
+	}
+
+	return packets
+}
+
+// parsePAT returns PID of the first PMT
+func parsePAT(payload []byte) int {
+	section, ok := psiSection(payload)
+	if !ok {
+		return -1
+	}
+
+	// program entries after 8 bytes header, ends with 4 bytes CRC
+	for i := 8; i+4 <= len(section)-4; i += 4 {
+		program := binary.BigEndian.Uint16(section[i:])
+		if program != 0 { // 0 is network PID
+			return int(binary.BigEndian.Uint16(section[i+2:]) & 0x1FFF)
+		}
+	}
+
+	return -1
+}
+
+// parsePMT returns known streams of the program
+func parsePMT(payload []byte) []tsStream {
+	section, ok := psiSection(payload)
+	if !ok || len(section) < 12 {
+		return nil
+	}
+
+	streams := make([]tsStream, 0)
+	infoLen := int(binary.BigEndian.Uint16(section[10:]) & 0x0FFF)
+	for i := 12 + infoLen; i+5 <= len(section)-4; {
+		streamType := section[i]
+		pid := int(binary.BigEndian.Uint16(section[i+1:]) & 0x1FFF)
+		esInfoLen := int(binary.BigEndian.Uint16(section[i+3:]) & 0x0FFF)
+		descriptors := section[i+5 : min(i+5+esInfoLen, len(section))]
+		i += 5 + esInfoLen
+
+		stream, known := tsCodecs[streamType]
+		lang := ""
+		for d := 0; d+2 <= len(descriptors); {
+			tag, n := descriptors[d], int(descriptors[d+1])
+			if d+2+n > len(descriptors) {
+				break
+			}
+			body := descriptors[d+2 : d+2+n]
+			d += 2 + n
+
+			if (tag == 0x0A || tag == 0x59 || tag == 0x56) && n >= 3 { // language is the first 3 bytes
+				lang = string(body[:3])
+			}
+			if streamType == 0x06 && !known {
+				stream, known = tsDescriptorCodecs[tag]
+			}
+		}
+		if !known {
+			continue
+		}
+
+		stream.Language = lang
+		streams = append(streams, tsStream{pid: pid, stream: stream})
+	}
+
+	return streams
+}
+
+// psiSection skips pointer field and returns the section
+func psiSection(payload []byte) ([]byte, bool) {
+	if len(payload) < 1 {
+		return nil, false
+	}
+	start := 1 + int(payload[0])
+	if start+3 > len(payload) {
+		return nil, false
+	}
+
+	length := int(binary.BigEndian.Uint16(payload[start+1:]) & 0x0FFF)
+	end := start + 3 + length
+	if end > len(payload) {
+		return nil, false
+	}
+
+	return payload[start:end], true
+}
+
+// parsePES returns PTS(-1 if absent) and elementary stream payload
+func parsePES(payload []byte) (int64, []byte, bool) {
+	if len(payload) < 9 || payload[0] != 0 || payload[1] != 0 || payload[2] != 1 {
+		return -1, nil, false
+	}
+
+	headerLen := int(payload[8])
+	if 9+headerLen > len(payload) {
+		return -1, nil, false
+	}
+
+	pts := int64(-1)
+	if payload[7]&0x80 != 0 && headerLen >= 5 {
+		b := payload[9:14]
+		pts = int64(b[0]>>1&0x07)<<30 | int64(b[1])<<22 | int64(b[2]>>1)<<15 | int64(b[3])<<7 | int64(b[4]>>1)
+	}
+
+	return pts, payload[9+headerLen:], true
+}
+
+// ---------- H.264 ----------
+
+// findNAL finds the first NAL unit with given type in Annex B byte stream
+func findNAL(es []byte, typ byte) ([]byte, bool) {
+	for i := 0; i+3 < len(es); i++ {
+		if es[i] != 0 || es[i+1] != 0 || es[i+2] != 1 {
+			continue
+		}
+
+		start := i + 3
+		if es[start]&0x1F != typ {
+			continue
+		}
+
+		end := len(es)
+		for j := start; j+3 <= len(es); j++ {
+			if es[j] == 0 && es[j+1] == 0 && (es[j+2] == 1 || es[j+2] == 0) {
+				end = j
+				break
+			}
+		}
+		return es[start:end], true
+	}
+
+	return nil, false
+}
+
+// parseH264SPS returns width and height of sequence parameter set NAL unit, refer to ITU-T H.264 7.3.2.1.1
+func parseH264SPS(nal []byte) (int, int, error) {
+	if len(nal) < 4 {
+		return 0, 0, errors.New("sps is too short")
+	}
+
+	// remove emulation prevention bytes
+	rbsp := make([]byte, 0, len(nal))
+	for i := 1; i < len(nal); i++ {
+		if i >= 3 && nal[i] == 3 && nal[i-1] == 0 && nal[i-2] == 0 {
+			continue
+		}
+		rbsp = append(rbsp, nal[i])
+	}
+
+	br := &bitReader{data: rbsp}
+	profile := br.u(8)
+	br.u(16) // constraint flags and level
+	br.ue()  // seq_parameter_set_id
+
+	chroma := uint64(1)
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		chroma = br.ue()
+		if chroma == 3 {
+			br.u(1) // separate_colour_plane_flag
+		}
+		br.ue()           // bit_depth_luma_minus8
+		br.ue()           // bit_depth_chroma_minus8
+		br.u(1)           // qpprime_y_zero_transform_bypass_flag
+		if br.u(1) == 1 { // seq_scaling_matrix_present_flag
+			lists := 8
+			if chroma == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				if br.u(1) == 0 {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				last, next := int64(8), int64(8)
+				for j := 0; j < size; j++ {
+					if next != 0 {
+						next = (last + br.se() + 256) % 256
+					}
+					if next != 0 {
+						last = next
+					}
+				}
+			}
+		}
+	}
+
+	br.ue()          // log2_max_frame_num_minus4
+	switch br.ue() { // pic_order_cnt_type
+	case 0:
+		br.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		br.u(1) // delta_pic_order_always_zero_flag
+		br.se() // offset_for_non_ref_pic
+		br.se() // offset_for_top_to_bottom_field
+		for n := br.ue(); n > 0 && br.err == nil; n-- {
+			br.se()
+		}
+	}
+	br.ue() // max_num_ref_frames
+	br.u(1) // gaps_in_frame_num_value_allowed_flag
+	widthMbs := br.ue() + 1
+	heightMapUnits := br.ue() + 1
+	frameMbsOnly := br.u(1)
+	if frameMbsOnly == 0 {
+		br.u(1) // mb_adaptive_frame_field_flag
+	}
+	br.u(1) // direct_8x8_inference_flag
+
+	var cropLeft, cropRight, cropTop, cropBottom uint64
+	if br.u(1) == 1 {
+		cropLeft, cropRight, cropTop, cropBottom = br.ue(), br.ue(), br.ue(), br.ue()
+	}
+	if br.err != nil {
+		return 0, 0, errors.Wrap(br.err, "parse sps")
+	}
+
+	cropX, cropY := uint64(1), 2-frameMbsOnly
+	switch chroma {
+	case 1:
+		cropX, cropY = 2, 2*(2-frameMbsOnly)
+	case 2:
+		cropX, cropY = 2, 2-frameMbsOnly
+	}
+
+	width := widthMbs*16 - (cropLeft+cropRight)*cropX
+	height := (2-frameMbsOnly)*heightMapUnits*16 - (cropTop+cropBottom)*cropY
+
+	return int(width), int(height), nil
+}
+
+type bitReader struct {
+	data []byte
+	pos  int // bit position
+	err  error
+}
+
+func (b *bitReader) u(n int) uint64 {
+	v := uint64(0)
+	for i := 0; i < n; i++ {
+		if b.pos >= len(b.data)*8 {
+			b.err = io.ErrUnexpectedEOF
+			return 0
+		}
+		bit := b.data[b.pos/8] >> (7 - b.pos%8) & 1
+		v = v<<1 | uint64(bit)
+		b.pos++
+	}
+	return v
+}
+
+// ue reads unsigned Exp-Golomb code
+func (b *bitReader) ue() uint64 {
+	zeros := 0
+	for b.u(1) == 0 {
+		if b.err != nil || zeros > 31 {
+			b.err = errors.New("invalid exp-golomb code")
+			return 0
+		}
+		zeros++
+	}
+	return 1<<zeros - 1 + b.u(zeros)
+}
+
+// se reads signed Exp-Golomb code
+func (b *bitReader) se() int64 {
+	v := b.ue()
+	if v%2 == 1 {
+		return int64(v+1) / 2
+	}
+	return -int64(v / 2)
+}
@@ -0,0 +1,339 @@
+package mediautil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yapingcat/gomedia/go-mp4"
+)
+
+func mp4Box(typ string, children ...[]byte) []byte {
+	body := bytes.Join(children, nil)
+	b := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(b, uint32(8+len(body)))
+	copy(b[4:], typ)
+	return append(b, body...)
+}
+
+func u32(vs ...uint32) []byte {
+	b := make([]byte, 4*len(vs))
+	for i, v := range vs {
+		binary.BigEndian.PutUint32(b[i*4:], v)
+	}
+	return b
+}
+
+func testMP4(t *testing.T, rotation bool, cover []byte) string {
+	matrix := u32(0x10000, 0, 0, 0, 0x10000, 0, 0, 0, 0x40000000)
+	if rotation {
+		matrix = u32(0, 0x10000, 0, 0xFFFF0000, 0, 0, 0, 0, 0x40000000)
+	}
+
+	tkhd := mp4Box("tkhd", u32(0, 0, 0, 1, 0, 0, 0, 0, 0, 0), matrix, u32(1920<<16, 1080<<16))
+	mdhd := mp4Box("mdhd", u32(0, 0, 0, 1000, 10000), u32(0))
+	hdlr := mp4Box("hdlr", u32(0, 0), []byte("vide"), u32(0, 0, 0))
+	stsd := mp4Box("stsd", u32(0, 1), mp4Box("avc1", make([]byte, 78)))
+	stts := mp4Box("stts", u32(0, 1, 300, 33))
+	trak := mp4Box("trak", tkhd, mp4Box("mdia", mdhd, hdlr, mp4Box("minf", mp4Box("stbl", stsd, stts))))
+	mvhd := mp4Box("mvhd", u32(0, 0, 0, 1000, 10000), make([]byte, 80))
+
+	moov := [][]byte{mvhd, trak}
+	if cover != nil {
+		data := mp4Box("data", u32(13, 0), cover)
+		ilst := mp4Box("ilst", mp4Box("covr", data))
+		moov = append(moov, mp4Box("udta", mp4Box("meta", u32(0), ilst)))
+	}
+
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	file := bytes.Join([][]byte{mp4Box("ftyp", []byte("isom"), u32(0)), mp4Box("moov", moov...), mp4Box("mdat", make([]byte, 1024))}, nil)
+	require.NoError(t, os.WriteFile(path, file, 0o644))
+
+	return path
+}
+
+func TestProbeMP4(t *testing.T) {
+	info, err := ProbeVideoNative(testMP4(t, false, nil))
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, info.Duration)
+	assert.Equal(t, 1920, info.Width)
+	assert.Equal(t, 1080, info.Height)
+	assert.Equal(t, "h264", info.Codec)
+	assert.Equal(t, 30.0, info.FrameRate)
+
+	info, err = ProbeVideoNative(testMP4(t, true, nil))
+	require.NoError(t, err)
+	assert.Equal(t, 1080, info.Width)
+	assert.Equal(t, 1920, info.Height)
+}
+
+func ebml(id uint32, children ...[]byte) []byte {
+	body := bytes.Join(children, nil)
+
+	b := make([]byte, 0, 12+len(body))
+	for shift := 24; shift >= 0; shift -= 8 {
+		if v := byte(id >> shift); v != 0 || len(b) > 0 {
+			b = append(b, v)
+		}
+	}
+	// 8 bytes size
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(body)))
+	size[0] = 0x01
+	b = append(b, size...)
+
+	return append(b, body...)
+}
+
+func TestProbeMatroska(t *testing.T) {
+	duration := make([]byte, 8)
+	binary.BigEndian.PutUint64(duration, math.Float64bits(12500))
+
+	header := ebml(0x1A45DFA3, ebml(0x4282, []byte("webm")))
+	info := ebml(mkvInfo, ebml(mkvTimecodeScale, []byte{0x0F, 0x42, 0x40}), ebml(mkvDuration, duration))
+	audio := ebml(mkvTrackEntry, ebml(mkvTrackType, []byte{2}), ebml(mkvCodecID, []byte("A_OPUS")),
+		ebml(mkvLanguage, []byte("jpn")), ebml(mkvAudio, ebml(mkvChannels, []byte{2})))
+	video := ebml(mkvTrackEntry,
+		ebml(mkvTrackType, []byte{1}),
+		ebml(mkvCodecID, []byte("V_VP9")),
+		ebml(mkvDefaultDur, []byte{0x01, 0xFD, 0x22, 0x8B}), // 33366667ns, 29.97fps
+		ebml(mkvVideo, ebml(mkvPixelWidth, []byte{0x05, 0x00}), ebml(mkvPixelHeight, []byte{0x02, 0xD0})))
+	cluster := ebml(mkvCluster, make([]byte, 1024))
+
+	path := filepath.Join(t.TempDir(), "video.webm")
+	file := bytes.Join([][]byte{header, ebml(mkvSegment, cluster, ebml(mkvTracks, audio, video), info)}, nil)
+	require.NoError(t, os.WriteFile(path, file, 0o644))
+
+	got, err := ProbeVideoNative(path)
+	require.NoError(t, err)
+	assert.Equal(t, 12.5, got.Duration)
+	assert.Equal(t, 1280, got.Width)
+	assert.Equal(t, 720, got.Height)
+	assert.Equal(t, "vp9", got.Codec)
+	assert.Equal(t, 29.97, got.FrameRate)
+	assert.Equal(t, []StreamInfo{
+		{Index: 0, Type: StreamAudio, Codec: "opus", Language: "jpn", Channels: 2, Default: true},
+		{Index: 1, Type: StreamVideo, Codec: "vp9", Language: "eng", Default: true},
+	}, got.Streams)
+}
+
+type bitWriter struct {
+	data []byte
+	n    int
+}
+
+func (w *bitWriter) u(n int, v uint64) {
+	for i := n - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[len(w.data)-1] |= byte(v>>i&1) << (7 - w.n%8)
+		w.n++
+	}
+}
+
+func (w *bitWriter) ue(v uint64) {
+	v++
+	bits := 0
+	for x := v; x > 0; x >>= 1 {
+		bits++
+	}
+	w.u(bits-1, 0)
+	w.u(bits, v)
+}
+
+// testSPS returns SPS of 1920x1080 high profile
+func testSPS() []byte {
+	w := &bitWriter{}
+	w.u(8, 0x67) // nal header
+	w.u(8, 100)  // high profile
+	w.u(16, 0x0028)
+	w.ue(0) // sps id
+	w.ue(1) // chroma 4:2:0
+	w.ue(0)
+	w.ue(0)
+	w.u(1, 0)
+	w.u(1, 0) // no scaling matrix
+	w.ue(0)
+	w.ue(0) // poc type 0
+	w.ue(2)
+	w.ue(4)
+	w.u(1, 0)
+	w.ue(119) // 120 mbs
+	w.ue(67)  // 68 map units
+	w.u(1, 1) // frame mbs only
+	w.u(1, 1)
+	w.u(1, 1) // cropping
+	w.ue(0)
+	w.ue(0)
+	w.ue(0)
+	w.ue(4)   // crop 8 lines at bottom
+	w.u(1, 0) // vui
+	w.u(1, 1) // stop bit
+
+	return w.data
+}
+
+func TestParseH264SPS(t *testing.T) {
+	width, height, err := parseH264SPS(testSPS())
+	require.NoError(t, err)
+	assert.Equal(t, 1920, width)
+	assert.Equal(t, 1080, height)
+}
+
+func TestExtractCover(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 640, 480))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+	img.Set(0, 0, color.Black)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, png.Encode(buf, img))
+
+	out := filepath.Join(t.TempDir(), "thumb.jpg")
+	require.NoError(t, ExtractCover(testMP4(t, false, buf.Bytes()), out))
+
+	f, err := os.Open(out)
+	require.NoError(t, err)
+	defer f.Close()
+
+	cfg, format, err := image.DecodeConfig(f)
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, 320, cfg.Width)
+	assert.Equal(t, 240, cfg.Height)
+}
+
+func tsPkt(pid uint16, start bool, payload []byte) []byte {
+	p := make([]byte, tsPacketSize)
+	p[0] = tsSyncByte
+	p[1] = byte(pid >> 8 & 0x1F)
+	if start {
+		p[1] |= 0x40
+	}
+	p[2] = byte(pid)
+
+	// adaptation field stuffing to fill the packet
+	stuffing := tsPacketSize - 4 - len(payload)
+	if stuffing == 0 {
+		p[3] = 0x10
+		copy(p[4:], payload)
+		return p
+	}
+	p[3] = 0x30
+	p[4] = byte(stuffing - 1)
+	if stuffing > 1 {
+		p[5] = 0
+		for i := 6; i < 4+stuffing; i++ {
+			p[i] = 0xFF
+		}
+	}
+	copy(p[4+stuffing:], payload)
+	return p
+}
+
+func pesWithPTS(pts int64, es []byte) []byte {
+	b := []byte{0, 0, 1, 0xE0, 0, 0, 0x80, 0x80, 5,
+		byte(0x21 | pts>>29&0x0E), byte(pts >> 22), byte(pts>>14 | 1), byte(pts >> 7), byte(pts<<1 | 1)}
+	return append(b, es...)
+}
+
+func TestProbeTS(t *testing.T) {
+	pat := []byte{0, 0x00, 0xB0, 13, 0, 1, 0xC1, 0, 0, 0, 1, 0xF0, 0x00, 0, 0, 0, 0}
+	pmt := []byte{0, 0x02, 0xB0, 18, 0, 1, 0xC1, 0, 0, 0xE1, 0x00, 0xF0, 0x00, 0x1B, 0xE1, 0x00, 0xF0, 0x00, 0, 0, 0, 0}
+
+	w := &bitWriter{}
+	w.u(8, 0x67)
+	w.u(8, 66) // baseline
+	w.u(16, 0x001F)
+	w.ue(0) // sps id
+	w.ue(0)
+	w.ue(0) // poc type 0
+	w.ue(0)
+	w.ue(1)
+	w.u(1, 0)
+	w.ue(79) // 1280
+	w.ue(44) // 720
+	w.u(1, 1)
+	w.u(1, 1)
+	w.u(1, 0)
+	w.u(1, 0)
+	w.u(1, 1)
+	es := append([]byte{0, 0, 0, 1}, w.data...)
+
+	file := bytes.Join([][]byte{
+		tsPkt(0, true, pat),
+		tsPkt(0x1000, true, pmt),
+		tsPkt(0x100, true, pesWithPTS(90000, es)),
+		tsPkt(0x100, true, pesWithPTS(90000+90000*5/2, []byte{0, 0, 1, 0x41})),
+	}, nil)
+
+	path := filepath.Join(t.TempDir(), "video.ts")
+	require.NoError(t, os.WriteFile(path, file, 0o644))
+
+	info, err := ProbeVideoNative(path)
+	require.NoError(t, err)
+	assert.Equal(t, "h264", info.Codec)
+	assert.Equal(t, 1280, info.Width)
+	assert.Equal(t, 720, info.Height)
+	assert.Equal(t, 2.5, info.Duration)
+}
+
+func TestFindBoxBounds(t *testing.T) {
+	// child claims 4GB inside 24 bytes parent
+	child := u32(0xFFFFFFF0)
+	child = append(child, []byte("udta")...)
+	parent := mp4Box("moov", child, make([]byte, 8))
+
+	_, _, err := findBox(bytes.NewReader(parent), 8, int64(len(parent)), "udta")
+	assert.Error(t, err)
+
+	// negative 64-bit size
+	large := append(u32(1), []byte("udta")...)
+	large = append(large, u32(0x80000000, 0)...)
+	parent = mp4Box("moov", large)
+
+	_, _, err = findBox(bytes.NewReader(parent), 8, int64(len(parent)), "udta")
+	assert.Error(t, err)
+
+	_, err = readBox(bytes.NewReader(nil), box{typ: "mdat", size: mp4MaxBoxSize + 16, headerSize: 8})
+	assert.Error(t, err)
+}
+
+func TestProbeMP4Demuxer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.mp4")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+
+	muxer, err := mp4.CreateMp4Muxer(f)
+	require.NoError(t, err)
+	track := muxer.AddVideoTrack(mp4.MP4_CODEC_H264)
+
+	start := []byte{0, 0, 0, 1}
+	for i := uint64(0); i < 50; i++ {
+		frame := bytes.Join([][]byte{nil, testSPS(), {0x68, 0xce, 0x38, 0x80}, {0x65, 0x88, 0x84, 0x00}}, start)
+		require.NoError(t, muxer.Write(track, frame, i*40, i*40))
+	}
+	require.NoError(t, muxer.WriteTrailer())
+	require.NoError(t, f.Close())
+
+	r, err := os.Open(path)
+	require.NoError(t, err)
+	defer r.Close()
+
+	info := &VideoInfo{}
+	require.NoError(t, probeMP4Demuxer(r, info))
+	assert.Equal(t, 1920, info.Width)
+	assert.Equal(t, 1080, info.Height)
+	assert.Equal(t, "h264", info.Codec)
+	assert.Positive(t, info.Duration) // whole seconds
+}
@@ -0,0 +1,252 @@
+package mediautil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-faster/errors"
+)
+
+// Keyframe 关键帧的时间和在文件中的字节位置
+type Keyframe struct {
+	Time float64 `json:"time"`
+	Pos  int64   `json:"pos"`
+}
+
+// segment 分段，end 为负数表示到文件末尾
+type segment struct {
+	start, end       float64
+	startPos, endPos int64
+}
+
+const (
+	// 容器开销和音频交错导致实际大小与字节位置估计有偏差，预留余量
+	splitSizeMargin = 0.97
+	// 无法获取关键帧时，分段的最小时长
+	minSegmentDuration = 1.0
+)
+
+// Keyframes 使用 ffprobe 获取主视频流的关键帧
+func (p *VideoProcessor) Keyframes(ctx context.Context, path string) ([]Keyframe, error) {
+	if p.ffprobePath == "" {
+		return nil, errors.New("ffprobe not found")
+	}
+
+	args := []string{
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "packet=pts_time,pos,flags",
+		"-of", "csv=p=0",
+		path,
+	}
+
+	cmd := exec.CommandContext(ctx, p.ffprobePath, args...)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrap(err, "run ffprobe")
+	}
+
+	return parseKeyframes(output), nil
+}
+
+// parseKeyframes 解析 "pts_time,pos,flags" 格式的输出，flags 包含 K 表示关键帧
+func parseKeyframes(output []byte) []Keyframe {
+	keyframes := make([]Keyframe, 0)
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), ",")
+		if len(fields) < 3 || !strings.Contains(fields[2], "K") {
+			continue
+		}
+
+		t, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		pos, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		keyframes = append(keyframes, Keyframe{Time: t, Pos: pos})
+	}
+
+	sort.Slice(keyframes, func(i, j int) bool {
+		return keyframes[i].Time < keyframes[j].Time
+	})
+	return keyframes
+}
+
+// planSegments 按字节预算在关键帧处切分，每段估计大小不超过 budget
+func planSegments(keyframes []Keyframe, size int64, budget int64) []segment {
+	segments := make([]segment, 0)
+	cur := segment{start: 0, startPos: 0}
+
+	for i := 0; i < len(keyframes); i++ {
+		kf := keyframes[i]
+		if kf.Pos-cur.startPos <= budget || kf.Time <= cur.start {
+			continue
+		}
+
+		// cut at the previous keyframe which is still in budget, at least one keyframe interval
+		cut := keyframes[max(i-1, 0)]
+		if cut.Time <= cur.start {
+			cut = kf
+		}
+		cur.end, cur.endPos = cut.Time, cut.Pos
+		segments = append(segments, cur)
+		cur = segment{start: cut.Time, startPos: cut.Pos}
+	}
+
+	cur.end, cur.endPos = -1, size
+	return append(segments, cur)
+}
+
+// evenSegments 无关键帧信息时按时长平均切分
+func evenSegments(duration float64, size, budget int64) []segment {
+	parts := int(math.Ceil(float64(size) / float64(budget)))
+	segments := make([]segment, 0, parts)
+	for i := 0; i < parts; i++ {
+		s := segment{
+			start:    duration * float64(i) / float64(parts),
+			end:      duration * float64(i+1) / float64(parts),
+			startPos: size * int64(i) / int64(parts),
+			endPos:   size * int64(i+1) / int64(parts),
+		}
+		if i == parts-1 {
+			s.end = -1
+		}
+		segments = append(segments, s)
+	}
+	return segments
+}
+
+// halve 在中间位置的关键帧处将分段一分为二，无法切分时返回 false
+func halve(s segment, keyframes []Keyframe, duration float64) (segment, segment, bool) {
+	end := s.end
+	if end < 0 {
+		end = duration
+	}
+
+	mid := segment{}
+	if len(keyframes) > 0 {
+		target := (s.startPos + s.endPos) / 2
+		best := -1
+		for i, kf := range keyframes {
+			if kf.Time <= s.start || kf.Time >= end {
+				continue
+			}
+			if best < 0 || abs(kf.Pos-target) < abs(keyframes[best].Pos-target) {
+				best = i
+			}
+		}
+		if best < 0 {
+			return segment{}, segment{}, false
+		}
+		mid.start, mid.startPos = keyframes[best].Time, keyframes[best].Pos
+	} else {
+		if end-s.start < minSegmentDuration*2 {
+			return segment{}, segment{}, false
+		}
+		mid.start, mid.startPos = (s.start+end)/2, (s.startPos+s.endPos)/2
+	}
+
+	first := segment{start: s.start, end: mid.start, startPos: s.startPos, endPos: mid.startPos}
+	second := segment{start: mid.start, end: s.end, startPos: mid.startPos, endPos: s.endPos}
+	return first, second, true
+}
+
+func abs(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// splitSegments 按关键帧规划分段，无法获取关键帧时按时长平均分段
+func (p *VideoProcessor) splitSegments(ctx context.Context, inputPath string, info *VideoInfo, maxSize int64) ([]segment, []Keyframe) {
+	budget := int64(float64(maxSize) * splitSizeMargin)
+
+	keyframes, err := p.Keyframes(ctx, inputPath)
+	if err != nil || len(keyframes) == 0 {
+		return evenSegments(info.Duration, info.Size, budget), nil
+	}
+	return planSegments(keyframes, info.Size, budget), keyframes
+}
+
+// EstimateParts 返回 SplitVideoBySize 预计的分段数，分段过大需要重新切分时实际数量会更多
+func (p *VideoProcessor) EstimateParts(ctx context.Context, inputPath string, info *VideoInfo, maxSize int64) int {
+	segments, _ := p.splitSegments(ctx, inputPath, info, maxSize)
+	return len(segments)
+}
+
+// SplitVideoBySize 在关键帧处切分视频，使每个分段不超过 maxSize，分段过大时会重新切分。
+// 分段写入 outputDir，命名为 name_part01.ext 格式，序号位数由总数决定。
+func (p *VideoProcessor) SplitVideoBySize(ctx context.Context, inputPath, outputDir string, info *VideoInfo, maxSize int64) (_ []string, rerr error) {
+	segments, keyframes := p.splitSegments(ctx, inputPath, info, maxSize)
+
+	ext := filepath.Ext(inputPath)
+	base := filepath.Join(outputDir, strings.TrimSuffix(filepath.Base(inputPath), ext))
+	tmp := make([]string, 0, len(segments))
+	defer func() {
+		if rerr != nil {
+			for _, t := range tmp {
+				_ = os.Remove(t)
+			}
+		}
+	}()
+
+	for i := 0; i < len(segments); {
+		s := segments[i]
+		out := fmt.Sprintf("%s.splitting%d%s", base, len(tmp), ext)
+
+		opts := SplitOptions{StartTime: s.start, OutputPath: out}
+		if s.end >= 0 {
+			opts.Duration = s.end - s.start
+		}
+		if err := p.SplitVideo(ctx, inputPath, opts); err != nil {
+			return nil, err
+		}
+
+		stat, err := os.Stat(out)
+		if err != nil {
+			return nil, errors.Wrap(err, "stat part")
+		}
+		if stat.Size() <= maxSize {
+			tmp = append(tmp, out)
+			i++
+			continue
+		}
+
+		// part is still too large, split it again
+		_ = os.Remove(out)
+		first, second, ok := halve(s, keyframes, info.Duration)
+		if !ok {
+			return nil, errors.Errorf("part from %.3fs is %d bytes and can't be split at keyframes", s.start, stat.Size())
+		}
+		segments = append(segments[:i], append([]segment{first, second}, segments[i+1:]...)...)
+	}
+
+	// rename parts when total is known
+	digits := len(strconv.Itoa(len(tmp)))
+	parts := make([]string, 0, len(tmp))
+	for i, t := range tmp {
+		part := fmt.Sprintf("%s_part%0*d%s", base, digits, i+1, ext)
+		if err := os.Rename(t, part); err != nil {
+			return nil, errors.Wrap(err, "rename part")
+		}
+		tmp[i] = part
+		parts = append(parts, part)
+	}
+
+	return parts, nil
+}
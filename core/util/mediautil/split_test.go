@@ -0,0 +1,75 @@
+package mediautil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeyframes(t *testing.T) {
+	output := []byte("2.000000,2048,K__\n" +
+		"0.040000,1200,___\n" +
+		"0.000000,48,K__\n" +
+		"N/A,N/A,K__\n" +
+		"4.000000,4096,K_D\n")
+
+	assert.Equal(t, []Keyframe{
+		{Time: 0, Pos: 48},
+		{Time: 2, Pos: 2048},
+		{Time: 4, Pos: 4096},
+	}, parseKeyframes(output))
+}
+
+func TestPlanSegments(t *testing.T) {
+	keyframes := make([]Keyframe, 0)
+	for i := 0; i < 10; i++ {
+		keyframes = append(keyframes, Keyframe{Time: float64(i), Pos: int64(i) * 100})
+	}
+
+	assert.Equal(t, []segment{
+		{start: 0, end: 2, startPos: 0, endPos: 200},
+		{start: 2, end: 4, startPos: 200, endPos: 400},
+		{start: 4, end: 6, startPos: 400, endPos: 600},
+		{start: 6, end: 8, startPos: 600, endPos: 800},
+		{start: 8, end: -1, startPos: 800, endPos: 1000},
+	}, planSegments(keyframes, 1000, 250))
+
+	// a single GOP larger than budget can't be split further
+	assert.Equal(t, []segment{
+		{start: 0, end: 5, startPos: 0, endPos: 500},
+		{start: 5, end: -1, startPos: 500, endPos: 700},
+	}, planSegments([]Keyframe{{0, 0}, {5, 500}, {6, 600}}, 700, 250))
+
+	assert.Equal(t, []segment{
+		{start: 0, end: -1, startPos: 0, endPos: 100},
+	}, planSegments(nil, 100, 250))
+}
+
+func TestEvenSegments(t *testing.T) {
+	assert.Equal(t, []segment{
+		{start: 0, end: 5, startPos: 0, endPos: 500},
+		{start: 5, end: -1, startPos: 500, endPos: 1000},
+	}, evenSegments(10, 1000, 600))
+}
+
+func TestHalve(t *testing.T) {
+	keyframes := []Keyframe{{0, 0}, {1, 100}, {2, 250}, {3, 350}, {4, 400}}
+
+	first, second, ok := halve(segment{start: 0, end: 4, startPos: 0, endPos: 400}, keyframes, 10)
+	require.True(t, ok)
+	assert.Equal(t, segment{start: 0, end: 2, startPos: 0, endPos: 250}, first)
+	assert.Equal(t, segment{start: 2, end: 4, startPos: 250, endPos: 400}, second)
+
+	_, _, ok = halve(segment{start: 0, end: 1, startPos: 0, endPos: 100}, keyframes, 10)
+	assert.False(t, ok)
+
+	// without keyframes, split by time
+	first, second, ok = halve(segment{start: 4, end: -1, startPos: 400, endPos: 1000}, nil, 10)
+	require.True(t, ok)
+	assert.Equal(t, segment{start: 4, end: 7, startPos: 400, endPos: 700}, first)
+	assert.Equal(t, segment{start: 7, end: -1, startPos: 700, endPos: 1000}, second)
+
+	_, _, ok = halve(segment{start: 9.5, end: -1, startPos: 950, endPos: 1000}, nil, 10)
+	assert.False(t, ok)
+}
@@ -0,0 +1,156 @@
+package mediautil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-faster/errors"
+)
+
+//go:generate go-enum --values --names --flag --nocase
+
+// TranscodeProfile 转码配置
+// None 不转换；Remux 尽量只重新封装为 faststart MP4，编码不兼容时才转码；H264 将视频转码为 H.264/AAC
+// ENUM(none, remux, h264)
+type TranscodeProfile int
+
+// TranscodeOptions 转码选项
+type TranscodeOptions struct {
+	Profile    TranscodeProfile `json:"profile"`
+	MaxHeight  int              `json:"maxHeight"`  // 短边最大像素，0 表示不限制
+	MaxBitrate int64            `json:"maxBitrate"` // 最大视频比特率(kb/s)，0 表示不限制
+	OutputPath string           `json:"outputPath"` // 输出路径
+}
+
+// TranscodePlan 转码计划
+type TranscodePlan struct {
+	CopyVideo bool     `json:"copyVideo"` // 直接复制视频流
+	CopyAudio bool     `json:"copyAudio"` // 直接复制音频流
+	Reasons   []string `json:"reasons"`   // 需要处理的原因
+}
+
+// Telegram 客户端能在 MP4 中流式播放的编码
+var (
+	mp4VideoCodecs = map[string]struct{}{"h264": {}, "hevc": {}}
+	mp4AudioCodecs = map[string]struct{}{"aac": {}, "mp3": {}}
+)
+
+// PlanTranscode 根据视频信息和选项判断是否需要处理，不需要时返回 false
+func PlanTranscode(info *VideoInfo, opts TranscodeOptions) (TranscodePlan, bool) {
+	plan := TranscodePlan{CopyVideo: true, CopyAudio: true}
+	if opts.Profile == TranscodeProfileNone || info == nil {
+		return plan, false
+	}
+
+	transcode := func(reason string) {
+		plan.CopyVideo = false
+		plan.Reasons = append(plan.Reasons, reason)
+	}
+
+	if info.Container != "mp4" {
+		plan.Reasons = append(plan.Reasons, fmt.Sprintf("container %s is not mp4", info.Container))
+	}
+
+	switch opts.Profile {
+	case TranscodeProfileRemux:
+		if _, ok := mp4VideoCodecs[info.Codec]; !ok {
+			transcode(fmt.Sprintf("video codec %s is not streamable", info.Codec))
+		}
+	case TranscodeProfileH264:
+		if info.Codec != "h264" {
+			transcode(fmt.Sprintf("video codec %s is not h264", info.Codec))
+		}
+	}
+
+	// most clients ignore rotation metadata, rotate frames instead
+	if info.Rotation != 0 {
+		transcode(fmt.Sprintf("video is rotated %d degrees", info.Rotation))
+	}
+	if short := min(info.Width, info.Height); opts.MaxHeight > 0 && short > opts.MaxHeight {
+		transcode(fmt.Sprintf("resolution %dx%d exceeds %dp", info.Width, info.Height, opts.MaxHeight))
+	}
+	if opts.MaxBitrate > 0 && info.Bitrate > opts.MaxBitrate {
+		transcode(fmt.Sprintf("bitrate %dkb/s exceeds %dkb/s", info.Bitrate, opts.MaxBitrate))
+	}
+
+	for _, s := range info.StreamsOf(StreamAudio) {
+		if _, ok := mp4AudioCodecs[s.Codec]; !ok {
+			plan.CopyAudio = false
+			plan.Reasons = append(plan.Reasons, fmt.Sprintf("audio codec %s is not streamable", s.Codec))
+			break
+		}
+	}
+
+	return plan, len(plan.Reasons) > 0
+}
+
+// Transcode 按计划将视频转换为 faststart MP4
+func (p *VideoProcessor) Transcode(ctx context.Context, inputPath string, plan TranscodePlan, opts TranscodeOptions) error {
+	if err := os.MkdirAll(filepath.Dir(opts.OutputPath), 0755); err != nil {
+		return errors.Wrap(err, "create output directory")
+	}
+
+	cmd := exec.CommandContext(ctx, p.ffmpegPath, transcodeArgs(inputPath, plan, opts)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		_ = os.Remove(opts.OutputPath)
+		return errors.Wrapf(err, "transcode video: %s", lastLine(string(output)))
+	}
+
+	return nil
+}
+
+func transcodeArgs(inputPath string, plan TranscodePlan, opts TranscodeOptions) []string {
+	args := []string{
+		"-hide_banner",
+		"-i", inputPath,
+		// main video and all audio, subtitles and data streams are dropped
+		"-map", "0:v:0",
+		"-map", "0:a?",
+		"-sn", "-dn",
+	}
+
+	if plan.CopyVideo {
+		args = append(args, "-c:v", "copy")
+	} else {
+		args = append(args,
+			"-c:v", "libx264",
+			"-preset", "veryfast",
+			"-crf", "23",
+			"-pix_fmt", "yuv420p", // 8 bit 4:2:0 is required by most clients
+		)
+
+		// frames are rotated by autorotate before filters, so cap the short side of display size
+		if opts.MaxHeight > 0 {
+			h := opts.MaxHeight
+			args = append(args, "-vf", fmt.Sprintf("scale='if(gt(iw,ih),-2,min(iw,%d))':'if(gt(iw,ih),min(ih,%d),-2)'", h, h))
+		}
+		if opts.MaxBitrate > 0 {
+			args = append(args,
+				"-maxrate", fmt.Sprintf("%dk", opts.MaxBitrate),
+				"-bufsize", fmt.Sprintf("%dk", opts.MaxBitrate*2),
+			)
+		}
+	}
+
+	if plan.CopyAudio {
+		args = append(args, "-c:a", "copy")
+	} else {
+		args = append(args, "-c:a", "aac", "-b:a", "192k")
+	}
+
+	return append(args,
+		"-movflags", "+faststart",
+		"-f", "mp4",
+		"-y",
+		opts.OutputPath,
+	)
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return lines[len(lines)-1]
+}
@@ -0,0 +1,106 @@
+// Code generated by go-enum DO NOT EDIT.
+// Version: 0.5.8
+// Revision: 3d844c8ecc59661ed7aa17bfd65727bc06a60ad8
+// Build Date: 2023-09-18T14:55:21Z
+// Built By: goreleaser
+
+package mediautil
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// TranscodeProfileNone is a TranscodeProfile of type None.
+	TranscodeProfileNone TranscodeProfile = iota
+	// TranscodeProfileRemux is a TranscodeProfile of type Remux.
+	TranscodeProfileRemux
+	// TranscodeProfileH264 is a TranscodeProfile of type H264.
+	TranscodeProfileH264
+)
+
+var ErrInvalidTranscodeProfile = fmt.Errorf("not a valid TranscodeProfile, try [%s]", strings.Join(_TranscodeProfileNames, ", "))
+
+const _TranscodeProfileName = "noneremuxh264"
+
+var _TranscodeProfileNames = []string{
+	_TranscodeProfileName[0:4],
+	_TranscodeProfileName[4:9],
+	_TranscodeProfileName[9:13],
+}
+
+// TranscodeProfileNames returns a list of possible string values of TranscodeProfile.
+func TranscodeProfileNames() []string {
+	tmp := make([]string, len(_TranscodeProfileNames))
+	copy(tmp, _TranscodeProfileNames)
+	return tmp
+}
+
+// TranscodeProfileValues returns a list of the values for TranscodeProfile
+func TranscodeProfileValues() []TranscodeProfile {
+	return []TranscodeProfile{
+		TranscodeProfileNone,
+		TranscodeProfileRemux,
+		TranscodeProfileH264,
+	}
+}
+
+var _TranscodeProfileMap = map[TranscodeProfile]string{
+	TranscodeProfileNone:  _TranscodeProfileName[0:4],
+	TranscodeProfileRemux: _TranscodeProfileName[4:9],
+	TranscodeProfileH264:  _TranscodeProfileName[9:13],
+}
+
+// String implements the Stringer interface.
+func (x TranscodeProfile) String() string {
+	if str, ok := _TranscodeProfileMap[x]; ok {
+		return str
+	}
+	return fmt.Sprintf("TranscodeProfile(%d)", x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x TranscodeProfile) IsValid() bool {
+	_, ok := _TranscodeProfileMap[x]
+	return ok
+}
+
+var _TranscodeProfileValue = map[string]TranscodeProfile{
+	_TranscodeProfileName[0:4]:                   TranscodeProfileNone,
+	strings.ToLower(_TranscodeProfileName[0:4]):  TranscodeProfileNone,
+	_TranscodeProfileName[4:9]:                   TranscodeProfileRemux,
+	strings.ToLower(_TranscodeProfileName[4:9]):  TranscodeProfileRemux,
+	_TranscodeProfileName[9:13]:                  TranscodeProfileH264,
+	strings.ToLower(_TranscodeProfileName[9:13]): TranscodeProfileH264,
+}
+
+// ParseTranscodeProfile attempts to convert a string to a TranscodeProfile.
+func ParseTranscodeProfile(name string) (TranscodeProfile, error) {
+	if x, ok := _TranscodeProfileValue[name]; ok {
+		return x, nil
+	}
+	// Case insensitive parse, do a separate lookup to prevent unnecessary cost of lowercasing a string if we don't need to.
+	if x, ok := _TranscodeProfileValue[strings.ToLower(name)]; ok {
+		return x, nil
+	}
+	return TranscodeProfile(0), fmt.Errorf("%s is %w", name, ErrInvalidTranscodeProfile)
+}
+
+// Set implements the Golang flag.Value interface func.
+func (x *TranscodeProfile) Set(val string) error {
+	v, err := ParseTranscodeProfile(val)
+	*x = v
+	return err
+}
+
+// Get implements the Golang flag.Getter interface func.
+func (x *TranscodeProfile) Get() interface{} {
+	return *x
+}
+
+// Type implements the github.com/spf13/pFlag Value interface.
+func (x *TranscodeProfile) Type() string {
+	return "TranscodeProfile"
+}
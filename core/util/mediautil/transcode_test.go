@@ -0,0 +1,88 @@
+package mediautil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanTranscode(t *testing.T) {
+	aac := []StreamInfo{{Type: StreamAudio, Codec: "aac"}}
+	opus := []StreamInfo{{Type: StreamAudio, Codec: "opus"}}
+
+	tests := []struct {
+		name      string
+		info      *VideoInfo
+		opts      TranscodeOptions
+		need      bool
+		copyVideo bool
+		copyAudio bool
+	}{
+		{
+			name: "none",
+			info: &VideoInfo{Container: "matroska", Codec: "vp9"},
+			opts: TranscodeOptions{Profile: TranscodeProfileNone},
+			need: false, copyVideo: true, copyAudio: true,
+		},
+		{
+			name: "streamable mp4",
+			info: &VideoInfo{Container: "mp4", Codec: "h264", Width: 1920, Height: 1080, Streams: aac},
+			opts: TranscodeOptions{Profile: TranscodeProfileRemux},
+			need: false, copyVideo: true, copyAudio: true,
+		},
+		{
+			name: "remux mkv",
+			info: &VideoInfo{Container: "matroska", Codec: "hevc", Streams: aac},
+			opts: TranscodeOptions{Profile: TranscodeProfileRemux},
+			need: true, copyVideo: true, copyAudio: true,
+		},
+		{
+			name: "remux webm",
+			info: &VideoInfo{Container: "matroska", Codec: "vp9", Streams: opus},
+			opts: TranscodeOptions{Profile: TranscodeProfileRemux},
+			need: true, copyVideo: false, copyAudio: false,
+		},
+		{
+			name: "h264 from hevc mp4",
+			info: &VideoInfo{Container: "mp4", Codec: "hevc", Streams: aac},
+			opts: TranscodeOptions{Profile: TranscodeProfileH264},
+			need: true, copyVideo: false, copyAudio: true,
+		},
+		{
+			name: "rotation",
+			info: &VideoInfo{Container: "mp4", Codec: "h264", Rotation: 90, Streams: aac},
+			opts: TranscodeOptions{Profile: TranscodeProfileRemux},
+			need: true, copyVideo: false, copyAudio: true,
+		},
+		{
+			name: "portrait within cap",
+			info: &VideoInfo{Container: "mp4", Codec: "h264", Width: 720, Height: 1280, Streams: aac},
+			opts: TranscodeOptions{Profile: TranscodeProfileRemux, MaxHeight: 720},
+			need: false, copyVideo: true, copyAudio: true,
+		},
+		{
+			name: "bitrate cap",
+			info: &VideoInfo{Container: "mp4", Codec: "h264", Bitrate: 8000, Streams: aac},
+			opts: TranscodeOptions{Profile: TranscodeProfileRemux, MaxBitrate: 4000},
+			need: true, copyVideo: false, copyAudio: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, need := PlanTranscode(tt.info, tt.opts)
+			assert.Equal(t, tt.need, need, plan.Reasons)
+			assert.Equal(t, tt.copyVideo, plan.CopyVideo)
+			assert.Equal(t, tt.copyAudio, plan.CopyAudio)
+		})
+	}
+}
+
+func TestTranscodeArgs(t *testing.T) {
+	args := transcodeArgs("in.mkv", TranscodePlan{CopyVideo: true, CopyAudio: false}, TranscodeOptions{OutputPath: "out.mp4"})
+	assert.Subset(t, args, []string{"-c:v", "copy", "-c:a", "aac", "+faststart", "out.mp4"})
+	assert.NotContains(t, args, "-vf")
+
+	args = transcodeArgs("in.mkv", TranscodePlan{}, TranscodeOptions{OutputPath: "out.mp4", MaxHeight: 720, MaxBitrate: 3000})
+	assert.Subset(t, args, []string{"libx264", "-vf", "3000k", "6000k"})
+}
@@ -2,7 +2,9 @@ package mediautil
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -16,15 +18,47 @@ import (
 
 // VideoInfo 存储视频文件的基本信息
 type VideoInfo struct {
-	FilePath  string  `json:"filePath"`  // 文件路径
-	Duration  float64 `json:"duration"`  // 视频时长（秒）
-	Width     int     `json:"width"`     // 视频宽度
-	Height    int     `json:"height"`    // 视频高度
-	Bitrate   int64   `json:"bitrate"`   // 比特率
-	Codec     string  `json:"codec"`     // 视频编码
-	FrameRate float64 `json:"frameRate"` // 帧率
-	Size      int64   `json:"size"`      // 文件大小（字节）
-	Thumbnail string  `json:"thumbnail"` // 缩略图
+	FilePath  string       `json:"filePath"`  // 文件路径
+	Container string       `json:"container"` // 容器格式 mp4/matroska/mpegts 等
+	Duration  float64      `json:"duration"`  // 视频时长（秒）
+	Width     int          `json:"width"`     // 视频宽度（已按旋转角度转换为显示宽度）
+	Height    int          `json:"height"`    // 视频高度（已按旋转角度转换为显示高度）
+	Bitrate   int64        `json:"bitrate"`   // 比特率
+	Codec     string       `json:"codec"`     // 视频编码
+	FrameRate float64      `json:"frameRate"` // 帧率
+	Size      int64        `json:"size"`      // 文件大小（字节）
+	Thumbnail string       `json:"thumbnail"` // 缩略图
+	Rotation  int          `json:"rotation"`  // 旋转角度，顺时针 0/90/180/270
+	HDR       bool         `json:"hdr"`       // 是否为 HDR(PQ/HLG)
+	Streams   []StreamInfo `json:"streams"`   // 所有流
+}
+
+// StreamInfo 存储单个流的信息
+type StreamInfo struct {
+	Index    int    `json:"index"`    // 流序号
+	Type     string `json:"type"`     // video/audio/subtitle
+	Codec    string `json:"codec"`    // 编码
+	Language string `json:"language"` // 语言，ISO 639-2
+	Title    string `json:"title"`    // 标题
+	Channels int    `json:"channels"` // 音频声道数
+	Default  bool   `json:"default"`  // 是否为默认流
+}
+
+const (
+	StreamVideo    = "video"
+	StreamAudio    = "audio"
+	StreamSubtitle = "subtitle"
+)
+
+// StreamsOf 返回指定类型的流
+func (v *VideoInfo) StreamsOf(typ string) []StreamInfo {
+	streams := make([]StreamInfo, 0)
+	for _, s := range v.Streams {
+		if s.Type == typ {
+			streams = append(streams, s)
+		}
+	}
+	return streams
 }
 
 // SplitOptions 视频分割选项
@@ -36,7 +70,8 @@ type SplitOptions struct {
 
 // VideoProcessor 视频处理器
 type VideoProcessor struct {
-	ffmpegPath string
+	ffmpegPath  string
+	ffprobePath string // empty if ffprobe is not available
 }
 
 var (
@@ -68,74 +103,257 @@ func newVideoProcessor(ffmpegPath string) (*VideoProcessor, error) {
 		return nil, errors.Wrap(err, "check ffmpeg")
 	}
 
+	// ffprobe 通常与 ffmpeg 在同一目录
+	ffprobePath := filepath.Join(filepath.Dir(ffmpegPath), strings.Replace(filepath.Base(ffmpegPath), "ffmpeg", "ffprobe", 1))
+	if !strings.ContainsRune(ffmpegPath, filepath.Separator) {
+		ffprobePath = strings.Replace(ffmpegPath, "ffmpeg", "ffprobe", 1)
+	}
+	if err := exec.Command(ffprobePath, "-version").Run(); err != nil {
+		ffprobePath = ""
+	}
+
 	return &VideoProcessor{
-		ffmpegPath: ffmpegPath,
+		ffmpegPath:  ffmpegPath,
+		ffprobePath: ffprobePath,
 	}, nil
 }
 
-// GetVideoInfo 获取视频信息
+// GetVideoInfo 获取视频信息，优先使用 ffprobe JSON 输出，ffprobe 不可用时解析 ffmpeg 输出
 func (p *VideoProcessor) GetVideoInfo(ctx context.Context, filepath string) (*VideoInfo, error) {
+	if p.ffprobePath != "" {
+		return p.probe(ctx, filepath)
+	}
+
+	return p.legacyVideoInfo(ctx, filepath)
+}
+
+// ffprobe JSON 输出，参考 https://ffmpeg.org/ffprobe.html
+type ffprobeOutput struct {
+	Streams []struct {
+		Index         int    `json:"index"`
+		CodecType     string `json:"codec_type"`
+		CodecName     string `json:"codec_name"`
+		Width         int    `json:"width"`
+		Height        int    `json:"height"`
+		AvgFrameRate  string `json:"avg_frame_rate"`
+		RFrameRate    string `json:"r_frame_rate"`
+		Channels      int    `json:"channels"`
+		ColorTransfer string `json:"color_transfer"`
+		Disposition   struct {
+			Default         int `json:"default"`
+			AttachedPic     int `json:"attached_pic"`
+			TimedThumbnails int `json:"timed_thumbnails"`
+		} `json:"disposition"`
+		Tags struct {
+			Language string `json:"language"`
+			Title    string `json:"title"`
+			Rotate   string `json:"rotate"`
+		} `json:"tags"`
+		SideDataList []struct {
+			Rotation float64 `json:"rotation"`
+		} `json:"side_data_list"`
+	} `json:"streams"`
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		BitRate    string `json:"bit_rate"`
+		Size       string `json:"size"`
+	} `json:"format"`
+}
+
+func (p *VideoProcessor) probe(ctx context.Context, path string) (*VideoInfo, error) {
 	args := []string{
-		"-hide_banner",
-		"-i", filepath,
+		"-v", "error",
+		"-print_format", "json",
+		"-show_streams",
+		"-show_format",
+		path,
 	}
 
-	cmd := exec.CommandContext(ctx, p.ffmpegPath, args...)
-	output, err := cmd.CombinedOutput()
+	cmd := exec.CommandContext(ctx, p.ffprobePath, args...)
+	output, err := cmd.Output()
 	if err != nil {
+		return nil, errors.Wrap(err, "run ffprobe")
+	}
 
-		info := &VideoInfo{
-			FilePath: filepath,
-		}
+	return parseFFprobe(path, output)
+}
 
-		// 获取文件大小
-		if stat, err := os.Stat(filepath); err == nil {
-			info.Size = stat.Size()
-		} else {
-			info.Size = -1
-		}
+func parseFFprobe(path string, output []byte) (*VideoInfo, error) {
+	var out ffprobeOutput
+	if err := json.Unmarshal(output, &out); err != nil {
+		return nil, errors.Wrap(err, "decode ffprobe output")
+	}
 
-		// FFmpeg 在获取信息时会返回错误码1，但仍会输出信息到stderr
-		outputStr := string(output)
+	info := &VideoInfo{
+		FilePath:  path,
+		Container: normalizeContainer(out.Format.FormatName),
+		Streams:   make([]StreamInfo, 0, len(out.Streams)),
+	}
+	info.Duration, _ = strconv.ParseFloat(out.Format.Duration, 64)
+	if bitrate, err := strconv.ParseInt(out.Format.BitRate, 10, 64); err == nil {
+		info.Bitrate = bitrate / 1000 // kb/s
+	}
+	info.Size, _ = strconv.ParseInt(out.Format.Size, 10, 64)
 
-		// 解析时长
-		if dur := extractValue(outputStr, "Duration: ", ","); dur != "" {
-			info.Duration = parseTime(dur)
+	video := false
+	for _, s := range out.Streams {
+		// cover art is a video stream too
+		if s.Disposition.AttachedPic == 1 || s.Disposition.TimedThumbnails == 1 {
+			continue
 		}
 
-		// 解析比特率
-		if bitrate := extractValue(outputStr, "bitrate: ", " kb/s"); bitrate != "" {
-			fmt.Sscanf(bitrate, "%d", &info.Bitrate)
-		} else {
-			info.Bitrate = -1
+		info.Streams = append(info.Streams, StreamInfo{
+			Index:    s.Index,
+			Type:     s.CodecType,
+			Codec:    s.CodecName,
+			Language: s.Tags.Language,
+			Title:    s.Tags.Title,
+			Channels: s.Channels,
+			Default:  s.Disposition.Default == 1,
+		})
+
+		if s.CodecType != StreamVideo || video {
+			continue
 		}
+		video = true
 
-		// 解析分辨率
-		if width, height, err := parseVideoResolution(outputStr); err == nil {
-			info.Width = width
-			info.Height = height
-		} else {
-			info.Width = -1
-			info.Height = -1
+		info.Codec = s.CodecName
+		info.Width, info.Height = s.Width, s.Height
+		info.FrameRate = parseRational(s.AvgFrameRate)
+		if info.FrameRate <= 0 {
+			info.FrameRate = parseRational(s.RFrameRate)
 		}
+		// PQ or HLG transfer
+		info.HDR = s.ColorTransfer == "smpte2084" || s.ColorTransfer == "arib-std-b67"
 
-		// 解析视频编码类型
-		if codec, err := parseVideoEncodeType(outputStr); err == nil {
-			info.Codec = codec
-		} else {
-			info.Codec = ""
+		// display matrix rotation is counterclockwise, old tag is clockwise
+		rotation := 0
+		if r, err := strconv.Atoi(s.Tags.Rotate); err == nil {
+			rotation = r
+		}
+		for _, sd := range s.SideDataList {
+			if sd.Rotation != 0 {
+				rotation = -int(math.Round(sd.Rotation))
+			}
 		}
+		info.Rotation = (rotation%360 + 360) % 360
+		if info.Rotation == 90 || info.Rotation == 270 {
+			info.Width, info.Height = info.Height, info.Width
+		}
+	}
+	if !video {
+		return nil, errors.New("no video stream found")
+	}
 
-		// 解析帧率
-		if fps, err := parseVideoFrameRate(outputStr); err == nil {
-			info.FrameRate = fps
-		} else {
-			info.FrameRate = -1
+	if info.Size <= 0 {
+		if stat, err := os.Stat(path); err == nil {
+			info.Size = stat.Size()
 		}
+	}
 
-		return info, nil
+	return info, nil
+}
+
+// normalizeContainer 将 ffmpeg 的格式名称如 "mov,mp4,m4a,3gp,3g2,mj2" 转换为 mp4
+func normalizeContainer(name string) string {
+	names := strings.Split(name, ",")
+	for _, n := range names {
+		switch n {
+		case "mp4":
+			return "mp4"
+		case "matroska", "webm":
+			return "matroska"
+		}
+	}
+	return names[0]
+}
+
+// parseRational 解析 "30000/1001" 格式的帧率
+func parseRational(s string) float64 {
+	num, den, ok := strings.Cut(s, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	if !ok {
+		return n
+	}
+
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return math.Round(n/d*100) / 100
+}
+
+// legacyVideoInfo 解析 ffmpeg -i 的输出获取视频信息
+func (p *VideoProcessor) legacyVideoInfo(ctx context.Context, filepath string) (*VideoInfo, error) {
+	args := []string{
+		"-hide_banner",
+		"-i", filepath,
+	}
+
+	// ffmpeg 没有指定输出文件时总是返回错误码1，但仍会输出信息到stderr
+	cmd := exec.CommandContext(ctx, p.ffmpegPath, args...)
+	output, _ := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
 	}
-	return nil, errors.Wrap(err, "get video info")
+
+	outputStr := string(output)
+	if !strings.Contains(outputStr, "Video:") {
+		return nil, errors.Errorf("no video stream found: %s", strings.TrimSpace(outputStr))
+	}
+
+	info := &VideoInfo{
+		FilePath:  filepath,
+		Container: normalizeContainer(extractValue(outputStr, "Input #0, ", ", from")),
+	}
+
+	// 获取文件大小
+	if stat, err := os.Stat(filepath); err == nil {
+		info.Size = stat.Size()
+	} else {
+		info.Size = -1
+	}
+
+	// 解析时长
+	if dur := extractValue(outputStr, "Duration: ", ","); dur != "" {
+		info.Duration = parseTime(dur)
+	}
+
+	// 解析比特率
+	if bitrate := extractValue(outputStr, "bitrate: ", " kb/s"); bitrate != "" {
+		fmt.Sscanf(bitrate, "%d", &info.Bitrate)
+	} else {
+		info.Bitrate = -1
+	}
+
+	// 解析分辨率
+	if width, height, err := parseVideoResolution(outputStr); err == nil {
+		info.Width = width
+		info.Height = height
+	} else {
+		info.Width = -1
+		info.Height = -1
+	}
+
+	// 解析视频编码类型
+	if codec, err := parseVideoEncodeType(outputStr); err == nil {
+		info.Codec = codec
+	} else {
+		info.Codec = ""
+	}
+
+	// 解析帧率
+	if fps, err := parseVideoFrameRate(outputStr); err == nil {
+		info.FrameRate = fps
+	} else {
+		info.FrameRate = -1
+	}
+
+	return info, nil
 }
 
 // SplitVideo 分割视频
@@ -214,9 +432,9 @@ func extractValue(text, prefix, suffix string) string {
 // 辅助函数：解析时间格式 (HH:MM:SS.ms)
 func parseTime(timeStr string) float64 {
 	var hours, minutes, seconds float64
-	var milliseconds float64
-	fmt.Sscanf(timeStr, "%f:%f:%f.%f", &hours, &minutes, &seconds, &milliseconds)
-	return hours*3600 + minutes*60 + seconds + milliseconds/100
+	// seconds contains fraction part, e.g. 00:01:02.50 is 62.5s
+	fmt.Sscanf(timeStr, "%f:%f:%f", &hours, &minutes, &seconds)
+	return hours*3600 + minutes*60 + seconds
 }
 
 // 辅助函数：解析视频分辨率
@@ -0,0 +1,63 @@
+package mediautil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTime(t *testing.T) {
+	assert.Equal(t, 62.5, parseTime("00:01:02.50"))
+	assert.Equal(t, 3723.04, parseTime("01:02:03.04"))
+}
+
+func TestParseFFprobe(t *testing.T) {
+	output := `{
+	"streams": [
+		{
+			"index": 0, "codec_name": "hevc", "codec_type": "video", "width": 3840, "height": 2160,
+			"avg_frame_rate": "30000/1001", "r_frame_rate": "30/1", "color_transfer": "smpte2084",
+			"disposition": {"default": 1, "attached_pic": 0},
+			"side_data_list": [{"side_data_type": "Display Matrix", "rotation": -90}]
+		},
+		{
+			"index": 1, "codec_name": "aac", "codec_type": "audio", "channels": 2,
+			"disposition": {"default": 1}, "tags": {"language": "jpn", "title": "Stereo"}
+		},
+		{
+			"index": 2, "codec_name": "ac3", "codec_type": "audio", "channels": 6,
+			"disposition": {"default": 0}, "tags": {"language": "eng"}
+		},
+		{
+			"index": 3, "codec_name": "subrip", "codec_type": "subtitle",
+			"disposition": {"default": 0}, "tags": {"language": "chi"}
+		},
+		{
+			"index": 4, "codec_name": "mjpeg", "codec_type": "video", "width": 600, "height": 600,
+			"disposition": {"attached_pic": 1}
+		}
+	],
+	"format": {"duration": "120.500000", "bit_rate": "8000000", "size": "120500000"}
+}`
+
+	info, err := parseFFprobe("video.mkv", []byte(output))
+	require.NoError(t, err)
+
+	assert.Equal(t, 120.5, info.Duration)
+	assert.Equal(t, int64(8000), info.Bitrate)
+	assert.Equal(t, int64(120500000), info.Size)
+	assert.Equal(t, "hevc", info.Codec)
+	assert.Equal(t, 2160, info.Width)
+	assert.Equal(t, 3840, info.Height)
+	assert.Equal(t, 90, info.Rotation)
+	assert.Equal(t, 29.97, info.FrameRate)
+	assert.True(t, info.HDR)
+
+	assert.Len(t, info.Streams, 4)
+	assert.Equal(t, []StreamInfo{
+		{Index: 1, Type: StreamAudio, Codec: "aac", Language: "jpn", Title: "Stereo", Channels: 2, Default: true},
+		{Index: 2, Type: StreamAudio, Codec: "ac3", Language: "eng", Channels: 6},
+	}, info.StreamsOf(StreamAudio))
+	assert.Len(t, info.StreamsOf(StreamSubtitle), 1)
+}
@@ -0,0 +1,130 @@
+package textutil
+
+import (
+	"reflect"
+	"sort"
+	"unicode/utf16"
+
+	"github.com/gotd/td/tg"
+)
+
+//go:generate go-enum --values --names --flag --nocase
+
+// EntityType
+// ENUM(url, text_url, mention, mention_name, hashtag, cashtag, email, phone, bot_command)
+type EntityType int
+
+// entityType returns type of entity, false if it's not filterable, e.g. bold
+func entityType(e tg.MessageEntityClass) (EntityType, bool) {
+	switch e.(type) {
+	case *tg.MessageEntityURL:
+		return EntityTypeUrl, true
+	case *tg.MessageEntityTextURL:
+		return EntityTypeTextUrl, true
+	case *tg.MessageEntityMention:
+		return EntityTypeMention, true
+	case *tg.MessageEntityMentionName:
+		return EntityTypeMentionName, true
+	case *tg.MessageEntityHashtag:
+		return EntityTypeHashtag, true
+	case *tg.MessageEntityCashtag:
+		return EntityTypeCashtag, true
+	case *tg.MessageEntityEmail:
+		return EntityTypeEmail, true
+	case *tg.MessageEntityPhone:
+		return EntityTypePhone, true
+	case *tg.MessageEntityBotCommand:
+		return EntityTypeBotCommand, true
+	}
+	return 0, false
+}
+
+// FilterEntities removes entities of the given types. Text of text_url and mention_name is kept,
+// while text of other types is removed with one adjacent space, because the text is the entity itself.
+// Offsets of the rest entities are adjusted. It reports whether the message is changed.
+func FilterEntities(text string, entities []tg.MessageEntityClass, drop []EntityType) (string, []tg.MessageEntityClass, bool) {
+	if len(drop) == 0 || len(entities) == 0 {
+		return text, entities, false
+	}
+
+	dropped := make(map[EntityType]struct{}, len(drop))
+	for _, t := range drop {
+		dropped[t] = struct{}{}
+	}
+
+	u := utf16.Encode([]rune(text))
+	var (
+		cuts [][2]int
+		kept = make([]tg.MessageEntityClass, 0, len(entities))
+	)
+	for _, e := range entities {
+		t, ok := entityType(e)
+		if _, d := dropped[t]; !ok || !d {
+			kept = append(kept, e)
+			continue
+		}
+
+		if t == EntityTypeTextUrl || t == EntityTypeMentionName {
+			continue
+		}
+		cuts = append(cuts, spaceCut(u, e.GetOffset(), e.GetOffset()+e.GetLength()))
+	}
+
+	if len(kept) == len(entities) {
+		return text, entities, false
+	}
+
+	text, kept = CutText(u, kept, cuts)
+	return text, kept, true
+}
+
+// spaceCut extends [start, end) with one adjacent space, so that no double spaces are left
+func spaceCut(u []uint16, start, end int) [2]int {
+	start, end = max(start, 0), min(end, len(u))
+	switch {
+	case end < len(u) && u[end] == ' ' && (start == 0 || u[start-1] == ' ' || u[start-1] == '\n'):
+		end++
+	case start > 0 && u[start-1] == ' ' && (end == len(u) || u[end] == '\n'):
+		start--
+	}
+	return [2]int{start, end}
+}
+
+// CutText removes ranges of UTF-16 text u, and adjusts offsets of entities. Entities which are empty after
+// cutting are removed.
+func CutText(u []uint16, entities []tg.MessageEntityClass, cuts [][2]int) (string, []tg.MessageEntityClass) {
+	cuts = mergeCuts(cuts)
+
+	edits := make([]Edit, 0, len(cuts))
+	for _, c := range cuts {
+		edits = append(edits, Edit{Start: c[0], End: c[1]})
+	}
+	return splice(u, entities, edits)
+}
+
+func mergeCuts(cuts [][2]int) [][2]int {
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i][0] < cuts[j][0] })
+
+	merged := make([][2]int, 0, len(cuts))
+	for _, c := range cuts {
+		if c[1] <= c[0] {
+			continue
+		}
+		if n := len(merged); n > 0 && c[0] <= merged[n-1][1] {
+			merged[n-1][1] = max(merged[n-1][1], c[1])
+			continue
+		}
+		merged = append(merged, c)
+	}
+	return merged
+}
+
+// SetEntityBounds returns a copy of entity with new offset and length
+func SetEntityBounds(e tg.MessageEntityClass, offset, length int) tg.MessageEntityClass {
+	v := reflect.New(reflect.TypeOf(e).Elem())
+	v.Elem().Set(reflect.ValueOf(e).Elem())
+	v.Elem().FieldByName("Offset").SetInt(int64(offset))
+	v.Elem().FieldByName("Length").SetInt(int64(length))
+
+	return v.Interface().(tg.MessageEntityClass)
+}
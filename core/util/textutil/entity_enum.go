@@ -0,0 +1,148 @@
+// Code generated by go-enum DO NOT EDIT.
+// Version: 0.5.8
+// Revision: 3d844c8ecc59661ed7aa17bfd65727bc06a60ad8
+// Build Date: 2023-09-18T14:55:21Z
+// Built By: goreleaser
+
+package textutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// EntityTypeUrl is a EntityType of type Url.
+	EntityTypeUrl EntityType = iota
+	// EntityTypeTextUrl is a EntityType of type Text_url.
+	EntityTypeTextUrl
+	// EntityTypeMention is a EntityType of type Mention.
+	EntityTypeMention
+	// EntityTypeMentionName is a EntityType of type Mention_name.
+	EntityTypeMentionName
+	// EntityTypeHashtag is a EntityType of type Hashtag.
+	EntityTypeHashtag
+	// EntityTypeCashtag is a EntityType of type Cashtag.
+	EntityTypeCashtag
+	// EntityTypeEmail is a EntityType of type Email.
+	EntityTypeEmail
+	// EntityTypePhone is a EntityType of type Phone.
+	EntityTypePhone
+	// EntityTypeBotCommand is a EntityType of type Bot_command.
+	EntityTypeBotCommand
+)
+
+var ErrInvalidEntityType = fmt.Errorf("not a valid EntityType, try [%s]", strings.Join(_EntityTypeNames, ", "))
+
+const _EntityTypeName = "urltext_urlmentionmention_namehashtagcashtagemailphonebot_command"
+
+var _EntityTypeNames = []string{
+	_EntityTypeName[0:3],
+	_EntityTypeName[3:11],
+	_EntityTypeName[11:18],
+	_EntityTypeName[18:30],
+	_EntityTypeName[30:37],
+	_EntityTypeName[37:44],
+	_EntityTypeName[44:49],
+	_EntityTypeName[49:54],
+	_EntityTypeName[54:65],
+}
+
+// EntityTypeNames returns a list of possible string values of EntityType.
+func EntityTypeNames() []string {
+	tmp := make([]string, len(_EntityTypeNames))
+	copy(tmp, _EntityTypeNames)
+	return tmp
+}
+
+// EntityTypeValues returns a list of the values for EntityType
+func EntityTypeValues() []EntityType {
+	return []EntityType{
+		EntityTypeUrl,
+		EntityTypeTextUrl,
+		EntityTypeMention,
+		EntityTypeMentionName,
+		EntityTypeHashtag,
+		EntityTypeCashtag,
+		EntityTypeEmail,
+		EntityTypePhone,
+		EntityTypeBotCommand,
+	}
+}
+
+var _EntityTypeMap = map[EntityType]string{
+	EntityTypeUrl:         _EntityTypeName[0:3],
+	EntityTypeTextUrl:     _EntityTypeName[3:11],
+	EntityTypeMention:     _EntityTypeName[11:18],
+	EntityTypeMentionName: _EntityTypeName[18:30],
+	EntityTypeHashtag:     _EntityTypeName[30:37],
+	EntityTypeCashtag:     _EntityTypeName[37:44],
+	EntityTypeEmail:       _EntityTypeName[44:49],
+	EntityTypePhone:       _EntityTypeName[49:54],
+	EntityTypeBotCommand:  _EntityTypeName[54:65],
+}
+
+// String implements the Stringer interface.
+func (x EntityType) String() string {
+	if str, ok := _EntityTypeMap[x]; ok {
+		return str
+	}
+	return fmt.Sprintf("EntityType(%d)", x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x EntityType) IsValid() bool {
+	_, ok := _EntityTypeMap[x]
+	return ok
+}
+
+var _EntityTypeValue = map[string]EntityType{
+	_EntityTypeName[0:3]:                    EntityTypeUrl,
+	strings.ToLower(_EntityTypeName[0:3]):   EntityTypeUrl,
+	_EntityTypeName[3:11]:                   EntityTypeTextUrl,
+	strings.ToLower(_EntityTypeName[3:11]):  EntityTypeTextUrl,
+	_EntityTypeName[11:18]:                  EntityTypeMention,
+	strings.ToLower(_EntityTypeName[11:18]): EntityTypeMention,
+	_EntityTypeName[18:30]:                  EntityTypeMentionName,
+	strings.ToLower(_EntityTypeName[18:30]): EntityTypeMentionName,
+	_EntityTypeName[30:37]:                  EntityTypeHashtag,
+	strings.ToLower(_EntityTypeName[30:37]): EntityTypeHashtag,
+	_EntityTypeName[37:44]:                  EntityTypeCashtag,
+	strings.ToLower(_EntityTypeName[37:44]): EntityTypeCashtag,
+	_EntityTypeName[44:49]:                  EntityTypeEmail,
+	strings.ToLower(_EntityTypeName[44:49]): EntityTypeEmail,
+	_EntityTypeName[49:54]:                  EntityTypePhone,
+	strings.ToLower(_EntityTypeName[49:54]): EntityTypePhone,
+	_EntityTypeName[54:65]:                  EntityTypeBotCommand,
+	strings.ToLower(_EntityTypeName[54:65]): EntityTypeBotCommand,
+}
+
+// ParseEntityType attempts to convert a string to a EntityType.
+func ParseEntityType(name string) (EntityType, error) {
+	if x, ok := _EntityTypeValue[name]; ok {
+		return x, nil
+	}
+	// Case insensitive parse, do a separate lookup to prevent unnecessary cost of lowercasing a string if we don't need to.
+	if x, ok := _EntityTypeValue[strings.ToLower(name)]; ok {
+		return x, nil
+	}
+	return EntityType(0), fmt.Errorf("%s is %w", name, ErrInvalidEntityType)
+}
+
+// Set implements the Golang flag.Value interface func.
+func (x *EntityType) Set(val string) error {
+	v, err := ParseEntityType(val)
+	*x = v
+	return err
+}
+
+// Get implements the Golang flag.Getter interface func.
+func (x *EntityType) Get() interface{} {
+	return *x
+}
+
+// Type implements the github.com/spf13/pFlag Value interface.
+func (x *EntityType) Type() string {
+	return "EntityType"
+}
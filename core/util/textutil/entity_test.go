@@ -0,0 +1,87 @@
+package textutil
+
+import (
+	"testing"
+	"unicode/utf16"
+
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterEntities(t *testing.T) {
+	// 👋 takes 2 UTF-16 code units
+	text := "👋 see https://example.com and docs by @user #tag"
+	entities := []tg.MessageEntityClass{
+		&tg.MessageEntityBold{Offset: 0, Length: 6},
+		&tg.MessageEntityURL{Offset: 7, Length: 19},
+		&tg.MessageEntityTextURL{Offset: 31, Length: 4, URL: "https://docs"},
+		&tg.MessageEntityMention{Offset: 39, Length: 5},
+		&tg.MessageEntityHashtag{Offset: 45, Length: 4},
+	}
+
+	tests := []struct {
+		name     string
+		drop     []EntityType
+		text     string
+		entities []tg.MessageEntityClass
+		changed  bool
+	}{
+		{
+			name:     "nothing",
+			drop:     nil,
+			text:     text,
+			entities: entities,
+		},
+		{
+			name:     "type not present",
+			drop:     []EntityType{EntityTypeEmail},
+			text:     text,
+			entities: entities,
+		},
+		{
+			name: "links",
+			drop: []EntityType{EntityTypeUrl, EntityTypeTextUrl},
+			text: "👋 see and docs by @user #tag",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityBold{Offset: 0, Length: 6},
+				&tg.MessageEntityMention{Offset: 19, Length: 5},
+				&tg.MessageEntityHashtag{Offset: 25, Length: 4},
+			},
+			changed: true,
+		},
+		{
+			name: "mention and trailing hashtag",
+			drop: []EntityType{EntityTypeMention, EntityTypeHashtag},
+			text: "👋 see https://example.com and docs by ", // spaces around adjacent entities are cut only once
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityBold{Offset: 0, Length: 6},
+				&tg.MessageEntityURL{Offset: 7, Length: 19},
+				&tg.MessageEntityTextURL{Offset: 31, Length: 4, URL: "https://docs"},
+			},
+			changed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, entities, changed := FilterEntities(text, entities, tt.drop)
+			assert.Equal(t, tt.text, text)
+			assert.Equal(t, tt.entities, entities)
+			assert.Equal(t, tt.changed, changed)
+		})
+	}
+}
+
+func TestCutText(t *testing.T) {
+	text, entities := CutText(utf16.Encode([]rune("abcdefgh")), []tg.MessageEntityClass{
+		&tg.MessageEntityBold{Offset: 1, Length: 4},   // overlaps with cut
+		&tg.MessageEntityItalic{Offset: 3, Length: 1}, // inside cut
+		&tg.MessageEntityCode{Offset: 6, Length: 2},
+	}, [][2]int{{5, 6}, {2, 4}, {3, 5}})
+
+	assert.Equal(t, "abgh", text)
+	assert.Equal(t, []tg.MessageEntityClass{
+		&tg.MessageEntityBold{Offset: 1, Length: 1},
+		&tg.MessageEntityCode{Offset: 2, Length: 2},
+	}, entities)
+}
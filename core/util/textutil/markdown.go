@@ -0,0 +1,471 @@
+package textutil
+
+import (
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-faster/errors"
+	"github.com/gotd/td/telegram/message/entity"
+	"github.com/gotd/td/tg"
+)
+
+// MarkdownOptions is options of Markdown.
+type MarkdownOptions struct {
+	// UserResolver is used to resolve user by ID of `tg://user?id=` links. May be nil.
+	//
+	// If UserResolver is nil, tg.InputUser will be created using only ID.
+	UserResolver entity.UserResolver
+}
+
+// reserved characters which can be escaped by '\', refer to https://core.telegram.org/bots/api#markdownv2-style
+const mdReserved = "_*[]()~`>#+-=|{}.!\\"
+
+type mdParser struct {
+	src  []rune
+	b    *entity.Builder
+	opts MarkdownOptions
+}
+
+// Markdown reads Telegram MarkdownV2 styled text from r and writes result to b.
+//
+// Supported syntax:
+//
+//	*bold* _italic_ __underline__ ~strike~ ||spoiler||
+//	`inline code` ```lang\npre code```
+//	[text](https://example.com) [user](tg://user?id=123) ![👍](tg://emoji?id=5368324170671202286)
+//	>blockquote and **>expandable blockquote||
+//
+// Unlike Bot API, it is lenient: unpaired markers are kept as plain text instead of returning error,
+// and '_' inside words (like file names) is not treated as italic or underline marker.
+func Markdown(r io.Reader, b *entity.Builder, opts MarkdownOptions) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return errors.Wrap(err, "read markdown")
+	}
+
+	if opts.UserResolver == nil {
+		opts.UserResolver = func(id int64) (tg.InputUserClass, error) {
+			return &tg.InputUser{UserID: id}, nil
+		}
+	}
+
+	p := &mdParser{
+		src:  []rune(string(data)),
+		b:    b,
+		opts: opts,
+	}
+
+	return p.parse(0, len(p.src))
+}
+
+func (p *mdParser) parse(start, end int) error {
+	for i := start; i < end; {
+		c := p.src[i]
+
+		switch {
+		case c == '\\' && i+1 < end && strings.ContainsRune(mdReserved, p.src[i+1]):
+			p.write(p.src[i+1])
+			i += 2
+			continue
+		case p.lineStart(i) && (c == '>' || p.hasPrefix(i, end, "**>")):
+			if next, ok, err := p.blockquote(i, end); err != nil {
+				return err
+			} else if ok {
+				i = next
+				continue
+			}
+		case p.hasPrefix(i, end, "```"):
+			if next, ok := p.pre(i, end); ok {
+				i = next
+				continue
+			}
+		case c == '`':
+			if next, ok := p.code(i, end); ok {
+				i = next
+				continue
+			}
+		case p.hasPrefix(i, end, "||"):
+			if next, ok, err := p.wrap(i, end, "||", entity.Spoiler()); err != nil {
+				return err
+			} else if ok {
+				i = next
+				continue
+			}
+		case p.hasPrefix(i, end, "__"):
+			if next, ok, err := p.wrap(i, end, "__", entity.Underline()); err != nil {
+				return err
+			} else if ok {
+				i = next
+				continue
+			}
+		case c == '_':
+			if next, ok, err := p.wrap(i, end, "_", entity.Italic()); err != nil {
+				return err
+			} else if ok {
+				i = next
+				continue
+			}
+		case c == '*':
+			if next, ok, err := p.wrap(i, end, "*", entity.Bold()); err != nil {
+				return err
+			} else if ok {
+				i = next
+				continue
+			}
+		case c == '~':
+			if next, ok, err := p.wrap(i, end, "~", entity.Strike()); err != nil {
+				return err
+			} else if ok {
+				i = next
+				continue
+			}
+		case p.hasPrefix(i, end, "!["):
+			if next, ok, err := p.link(i+1, end, true); err != nil {
+				return err
+			} else if ok {
+				i = next
+				continue
+			}
+		case c == '[':
+			if next, ok, err := p.link(i, end, false); err != nil {
+				return err
+			} else if ok {
+				i = next
+				continue
+			}
+		}
+
+		// plain text or unpaired marker
+		p.write(c)
+		i++
+	}
+
+	return nil
+}
+
+// wrap formats text between open marker at i and its closing marker.
+func (p *mdParser) wrap(i, end int, marker string, f entity.Formatter) (int, bool, error) {
+	if isWordMarker(marker) && i > 0 && isWordRune(p.src[i-1]) {
+		return 0, false, nil
+	}
+
+	from := i + len(marker)
+	to := p.closing(from, end, marker)
+	if to <= from {
+		return 0, false, nil
+	}
+
+	tok := p.b.Token()
+	if err := p.parse(from, to); err != nil {
+		return 0, false, err
+	}
+	tok.Apply(p.b, f)
+
+	return to + len(marker), true, nil
+}
+
+// closing returns the index of closing marker in [from, end), or -1 if not found.
+// Escaped characters, code spans and link URLs are skipped.
+func (p *mdParser) closing(from, end int, marker string) int {
+	for k := from; k < end; {
+		switch {
+		case p.src[k] == '\\' && k+1 < end:
+			k += 2
+			continue
+		case p.src[k] == '`':
+			if p.hasPrefix(k, end, "```") {
+				if j := p.find(k+3, end, "```"); j >= 0 {
+					k = j + 3
+					continue
+				}
+			} else if j := p.find(k+1, end, "`"); j >= 0 {
+				k = j + 1
+				continue
+			}
+		case p.src[k] == '[':
+			if _, _, urlEnd, ok := p.linkBounds(k, end); ok {
+				k = urlEnd + 1
+				continue
+			}
+		}
+
+		if p.hasPrefix(k, end, marker) {
+			// '_' is followed by another '_', it's nested underline
+			if marker == "_" && p.hasPrefix(k, end, "__") {
+				if j := p.closing(k+2, end, "__"); j > k+2 {
+					k = j + 2
+					continue
+				}
+			}
+
+			if isWordMarker(marker) && k+len(marker) < end && isWordRune(p.src[k+len(marker)]) {
+				k++
+				continue
+			}
+
+			return k
+		}
+
+		k++
+	}
+
+	return -1
+}
+
+// find returns index of marker in [from, end) skipping escaped characters, or -1 if not found.
+func (p *mdParser) find(from, end int, marker string) int {
+	for k := from; k < end; k++ {
+		if p.src[k] == '\\' {
+			k++
+			continue
+		}
+		if p.hasPrefix(k, end, marker) {
+			return k
+		}
+	}
+
+	return -1
+}
+
+func (p *mdParser) code(i, end int) (int, bool) {
+	to := p.find(i+1, end, "`")
+	if to <= i+1 {
+		return 0, false
+	}
+
+	tok := p.b.Token()
+	p.writeCode(i+1, to)
+	tok.Apply(p.b, entity.Code())
+
+	return to + 1, true
+}
+
+func (p *mdParser) pre(i, end int) (int, bool) {
+	from := i + 3
+	to := p.find(from, end, "```")
+	if to < 0 {
+		return 0, false
+	}
+
+	// first line is language if it's a single word
+	lang := ""
+	if nl := p.indexRune(from, to, '\n'); nl >= 0 {
+		first := strings.TrimSpace(string(p.src[from:nl]))
+		if !strings.ContainsFunc(first, unicode.IsSpace) {
+			lang = first
+			from = nl + 1
+		}
+	}
+	// trim trailing new line before closing marker
+	content := to
+	if content > from && p.src[content-1] == '\n' {
+		content--
+	}
+	if content <= from {
+		return 0, false
+	}
+
+	tok := p.b.Token()
+	p.writeCode(from, content)
+	tok.Apply(p.b, entity.Pre(lang))
+
+	return to + 3, true
+}
+
+// link parses [text](url) starting at i. If emoji is true, url must be tg://emoji?id= link.
+func (p *mdParser) link(i, end int, emoji bool) (int, bool, error) {
+	textEnd, urlStart, urlEnd, ok := p.linkBounds(i, end)
+	if !ok || textEnd == i+1 {
+		return 0, false, nil
+	}
+
+	rawURL := p.unescape(urlStart, urlEnd)
+	f, isEmoji, ok := p.urlFormatter(rawURL)
+	if !ok || isEmoji != emoji {
+		return 0, false, nil
+	}
+
+	tok := p.b.Token()
+	if err := p.parse(i+1, textEnd); err != nil {
+		return 0, false, err
+	}
+	tok.Apply(p.b, f)
+
+	return urlEnd + 1, true, nil
+}
+
+// linkBounds returns index of ']', start of URL and index of ')' of link starting at i.
+func (p *mdParser) linkBounds(i, end int) (textEnd, urlStart, urlEnd int, ok bool) {
+	depth := 0
+	textEnd = -1
+	for k := i; k < end; k++ {
+		switch p.src[k] {
+		case '\\':
+			k++
+		case '[':
+			depth++
+		case ']':
+			depth--
+		}
+		if depth == 0 {
+			textEnd = k
+			break
+		}
+	}
+	if textEnd < 0 || textEnd+1 >= end || p.src[textEnd+1] != '(' {
+		return 0, 0, 0, false
+	}
+
+	urlStart = textEnd + 2
+	urlEnd = p.find(urlStart, end, ")")
+	if urlEnd <= urlStart {
+		return 0, 0, 0, false
+	}
+
+	return textEnd, urlStart, urlEnd, true
+}
+
+func (p *mdParser) urlFormatter(rawURL string) (f entity.Formatter, emoji bool, ok bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, false, false
+	}
+
+	if u.Scheme == "tg" {
+		id, err := strconv.ParseInt(u.Query().Get("id"), 10, 64)
+		if err != nil {
+			return nil, false, false
+		}
+
+		switch u.Host {
+		case "user":
+			user, err := p.opts.UserResolver(id)
+			if err != nil {
+				return nil, false, false
+			}
+			return entity.MentionName(user), false, true
+		case "emoji":
+			return entity.CustomEmoji(id), true, true
+		}
+	}
+
+	return entity.TextURL(rawURL), false, true
+}
+
+// blockquote parses consecutive lines starting with '>' at i.
+func (p *mdParser) blockquote(i, end int) (int, bool, error) {
+	type line struct{ from, to int }
+
+	expandable := p.hasPrefix(i, end, "**>")
+	lines := make([]line, 0)
+
+	k := i
+	for k < end {
+		prefix := 1
+		if k == i && expandable {
+			prefix = 3
+		} else if p.src[k] != '>' {
+			break
+		}
+
+		to := p.indexRune(k, end, '\n')
+		if to < 0 {
+			to = end
+		}
+		lines = append(lines, line{from: k + prefix, to: to})
+
+		if to == end {
+			k = end
+			break
+		}
+		k = to + 1
+	}
+
+	next := lines[len(lines)-1].to
+	if expandable {
+		last := &lines[len(lines)-1]
+		if !p.hasPrefix(last.to-2, last.to, "||") {
+			return 0, false, nil
+		}
+		last.to -= 2
+	}
+
+	tok := p.b.Token()
+	for idx, l := range lines {
+		if idx > 0 {
+			p.write('\n')
+		}
+		if err := p.parse(l.from, l.to); err != nil {
+			return 0, false, err
+		}
+	}
+	tok.Apply(p.b, entity.Blockquote(expandable))
+
+	return next, true, nil
+}
+
+// writeCode writes code content, only '`' and '\' can be escaped in code.
+func (p *mdParser) writeCode(from, to int) {
+	for k := from; k < to; k++ {
+		if p.src[k] == '\\' && k+1 < to && (p.src[k+1] == '`' || p.src[k+1] == '\\') {
+			k++
+		}
+		p.write(p.src[k])
+	}
+}
+
+func (p *mdParser) unescape(from, to int) string {
+	b := strings.Builder{}
+	for k := from; k < to; k++ {
+		if p.src[k] == '\\' && k+1 < to {
+			k++
+		}
+		b.WriteRune(p.src[k])
+	}
+
+	return b.String()
+}
+
+func (p *mdParser) write(r rune) {
+	_, _ = p.b.WriteRune(r)
+}
+
+func (p *mdParser) lineStart(i int) bool {
+	return i == 0 || p.src[i-1] == '\n'
+}
+
+func (p *mdParser) hasPrefix(i, end int, s string) bool {
+	if i < 0 {
+		return false
+	}
+	for _, r := range s {
+		if i >= end || p.src[i] != r {
+			return false
+		}
+		i++
+	}
+
+	return true
+}
+
+func (p *mdParser) indexRune(from, to int, r rune) int {
+	for k := from; k < to; k++ {
+		if p.src[k] == r {
+			return k
+		}
+	}
+
+	return -1
+}
+
+// isWordMarker reports whether marker is ignored inside words, e.g. snake_case file names.
+func isWordMarker(marker string) bool {
+	return marker == "_" || marker == "__"
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
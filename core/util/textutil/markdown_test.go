@@ -0,0 +1,136 @@
+package textutil
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/telegram/message/entity"
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		text     string
+		entities []tg.MessageEntityClass
+	}{
+		{
+			name:  "plain",
+			input: "hello world",
+			text:  "hello world",
+		},
+		{
+			name:  "bold and italic",
+			input: "*bold* and _italic_",
+			text:  "bold and italic",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityBold{Offset: 0, Length: 4},
+				&tg.MessageEntityItalic{Offset: 9, Length: 6},
+			},
+		},
+		{
+			name:  "nested",
+			input: "*bold _italic bold_*",
+			text:  "bold italic bold",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityBold{Offset: 0, Length: 16},
+				&tg.MessageEntityItalic{Offset: 5, Length: 11},
+			},
+		},
+		{
+			name:  "underline strike spoiler",
+			input: "__u__ ~s~ ||sp||",
+			text:  "u s sp",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityUnderline{Offset: 0, Length: 1},
+				&tg.MessageEntityStrike{Offset: 2, Length: 1},
+				&tg.MessageEntitySpoiler{Offset: 4, Length: 2},
+			},
+		},
+		{
+			name:  "snake case file name",
+			input: "my_video_part1.mp4",
+			text:  "my_video_part1.mp4",
+		},
+		{
+			name:  "unpaired marker",
+			input: "2 * 3 = 6",
+			text:  "2 * 3 = 6",
+		},
+		{
+			name:  "escape",
+			input: `\*not bold\* 1\.0`,
+			text:  "*not bold* 1.0",
+		},
+		{
+			name:  "inline code",
+			input: "run `tdl *up*`",
+			text:  "run tdl *up*",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityCode{Offset: 4, Length: 8},
+			},
+		},
+		{
+			name:  "pre with language",
+			input: "```go\nfmt.Println(1)\n```",
+			text:  "fmt.Println(1)",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityPre{Offset: 0, Length: 14, Language: "go"},
+			},
+		},
+		{
+			name:  "link",
+			input: "[tdl](https://github.com/iyear/tdl)",
+			text:  "tdl",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityTextURL{Offset: 0, Length: 3, URL: "https://github.com/iyear/tdl"},
+			},
+		},
+		{
+			name:  "mention user",
+			input: "[user](tg://user?id=123)",
+			text:  "user",
+			entities: []tg.MessageEntityClass{
+				&tg.InputMessageEntityMentionName{Offset: 0, Length: 4, UserID: &tg.InputUser{UserID: 123}},
+			},
+		},
+		{
+			name:  "custom emoji",
+			input: "![👍](tg://emoji?id=5368324170671202286)",
+			text:  "👍",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityCustomEmoji{Offset: 0, Length: 2, DocumentID: 5368324170671202286},
+			},
+		},
+		{
+			name:  "blockquote",
+			input: ">line1\n>line2\nafter",
+			text:  "line1\nline2\nafter",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityBlockquote{Offset: 0, Length: 11},
+			},
+		},
+		{
+			name:  "expandable blockquote",
+			input: "**>line1\n>line2||",
+			text:  "line1\nline2",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityBlockquote{Offset: 0, Length: 11, Collapsed: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := entity.Builder{}
+			require.NoError(t, Markdown(strings.NewReader(tt.input), &b, MarkdownOptions{}))
+
+			text, entities := b.Complete()
+			assert.Equal(t, tt.text, text)
+			assert.ElementsMatch(t, tt.entities, entities)
+		})
+	}
+}
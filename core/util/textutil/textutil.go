@@ -0,0 +1,32 @@
+package textutil
+
+import (
+	"strings"
+
+	"github.com/go-faster/errors"
+	"github.com/gotd/td/telegram/message/entity"
+	"github.com/gotd/td/telegram/message/html"
+)
+
+//go:generate go-enum --values --names --flag --nocase
+
+// ParseMode
+// ENUM(html, markdown)
+type ParseMode int
+
+// Parse parses styled text with the given mode and writes result to b.
+func Parse(mode ParseMode, text string, b *entity.Builder) error {
+	switch mode {
+	case ParseModeHtml:
+		return html.HTML(strings.NewReader(text), b, html.Options{
+			UserResolver:          nil,
+			DisableTelegramEscape: false,
+		})
+	case ParseModeMarkdown:
+		return Markdown(strings.NewReader(text), b, MarkdownOptions{
+			UserResolver: nil,
+		})
+	}
+
+	return errors.Errorf("unsupported parse mode %v", mode)
+}
@@ -0,0 +1,99 @@
+// Code generated by go-enum DO NOT EDIT.
+// Version: 0.5.8
+// Revision: 3d844c8ecc59661ed7aa17bfd65727bc06a60ad8
+// Build Date: 2023-09-18T14:55:21Z
+// Built By: goreleaser
+
+package textutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// ParseModeHtml is a ParseMode of type Html.
+	ParseModeHtml ParseMode = iota
+	// ParseModeMarkdown is a ParseMode of type Markdown.
+	ParseModeMarkdown
+)
+
+var ErrInvalidParseMode = fmt.Errorf("not a valid ParseMode, try [%s]", strings.Join(_ParseModeNames, ", "))
+
+const _ParseModeName = "htmlmarkdown"
+
+var _ParseModeNames = []string{
+	_ParseModeName[0:4],
+	_ParseModeName[4:12],
+}
+
+// ParseModeNames returns a list of possible string values of ParseMode.
+func ParseModeNames() []string {
+	tmp := make([]string, len(_ParseModeNames))
+	copy(tmp, _ParseModeNames)
+	return tmp
+}
+
+// ParseModeValues returns a list of the values for ParseMode
+func ParseModeValues() []ParseMode {
+	return []ParseMode{
+		ParseModeHtml,
+		ParseModeMarkdown,
+	}
+}
+
+var _ParseModeMap = map[ParseMode]string{
+	ParseModeHtml:     _ParseModeName[0:4],
+	ParseModeMarkdown: _ParseModeName[4:12],
+}
+
+// String implements the Stringer interface.
+func (x ParseMode) String() string {
+	if str, ok := _ParseModeMap[x]; ok {
+		return str
+	}
+	return fmt.Sprintf("ParseMode(%d)", x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x ParseMode) IsValid() bool {
+	_, ok := _ParseModeMap[x]
+	return ok
+}
+
+var _ParseModeValue = map[string]ParseMode{
+	_ParseModeName[0:4]:                   ParseModeHtml,
+	strings.ToLower(_ParseModeName[0:4]):  ParseModeHtml,
+	_ParseModeName[4:12]:                  ParseModeMarkdown,
+	strings.ToLower(_ParseModeName[4:12]): ParseModeMarkdown,
+}
+
+// ParseParseMode attempts to convert a string to a ParseMode.
+func ParseParseMode(name string) (ParseMode, error) {
+	if x, ok := _ParseModeValue[name]; ok {
+		return x, nil
+	}
+	// Case insensitive parse, do a separate lookup to prevent unnecessary cost of lowercasing a string if we don't need to.
+	if x, ok := _ParseModeValue[strings.ToLower(name)]; ok {
+		return x, nil
+	}
+	return ParseMode(0), fmt.Errorf("%s is %w", name, ErrInvalidParseMode)
+}
+
+// Set implements the Golang flag.Value interface func.
+func (x *ParseMode) Set(val string) error {
+	v, err := ParseParseMode(val)
+	*x = v
+	return err
+}
+
+// Get implements the Golang flag.Getter interface func.
+func (x *ParseMode) Get() interface{} {
+	return *x
+}
+
+// Type implements the github.com/spf13/pFlag Value interface.
+func (x *ParseMode) Type() string {
+	return "ParseMode"
+}
@@ -0,0 +1,267 @@
+package textutil
+
+import (
+	"regexp"
+	"sort"
+	"unicode/utf16"
+
+	"github.com/gotd/td/tg"
+)
+
+//go:generate go-enum --values --names --flag --nocase
+
+// Style
+// ENUM(plain, bold, italic, underline, strike, spoiler, code, blockquote, text_url)
+type Style int
+
+// Edit replaces UTF-16 range [Start, End) of text with Text. Start == End inserts Text at Start.
+type Edit struct {
+	Start, End int
+	Text       string
+}
+
+// Splice applies edits to text, and adjusts offsets of entities. Overlapping edits are ignored.
+//
+// An entity which covers the whole replaced range covers the replacement too, and an entity which
+// partially overlaps with the range is shrunk to the part out of the range. Inserted text isn't
+// covered by entities which end or start at the insertion point.
+func Splice(text string, entities []tg.MessageEntityClass, edits []Edit) (string, []tg.MessageEntityClass) {
+	return splice(utf16.Encode([]rune(text)), entities, edits)
+}
+
+type edit16 struct {
+	start, end int
+	text       []uint16
+}
+
+func splice(u []uint16, entities []tg.MessageEntityClass, edits []Edit) (string, []tg.MessageEntityClass) {
+	sorted := make([]Edit, len(edits))
+	copy(sorted, edits)
+	// insertion goes before replacement at the same offset
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Start != sorted[j].Start {
+			return sorted[i].Start < sorted[j].Start
+		}
+		return sorted[i].End < sorted[j].End
+	})
+
+	es := make([]edit16, 0, len(sorted))
+	for _, e := range sorted {
+		start, end := max(e.Start, 0), min(e.End, len(u))
+		if end < start || (len(es) > 0 && start < es[len(es)-1].end) {
+			continue
+		}
+		es = append(es, edit16{start: start, end: end, text: utf16.Encode([]rune(e.Text))})
+	}
+
+	out := make([]uint16, 0, len(u))
+	prev := 0
+	for _, e := range es {
+		out = append(out, u[prev:e.start]...)
+		out = append(out, e.text...)
+		prev = e.end
+	}
+	out = append(out, u[prev:]...)
+
+	// mapOffset returns the offset after editing, start reports whether x is start of an entity
+	mapOffset := func(x int, start bool) int {
+		d := 0
+		for _, e := range es {
+			switch {
+			case x < e.start || x == e.start && (!start || e.start < e.end):
+				return x + d
+			case x >= e.end:
+				d += len(e.text) - (e.end - e.start)
+			case start: // inside of replaced range
+				return e.start + d + len(e.text)
+			default:
+				return e.start + d
+			}
+		}
+		return x + d
+	}
+
+	result := make([]tg.MessageEntityClass, 0, len(entities))
+	for _, e := range entities {
+		start, end := mapOffset(e.GetOffset(), true), mapOffset(e.GetOffset()+e.GetLength(), false)
+		if end <= start {
+			continue
+		}
+		result = append(result, SetEntityBounds(e, start, end-start))
+	}
+
+	return string(utf16.Decode(out)), result
+}
+
+// ReplaceRegexp replaces matches of re in text with repl, which can contain $1-like submatches.
+// Entities are adjusted as Splice does. It reports whether the text is changed.
+func ReplaceRegexp(text string, entities []tg.MessageEntityClass, re *regexp.Regexp, repl string) (string, []tg.MessageEntityClass, bool) {
+	matches := re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text, entities, false
+	}
+
+	offsets := utf16Offsets(text)
+	edits := make([]Edit, 0, len(matches))
+	for _, m := range matches {
+		edits = append(edits, Edit{
+			Start: offsets[m[0]],
+			End:   offsets[m[1]],
+			Text:  string(re.ExpandString(nil, repl, text, m)),
+		})
+	}
+
+	t, e := Splice(text, entities, edits)
+	if t == text {
+		return text, entities, false
+	}
+	return t, e, true
+}
+
+// utf16Offsets returns UTF-16 offset of each byte offset of text, which is valid at rune boundaries
+func utf16Offsets(text string) []int {
+	offsets := make([]int, len(text)+1)
+	n := 0
+	for i, r := range text {
+		offsets[i] = n
+		n += max(utf16.RuneLen(r), 1)
+	}
+	offsets[len(text)] = n
+	return offsets
+}
+
+// RewriteLinks replaces URLs of url and text_url entities by re and repl. Text of url entities is
+// replaced, so offsets of other entities are adjusted. It reports whether the message is changed.
+func RewriteLinks(text string, entities []tg.MessageEntityClass, re *regexp.Regexp, repl string) (string, []tg.MessageEntityClass, bool) {
+	u := utf16.Encode([]rune(text))
+
+	var (
+		edits   []Edit
+		result  = make([]tg.MessageEntityClass, 0, len(entities))
+		changed = false
+	)
+	for _, e := range entities {
+		switch e := e.(type) {
+		case *tg.MessageEntityTextURL:
+			if url := re.ReplaceAllString(e.URL, repl); url != e.URL {
+				c := *e
+				c.URL = url
+				result = append(result, &c)
+				changed = true
+				continue
+			}
+		case *tg.MessageEntityURL:
+			start, end := max(e.Offset, 0), min(e.Offset+e.Length, len(u))
+			if start >= end {
+				break
+			}
+			old := string(utf16.Decode(u[start:end]))
+			if url := re.ReplaceAllString(old, repl); url != old {
+				edits = append(edits, Edit{Start: start, End: end, Text: url})
+			}
+		}
+		result = append(result, e)
+	}
+
+	if len(edits) == 0 {
+		if !changed {
+			return text, entities, false
+		}
+		return text, result, true
+	}
+
+	text, result = splice(u, result, edits)
+	return text, result, true
+}
+
+// Concat joins two styled texts with sep, sep is omitted if any of them is empty
+func Concat(a string, ae []tg.MessageEntityClass, b string, be []tg.MessageEntityClass, sep string) (string, []tg.MessageEntityClass) {
+	if b == "" {
+		return a, ae
+	}
+	if a == "" {
+		return b, be
+	}
+
+	prefix := a + sep
+	offset := len(utf16.Encode([]rune(prefix)))
+
+	entities := make([]tg.MessageEntityClass, 0, len(ae)+len(be))
+	entities = append(entities, ae...)
+	for _, e := range be {
+		entities = append(entities, SetEntityBounds(e, e.GetOffset()+offset, e.GetLength()))
+	}
+
+	return prefix + b, entities
+}
+
+// ConvertEntities converts entities of style from to style to, text_url can't be the target.
+// Entities are removed if to is plain, while the text is kept. It reports whether entities are changed.
+func ConvertEntities(entities []tg.MessageEntityClass, from, to Style) ([]tg.MessageEntityClass, bool) {
+	if from == to || from == StylePlain || to == StyleTextUrl {
+		return entities, false
+	}
+
+	result := make([]tg.MessageEntityClass, 0, len(entities))
+	changed := false
+	for _, e := range entities {
+		if s, ok := entityStyle(e); !ok || s != from {
+			result = append(result, e)
+			continue
+		}
+
+		changed = true
+		if to == StylePlain {
+			continue
+		}
+		result = append(result, styleEntity(to, e.GetOffset(), e.GetLength()))
+	}
+
+	if !changed {
+		return entities, false
+	}
+	return result, true
+}
+
+func entityStyle(e tg.MessageEntityClass) (Style, bool) {
+	switch e.(type) {
+	case *tg.MessageEntityBold:
+		return StyleBold, true
+	case *tg.MessageEntityItalic:
+		return StyleItalic, true
+	case *tg.MessageEntityUnderline:
+		return StyleUnderline, true
+	case *tg.MessageEntityStrike:
+		return StyleStrike, true
+	case *tg.MessageEntitySpoiler:
+		return StyleSpoiler, true
+	case *tg.MessageEntityCode:
+		return StyleCode, true
+	case *tg.MessageEntityBlockquote:
+		return StyleBlockquote, true
+	case *tg.MessageEntityTextURL:
+		return StyleTextUrl, true
+	}
+	return 0, false
+}
+
+// styleEntity creates entity of style, text_url and plain are not supported
+func styleEntity(s Style, offset, length int) tg.MessageEntityClass {
+	switch s {
+	case StyleBold:
+		return &tg.MessageEntityBold{Offset: offset, Length: length}
+	case StyleItalic:
+		return &tg.MessageEntityItalic{Offset: offset, Length: length}
+	case StyleUnderline:
+		return &tg.MessageEntityUnderline{Offset: offset, Length: length}
+	case StyleStrike:
+		return &tg.MessageEntityStrike{Offset: offset, Length: length}
+	case StyleSpoiler:
+		return &tg.MessageEntitySpoiler{Offset: offset, Length: length}
+	case StyleCode:
+		return &tg.MessageEntityCode{Offset: offset, Length: length}
+	case StyleBlockquote:
+		return &tg.MessageEntityBlockquote{Offset: offset, Length: length}
+	}
+	panic("unsupported style: " + s.String())
+}
@@ -0,0 +1,148 @@
+// Code generated by go-enum DO NOT EDIT.
+// Version: 0.5.8
+// Revision: 3d844c8ecc59661ed7aa17bfd65727bc06a60ad8
+// Build Date: 2023-09-18T14:55:21Z
+// Built By: goreleaser
+
+package textutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// StylePlain is a Style of type Plain.
+	StylePlain Style = iota
+	// StyleBold is a Style of type Bold.
+	StyleBold
+	// StyleItalic is a Style of type Italic.
+	StyleItalic
+	// StyleUnderline is a Style of type Underline.
+	StyleUnderline
+	// StyleStrike is a Style of type Strike.
+	StyleStrike
+	// StyleSpoiler is a Style of type Spoiler.
+	StyleSpoiler
+	// StyleCode is a Style of type Code.
+	StyleCode
+	// StyleBlockquote is a Style of type Blockquote.
+	StyleBlockquote
+	// StyleTextUrl is a Style of type Text_url.
+	StyleTextUrl
+)
+
+var ErrInvalidStyle = fmt.Errorf("not a valid Style, try [%s]", strings.Join(_StyleNames, ", "))
+
+const _StyleName = "plainbolditalicunderlinestrikespoilercodeblockquotetext_url"
+
+var _StyleNames = []string{
+	_StyleName[0:5],
+	_StyleName[5:9],
+	_StyleName[9:15],
+	_StyleName[15:24],
+	_StyleName[24:30],
+	_StyleName[30:37],
+	_StyleName[37:41],
+	_StyleName[41:51],
+	_StyleName[51:59],
+}
+
+// StyleNames returns a list of possible string values of Style.
+func StyleNames() []string {
+	tmp := make([]string, len(_StyleNames))
+	copy(tmp, _StyleNames)
+	return tmp
+}
+
+// StyleValues returns a list of the values for Style
+func StyleValues() []Style {
+	return []Style{
+		StylePlain,
+		StyleBold,
+		StyleItalic,
+		StyleUnderline,
+		StyleStrike,
+		StyleSpoiler,
+		StyleCode,
+		StyleBlockquote,
+		StyleTextUrl,
+	}
+}
+
+var _StyleMap = map[Style]string{
+	StylePlain:      _StyleName[0:5],
+	StyleBold:       _StyleName[5:9],
+	StyleItalic:     _StyleName[9:15],
+	StyleUnderline:  _StyleName[15:24],
+	StyleStrike:     _StyleName[24:30],
+	StyleSpoiler:    _StyleName[30:37],
+	StyleCode:       _StyleName[37:41],
+	StyleBlockquote: _StyleName[41:51],
+	StyleTextUrl:    _StyleName[51:59],
+}
+
+// String implements the Stringer interface.
+func (x Style) String() string {
+	if str, ok := _StyleMap[x]; ok {
+		return str
+	}
+	return fmt.Sprintf("Style(%d)", x)
+}
+
+// IsValid provides a quick way to determine if the typed value is
+// part of the allowed enumerated values
+func (x Style) IsValid() bool {
+	_, ok := _StyleMap[x]
+	return ok
+}
+
+var _StyleValue = map[string]Style{
+	_StyleName[0:5]:                    StylePlain,
+	strings.ToLower(_StyleName[0:5]):   StylePlain,
+	_StyleName[5:9]:                    StyleBold,
+	strings.ToLower(_StyleName[5:9]):   StyleBold,
+	_StyleName[9:15]:                   StyleItalic,
+	strings.ToLower(_StyleName[9:15]):  StyleItalic,
+	_StyleName[15:24]:                  StyleUnderline,
+	strings.ToLower(_StyleName[15:24]): StyleUnderline,
+	_StyleName[24:30]:                  StyleStrike,
+	strings.ToLower(_StyleName[24:30]): StyleStrike,
+	_StyleName[30:37]:                  StyleSpoiler,
+	strings.ToLower(_StyleName[30:37]): StyleSpoiler,
+	_StyleName[37:41]:                  StyleCode,
+	strings.ToLower(_StyleName[37:41]): StyleCode,
+	_StyleName[41:51]:                  StyleBlockquote,
+	strings.ToLower(_StyleName[41:51]): StyleBlockquote,
+	_StyleName[51:59]:                  StyleTextUrl,
+	strings.ToLower(_StyleName[51:59]): StyleTextUrl,
+}
+
+// ParseStyle attempts to convert a string to a Style.
+func ParseStyle(name string) (Style, error) {
+	if x, ok := _StyleValue[name]; ok {
+		return x, nil
+	}
+	// Case insensitive parse, do a separate lookup to prevent unnecessary cost of lowercasing a string if we don't need to.
+	if x, ok := _StyleValue[strings.ToLower(name)]; ok {
+		return x, nil
+	}
+	return Style(0), fmt.Errorf("%s is %w", name, ErrInvalidStyle)
+}
+
+// Set implements the Golang flag.Value interface func.
+func (x *Style) Set(val string) error {
+	v, err := ParseStyle(val)
+	*x = v
+	return err
+}
+
+// Get implements the Golang flag.Getter interface func.
+func (x *Style) Get() interface{} {
+	return *x
+}
+
+// Type implements the github.com/spf13/pFlag Value interface.
+func (x *Style) Type() string {
+	return "Style"
+}
@@ -0,0 +1,107 @@
+package textutil
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplice(t *testing.T) {
+	text, entities := Splice("abcdefgh", []tg.MessageEntityClass{
+		&tg.MessageEntityBold{Offset: 0, Length: 2},   // ends at insertion point
+		&tg.MessageEntityItalic{Offset: 2, Length: 2}, // covers the whole replaced range
+		&tg.MessageEntityCode{Offset: 4, Length: 2},   // overlaps with replaced range
+		&tg.MessageEntityStrike{Offset: 7, Length: 1},
+	}, []Edit{
+		{Start: 5, End: 7, Text: "XYZ"},
+		{Start: 2, End: 4, Text: "👋"},
+		{Start: 2, End: 2, Text: "+"},
+		{Start: 3, End: 5, Text: "ignored"}, // overlaps
+	})
+
+	assert.Equal(t, "ab+👋eXYZh", text)
+	assert.Equal(t, []tg.MessageEntityClass{
+		&tg.MessageEntityBold{Offset: 0, Length: 2},
+		&tg.MessageEntityItalic{Offset: 3, Length: 2},
+		&tg.MessageEntityCode{Offset: 5, Length: 1},
+		&tg.MessageEntityStrike{Offset: 9, Length: 1},
+	}, entities)
+}
+
+func TestReplaceRegexp(t *testing.T) {
+	// 👋 takes 2 UTF-16 code units
+	text := "👋 order #12 and #345 today"
+	entities := []tg.MessageEntityClass{
+		&tg.MessageEntityBold{Offset: 3, Length: 9},
+		&tg.MessageEntityItalic{Offset: 22, Length: 5},
+	}
+
+	text, entities, changed := ReplaceRegexp(text, entities, regexp.MustCompile(`#(\d+)`), "No.$1")
+	assert.True(t, changed)
+	assert.Equal(t, "👋 order No.12 and No.345 today", text)
+	assert.Equal(t, []tg.MessageEntityClass{
+		&tg.MessageEntityBold{Offset: 3, Length: 11},
+		&tg.MessageEntityItalic{Offset: 26, Length: 5},
+	}, entities)
+
+	_, _, changed = ReplaceRegexp(text, entities, regexp.MustCompile(`none`), "x")
+	assert.False(t, changed)
+}
+
+func TestRewriteLinks(t *testing.T) {
+	text := "see https://old.io/a and docs"
+	entities := []tg.MessageEntityClass{
+		&tg.MessageEntityURL{Offset: 4, Length: 15},
+		&tg.MessageEntityTextURL{Offset: 24, Length: 4, URL: "https://old.io/docs"},
+		&tg.MessageEntityBold{Offset: 24, Length: 4},
+	}
+
+	text, entities, changed := RewriteLinks(text, entities, regexp.MustCompile(`^https://old\.io`), "https://new.example")
+	assert.True(t, changed)
+	assert.Equal(t, "see https://new.example/a and docs", text)
+	assert.Equal(t, []tg.MessageEntityClass{
+		&tg.MessageEntityURL{Offset: 4, Length: 20},
+		&tg.MessageEntityTextURL{Offset: 29, Length: 4, URL: "https://new.example/docs"},
+		&tg.MessageEntityBold{Offset: 29, Length: 4},
+	}, entities)
+}
+
+func TestConcat(t *testing.T) {
+	text, entities := Concat("👋 hi", []tg.MessageEntityClass{
+		&tg.MessageEntityBold{Offset: 0, Length: 2},
+	}, "by me", []tg.MessageEntityClass{
+		&tg.MessageEntityItalic{Offset: 3, Length: 2},
+	}, "\n\n")
+	assert.Equal(t, "👋 hi\n\nby me", text)
+	assert.Equal(t, []tg.MessageEntityClass{
+		&tg.MessageEntityBold{Offset: 0, Length: 2},
+		&tg.MessageEntityItalic{Offset: 10, Length: 2},
+	}, entities)
+
+	// separator is omitted
+	text, _ = Concat("", nil, "by me", nil, "\n\n")
+	assert.Equal(t, "by me", text)
+}
+
+func TestConvertEntities(t *testing.T) {
+	entities := []tg.MessageEntityClass{
+		&tg.MessageEntityBold{Offset: 0, Length: 2},
+		&tg.MessageEntityTextURL{Offset: 3, Length: 2, URL: "https://example.com"},
+	}
+
+	converted, changed := ConvertEntities(entities, StyleBold, StyleItalic)
+	assert.True(t, changed)
+	assert.Equal(t, []tg.MessageEntityClass{
+		&tg.MessageEntityItalic{Offset: 0, Length: 2},
+		entities[1],
+	}, converted)
+
+	converted, changed = ConvertEntities(entities, StyleTextUrl, StylePlain)
+	assert.True(t, changed)
+	assert.Equal(t, entities[:1], converted)
+
+	_, changed = ConvertEntities(entities, StyleCode, StyleBold)
+	assert.False(t, changed)
+}
@@ -0,0 +1,35 @@
+package tutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/gotd/td/tg"
+)
+
+// Fingerprint identifies content of the message, which is the same for forwarded copies of it.
+// Media message is identified by photo or document ID, and text message by hash of normalized text.
+// It returns false if the message has no content to identify.
+func Fingerprint(msg *tg.Message) (string, bool) {
+	switch m := msg.Media.(type) {
+	case *tg.MessageMediaPhoto:
+		if photo, ok := m.Photo.AsNotEmpty(); ok {
+			return "photo:" + strconv.FormatInt(photo.ID, 10), true
+		}
+	case *tg.MessageMediaDocument:
+		if doc, ok := m.Document.AsNotEmpty(); ok {
+			return "doc:" + strconv.FormatInt(doc.ID, 10), true
+		}
+	}
+
+	// case and spaces are ignored
+	text := strings.Join(strings.Fields(strings.ToLower(msg.Message)), " ")
+	if text == "" {
+		return "", false
+	}
+
+	sum := sha256.Sum256([]byte(text))
+	return "text:" + hex.EncodeToString(sum[:16]), true
+}
@@ -0,0 +1,30 @@
+package tutil
+
+import (
+	"testing"
+
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprint(t *testing.T) {
+	fp := func(msg *tg.Message) string {
+		s, _ := Fingerprint(msg)
+		return s
+	}
+
+	assert.Equal(t, "photo:1", fp(&tg.Message{Message: "caption", Media: &tg.MessageMediaPhoto{Photo: &tg.Photo{ID: 1}}}))
+	assert.Equal(t, "doc:2", fp(&tg.Message{Media: &tg.MessageMediaDocument{Document: &tg.Document{ID: 2}}}))
+
+	// normalized text
+	assert.Equal(t, fp(&tg.Message{Message: "Hello  World\n"}), fp(&tg.Message{Message: "hello world"}))
+	assert.NotEqual(t, fp(&tg.Message{Message: "hello world"}), fp(&tg.Message{Message: "hello world!"}))
+
+	// link preview is identified by text
+	assert.Equal(t, fp(&tg.Message{Message: "a"}), fp(&tg.Message{Message: "a", Media: &tg.MessageMediaWebPage{}}))
+
+	_, ok := Fingerprint(&tg.Message{Message: " \n"})
+	assert.False(t, ok)
+	_, ok = Fingerprint(&tg.Message{Media: &tg.MessageMediaPhoto{Photo: &tg.PhotoEmpty{}}})
+	assert.False(t, ok)
+}
@@ -1,6 +1,8 @@
 package key
 
 import (
+	"strconv"
+
 	"github.com/lshcx/tdl/core/storage/keygen"
 )
 
@@ -11,3 +13,28 @@ func App() string {
 func Resume(fingerprint string) string {
 	return keygen.New("resume", fingerprint)
 }
+
+// ForwardResume marks the source message as forwarded in the forward job
+func ForwardResume(fingerprint string, from int64, msg int) string {
+	return keygen.New("resume", "forward", fingerprint, strconv.FormatInt(from, 10), strconv.Itoa(msg))
+}
+
+// ForwardMap maps the source message to the message in destination peer, shared by all forward jobs
+func ForwardMap(from int64, msg int, to int64) string {
+	return keygen.New("forward", "map", strconv.FormatInt(from, 10), strconv.Itoa(msg), strconv.FormatInt(to, 10))
+}
+
+// ForwardTargets lists all destination messages of the source message, used to propagate edits and deletions
+func ForwardTargets(from int64, msg int) string {
+	return keygen.New("forward", "targets", strconv.FormatInt(from, 10), strconv.Itoa(msg))
+}
+
+// ForwardDedup marks the content fingerprint as present in destination peer
+func ForwardDedup(to int64, fingerprint string) string {
+	return keygen.New("forward", "dedup", strconv.FormatInt(to, 10), fingerprint)
+}
+
+// ForwardDedupScan is the latest message ID of destination peer which is scanned for dedup
+func ForwardDedupScan(to int64) string {
+	return keygen.New("forward", "dedup", "scan", strconv.FormatInt(to, 10))
+}
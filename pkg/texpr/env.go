@@ -1,6 +1,8 @@
 package texpr
 
 import (
+	"unicode/utf16"
+
 	"github.com/gotd/td/tg"
 
 	"github.com/lshcx/tdl/core/tmedia"
@@ -8,23 +10,47 @@ import (
 )
 
 type EnvMessage struct {
-	Mentioned     bool            `comment:"Whether we were mentioned in this message"`
-	Silent        bool            `comment:"Whether this is a silent message (no notification triggered)"`
-	FromScheduled bool            `comment:"Whether this is a scheduled message"`
-	Pinned        bool            `comment:"Whether this message is pinned"`
-	ID            int             `comment:"ID of the message"`
-	FromID        int64           `comment:"ID of the sender of the message"`
-	Date          int             `comment:"Date of the message"`
-	Message       string          `comment:"The message"`
-	Media         EnvMessageMedia `comment:"Media attachment"`
-	Views         int             `comment:"View count"`
-	Forwards      int             `comment:"Forward count"`
+	Mentioned     bool               `comment:"Whether we were mentioned in this message"`
+	Silent        bool               `comment:"Whether this is a silent message (no notification triggered)"`
+	FromScheduled bool               `comment:"Whether this is a scheduled message"`
+	Pinned        bool               `comment:"Whether this message is pinned"`
+	ID            int                `comment:"ID of the message"`
+	FromID        int64              `comment:"ID of the sender of the message"`
+	Date          int                `comment:"Date of the message"`
+	Message       string             `comment:"The message"`
+	Media         EnvMessageMedia    `comment:"Media attachment"`
+	Views         int                `comment:"View count"`
+	Forwards      int                `comment:"Forward count"`
+	ReplyTo       int                `comment:"ID of the replied message, 0 if it's not a reply"`
+	TopicID       int                `comment:"ID of the forum topic, 0 if it's not in a topic"`
+	GroupedID     int64              `comment:"ID of the album, 0 if it's not in an album"`
+	Fwd           EnvMessageFwd      `comment:"Forwarded from header"`
+	Entities      EnvMessageEntities `comment:"Entities in the message"`
+	ReplyMarkup   bool               `comment:"Whether the message has reply markup, e.g. inline buttons"`
 }
 
 type EnvMessageMedia struct {
-	Name string `comment:"File name"`
-	Size int64  `comment:"File size. Unit: Byte"`
-	DC   int    `comment:"DC ID"`
+	Name     string  `comment:"File name"`
+	Size     int64   `comment:"File size. Unit: Byte"`
+	DC       int     `comment:"DC ID"`
+	Type     string  `comment:"Media type: photo, video, round, animation, audio, voice, sticker, document, poll, location, contact, webpage or other. Empty if no media"`
+	MIME     string  `comment:"MIME type of the file"`
+	Duration float64 `comment:"Duration of video or audio. Unit: Second"`
+}
+
+type EnvMessageFwd struct {
+	Forwarded  bool   `comment:"Whether the message is forwarded"`
+	FromID     int64  `comment:"ID of the original sender, 0 if it's hidden"`
+	FromName   string `comment:"Name of the original sender if it's hidden"`
+	PostID     int    `comment:"ID of the original channel post"`
+	PostAuthor string `comment:"Author signature of the original channel post"`
+	Date       int    `comment:"Date of the original message"`
+}
+
+type EnvMessageEntities struct {
+	URLs     []string `comment:"URLs in the message, including text links"`
+	Hashtags []string `comment:"Hashtags in the message, with leading #"`
+	Mentions []string `comment:"Mentions in the message, e.g. @username"`
 }
 
 func ConvertEnvMessage(msg *tg.Message) EnvMessage {
@@ -49,9 +75,124 @@ func ConvertEnvMessage(msg *tg.Message) EnvMessage {
 			DC:   media.DC,
 		}
 	}
+	m.Media.Type, m.Media.MIME, m.Media.Duration = mediaInfo(msg.Media)
 
 	m.Views = msg.Views
 	m.Forwards = msg.Forwards
 
+	if h, ok := msg.ReplyTo.(*tg.MessageReplyHeader); ok {
+		if h.ForumTopic {
+			// message in topic without reply only has top message ID in ReplyToMsgID
+			m.TopicID = h.ReplyToMsgID
+			if h.ReplyToTopID != 0 {
+				m.TopicID = h.ReplyToTopID
+				m.ReplyTo = h.ReplyToMsgID
+			}
+		} else {
+			m.ReplyTo = h.ReplyToMsgID
+		}
+	}
+
+	m.GroupedID = msg.GroupedID
+
+	if fwd := msg.FwdFrom; !fwd.Zero() {
+		m.Fwd = EnvMessageFwd{
+			Forwarded:  true,
+			FromID:     tutil.GetPeerID(fwd.FromID),
+			FromName:   fwd.FromName,
+			PostID:     fwd.ChannelPost,
+			PostAuthor: fwd.PostAuthor,
+			Date:       fwd.Date,
+		}
+	}
+
+	m.Entities = convertEntities(msg.Message, msg.Entities)
+	m.ReplyMarkup = msg.ReplyMarkup != nil
+
 	return m
 }
+
+func mediaInfo(media tg.MessageMediaClass) (typ, mime string, duration float64) {
+	switch m := media.(type) {
+	case nil, *tg.MessageMediaEmpty:
+		return "", "", 0
+	case *tg.MessageMediaPhoto:
+		return "photo", "image/jpeg", 0
+	case *tg.MessageMediaDocument:
+		doc, ok := m.Document.AsNotEmpty()
+		if !ok {
+			return "document", "", 0
+		}
+
+		typ = "document"
+		for _, attr := range doc.Attributes {
+			switch a := attr.(type) {
+			case *tg.DocumentAttributeSticker:
+				typ = "sticker"
+			case *tg.DocumentAttributeAnimated:
+				typ = "animation"
+			case *tg.DocumentAttributeVideo:
+				duration = a.Duration
+				if typ == "document" {
+					typ = "video"
+					if a.RoundMessage {
+						typ = "round"
+					}
+				}
+			case *tg.DocumentAttributeAudio:
+				duration = float64(a.Duration)
+				if typ == "document" {
+					typ = "audio"
+					if a.Voice {
+						typ = "voice"
+					}
+				}
+			}
+		}
+
+		return typ, doc.MimeType, duration
+	case *tg.MessageMediaWebPage:
+		return "webpage", "", 0
+	case *tg.MessageMediaPoll:
+		return "poll", "", 0
+	case *tg.MessageMediaGeo, *tg.MessageMediaGeoLive, *tg.MessageMediaVenue:
+		return "location", "", 0
+	case *tg.MessageMediaContact:
+		return "contact", "", 0
+	default:
+		return "other", "", 0
+	}
+}
+
+func convertEntities(text string, entities []tg.MessageEntityClass) EnvMessageEntities {
+	e := EnvMessageEntities{}
+	if len(entities) == 0 {
+		return e
+	}
+
+	// offsets of entities are in UTF-16 code units
+	u := utf16.Encode([]rune(text))
+	slice := func(offset, length int) string {
+		if offset < 0 || length < 0 || offset+length > len(u) {
+			return ""
+		}
+		return string(utf16.Decode(u[offset : offset+length]))
+	}
+
+	for _, entity := range entities {
+		switch v := entity.(type) {
+		case *tg.MessageEntityURL:
+			e.URLs = append(e.URLs, slice(v.Offset, v.Length))
+		case *tg.MessageEntityTextURL:
+			e.URLs = append(e.URLs, v.URL)
+		case *tg.MessageEntityHashtag:
+			e.Hashtags = append(e.Hashtags, slice(v.Offset, v.Length))
+		case *tg.MessageEntityMention:
+			e.Mentions = append(e.Mentions, slice(v.Offset, v.Length))
+		case *tg.MessageEntityMentionName:
+			e.Mentions = append(e.Mentions, slice(v.Offset, v.Length))
+		}
+	}
+
+	return e
+}
@@ -4,6 +4,9 @@ import (
 	"testing"
 
 	"github.com/expr-lang/expr"
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMessageExpr(t *testing.T) {
@@ -86,3 +89,70 @@ func TestMessageExpr(t *testing.T) {
 		})
 	}
 }
+
+func TestConvertEnvMessage(t *testing.T) {
+	text := "👋 #tag see https://example.com by @user"
+	msg := &tg.Message{
+		ID:        10,
+		Message:   text,
+		GroupedID: 99,
+		ReplyTo: &tg.MessageReplyHeader{
+			ForumTopic:   true,
+			ReplyToMsgID: 7,
+			ReplyToTopID: 5,
+		},
+		FwdFrom: tg.MessageFwdHeader{
+			FromID:      &tg.PeerChannel{ChannelID: 123},
+			ChannelPost: 42,
+			Date:        1684651590,
+		},
+		Entities: []tg.MessageEntityClass{
+			// emoji takes 2 UTF-16 code units
+			&tg.MessageEntityHashtag{Offset: 3, Length: 4},
+			&tg.MessageEntityURL{Offset: 12, Length: 19},
+			&tg.MessageEntityTextURL{Offset: 0, Length: 2, URL: "https://t.me"},
+			&tg.MessageEntityMention{Offset: 35, Length: 5},
+		},
+		Media: &tg.MessageMediaDocument{
+			Document: &tg.Document{
+				MimeType: "video/mp4",
+				Attributes: []tg.DocumentAttributeClass{
+					&tg.DocumentAttributeVideo{Duration: 12.5},
+				},
+			},
+		},
+		ReplyMarkup: &tg.ReplyInlineMarkup{},
+	}
+	msg.SetFlags()
+
+	m := ConvertEnvMessage(msg)
+	assert.Equal(t, 7, m.ReplyTo)
+	assert.Equal(t, 5, m.TopicID)
+	assert.Equal(t, int64(99), m.GroupedID)
+	assert.Equal(t, EnvMessageFwd{Forwarded: true, FromID: 123, PostID: 42, Date: 1684651590}, m.Fwd)
+	assert.Equal(t, []string{"#tag"}, m.Entities.Hashtags)
+	assert.Equal(t, []string{"https://example.com", "https://t.me"}, m.Entities.URLs)
+	assert.Equal(t, []string{"@user"}, m.Entities.Mentions)
+	assert.Equal(t, "video", m.Media.Type)
+	assert.Equal(t, "video/mp4", m.Media.MIME)
+	assert.Equal(t, 12.5, m.Media.Duration)
+	assert.True(t, m.ReplyMarkup)
+
+	// message in topic without reply
+	msg = &tg.Message{ReplyTo: &tg.MessageReplyHeader{ForumTopic: true, ReplyToMsgID: 5}}
+	m = ConvertEnvMessage(msg)
+	assert.Equal(t, 0, m.ReplyTo)
+	assert.Equal(t, 5, m.TopicID)
+	assert.False(t, m.Fwd.Forwarded)
+	assert.Empty(t, m.Media.Type)
+
+	exp, err := expr.Compile(`Media.Type == "video" && "#tag" in Entities.Hashtags`, expr.Env(EnvMessage{}), expr.AsBool())
+	require.NoError(t, err)
+	got, err := Run(exp, ConvertEnvMessage(&tg.Message{
+		Message:  "#tag",
+		Entities: []tg.MessageEntityClass{&tg.MessageEntityHashtag{Offset: 0, Length: 4}},
+		Media:    &tg.MessageMediaDocument{Document: &tg.Document{Attributes: []tg.DocumentAttributeClass{&tg.DocumentAttributeVideo{}}}},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, true, got)
+}